@@ -1,20 +1,29 @@
 package main
 
 import (
+	"archive/zip"
 	"context"
+	"errors"
+	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
 
+	"blood-on-maple-leaves/backend/domain"
 	"blood-on-maple-leaves/backend/handlers"
 	"blood-on-maple-leaves/backend/middleware"
 	"blood-on-maple-leaves/backend/repo"
 	"blood-on-maple-leaves/backend/service"
+	"blood-on-maple-leaves/backend/stories"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -61,6 +70,102 @@ func runMigrations(dsn, dir string) {
 	log.Fatalf("migrations failed: %v", err)
 }
 
+// defaultStoryID — история, которую обслуживают маршруты без /stories/{story}.
+const defaultStoryID = "blood-on-maple-leaves"
+
+// validateStory проверяет граф истории перед публикацией в каталог:
+// предупреждения пишет в лог, ошибки отменяют публикацию.
+func validateStory(story domain.Story, scenes map[string]domain.Scene) error {
+	report := service.ValidateScenes(scenes, story)
+	for _, issue := range report.Issues {
+		log.Printf("storycheck %s: %s", story.ID, issue)
+	}
+	if report.HasErrors() {
+		return errors.New("story has errors, run `go run ./cmd/storycheck` for details")
+	}
+	return nil
+}
+
+// watchStories перезагружает каталог по SIGHUP и при изменении файлов историй.
+// Если новая версия истории не прошла проверку, игроки продолжают играть в старую.
+func watchStories(catalog *repo.Catalog, interval time.Duration) {
+	onReload := func(err error) {
+		if err != nil {
+			log.Printf("story reload failed, keeping previous versions: %v", err)
+			return
+		}
+		log.Println("stories reloaded")
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			onReload(catalog.Reload())
+		}
+	}()
+
+	go catalog.Watch(context.Background(), interval, onReload)
+}
+
+// openStories собирает файловую систему каталога историй:
+//   - STORIES_DIR — папка или zip-архив с историями; если не задан, используются
+//     истории, встроенные в бинарник (пакет stories);
+//   - STORIES_PATCH_DIR — необязательная папка или архив, файлы которого
+//     перекрывают одноимённые файлы основного каталога.
+//
+// Возвращает функцию закрытия открытых архивов.
+func openStories() (fs.FS, func(), error) {
+	var closers []io.Closer
+	closeAll := func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+
+	// openSource открывает папку или zip-архив
+	openSource := func(path string) (fs.FS, error) {
+		if strings.HasSuffix(path, ".zip") {
+			z, err := zip.OpenReader(path)
+			if err != nil {
+				return nil, err
+			}
+			closers = append(closers, z)
+			return z, nil
+		}
+		return os.DirFS(path), nil
+	}
+
+	// 1. Основной каталог
+	var root fs.FS = stories.FS
+	if dir := os.Getenv("STORIES_DIR"); dir != "" {
+		base, err := openSource(dir)
+		if err != nil {
+			return nil, closeAll, err
+		}
+		root = base
+	}
+
+	// 2. Заплатки поверх него
+	if dir := os.Getenv("STORIES_PATCH_DIR"); dir != "" {
+		patch, err := openSource(dir)
+		if err != nil {
+			closeAll()
+			return nil, closeAll, err
+		}
+		root = repo.NewLayeredFS(root, patch)
+	}
+	return root, closeAll, nil
+}
+
+// envOr возвращает значение переменной окружения или def, если она не задана.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
 func main() {
 	// 1) Прогон миграций до открытия пула Postgres
 	dsn := os.Getenv("DB_DSN")
@@ -75,23 +180,84 @@ func main() {
 	// 3) Репозитории
 	playerRepo := repo.NewPlayerRepo(db)
 	tokenRepo := repo.NewTokenRepo(rdb)
+	// Токены старого формата — в индексы игроков, чтобы их отзывал выход со всех устройств
+	if n, err := tokenRepo.IndexLegacyRefreshTokens(context.Background()); err != nil {
+		log.Printf("legacy refresh tokens indexing failed: %v", err)
+	} else if n > 0 {
+		log.Printf("indexed %d legacy refresh tokens", n)
+	}
 	saveRepo := repo.NewSaveRepoPG(db)
-	sceneRepo := repo.NewSceneRepoFS("./scenes")
+	storiesFS, closeStories, err := openStories()
+	if err != nil {
+		log.Fatalf("stories open error: %v", err)
+	}
+	defer closeStories()
+	// Опубликованные в базе версии перекрывают одноимённые истории из файлов
+	catalog := repo.NewCatalogFromStore(
+		repo.NewLayeredStore(repo.NewFSStore(storiesFS), repo.NewStoryRepoPG(db)),
+		validateStory,
+	)
+	if err := catalog.Reload(); err != nil {
+		log.Fatalf("story load error: %v", err)
+	}
+	watchStories(catalog, 2*time.Second)
 
 	// 4) Сервисы
 	authSvc := service.NewAuthService(playerRepo, tokenRepo)
-	gameSvc := service.NewGameService(sceneRepo, saveRepo)
+	gameSvc := service.NewGameService(catalog, saveRepo)
+	gameSvc.RunRepo = repo.NewRunRepoPG(db)
+	gameSvc.Locales = playerRepo
+	gameSvc.Players = playerRepo
+	gameSvc.DefaultStoryID = envOr("DEFAULT_STORY", defaultStoryID)
+	if _, err := catalog.Scenes(gameSvc.DefaultStoryID); err != nil {
+		log.Fatalf("default story %q: %v", gameSvc.DefaultStoryID, err)
+	}
+	authoringSvc := service.NewAuthoringService(repo.NewDraftRepoPG(db), catalog, catalog.Reload)
 
 	// 5) HTTP-обработчики
+	storyH := handlers.NewStoryHandler(gameSvc)
 	sceneH := handlers.NewSceneHandler(gameSvc)
+	saveH := handlers.NewSaveHandler(gameSvc)
+	adminH := handlers.NewAdminHandler(authoringSvc, gameSvc)
 
 	r := chi.NewRouter()
 	r.Post("/signup", handlers.SignupHandler(authSvc))
 	r.Post("/login", handlers.LoginHandler(authSvc))
+	r.Post("/token/refresh", handlers.RefreshHandler(authSvc))
+	r.Post("/logout", handlers.LogoutHandler(authSvc))
+	r.With(middleware.AuthMiddleware).Post("/logout/all", handlers.LogoutAllHandler(authSvc))
 	r.With(middleware.AuthMiddleware).Get("/me", handlers.MeHandler(authSvc))
+	r.With(middleware.AuthMiddleware).Put("/me/locale", handlers.SetLocaleHandler(gameSvc))
+	r.With(middleware.AuthMiddleware).Get("/runs", sceneH.CompletedRuns)
 
-	r.With(middleware.AuthMiddleware).Get("/scenes/{id}", sceneH.GetScene)
-	r.With(middleware.AuthMiddleware).Post("/scenes/{id}/choose", sceneH.Choose)
+	// Маршруты прохождения внутри одной истории
+	gameRoutes := func(r chi.Router) {
+		r.Use(middleware.AuthMiddleware)
+		r.Post("/games", sceneH.NewGame)
+		r.Get("/games/current", sceneH.CurrentGame)
+		r.Get("/saves", saveH.ListSaves)
+		r.Get("/saves/history", saveH.History)
+		r.Post("/saves/{id}/restore", saveH.Restore)
+		r.Post("/saves/{slot}/load", saveH.LoadSave)
+		r.Patch("/saves/{slot}", saveH.RenameSave)
+		r.Delete("/saves/{slot}", saveH.DeleteSave)
+		r.Get("/scenes/{id}", sceneH.GetScene)
+		r.Post("/scenes/{id}/choose", sceneH.Choose)
+	}
+
+	r.Get("/stories", storyH.ListStories)
+	r.Route("/stories/{story}", func(r chi.Router) {
+		r.Get("/", storyH.GetStory)
+		r.Group(gameRoutes)
+	})
+	// Прежние маршруты без истории работают с историей по умолчанию
+	r.Group(gameRoutes)
+
+	// Редактирование и публикация историй — только для администраторов
+	r.Route("/admin/stories/{story}", func(r chi.Router) {
+		r.Use(middleware.AuthMiddleware, middleware.AdminMiddleware(playerRepo))
+		adminH.Routes(r)
+	})
 
 	r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.Write([]byte("OK"))
@@ -0,0 +1,141 @@
+// Команда storycheck проверяет граф истории перед выкладкой контента:
+// висячие переходы, недостижимые сцены, повторяющиеся выборы,
+// несовпадение id сцены и имени файла, неизвестные характеристики, пустые сцены.
+// Для каждого перевода из locales/ печатает, сколько строк не переведено;
+// с флагом -untranslated перечисляет сами строки.
+//
+// Использование:
+//
+//	go run ./cmd/storycheck -dir ./stories
+//	go run ./cmd/storycheck -dir ./stories/blood-on-maple-leaves
+//	go run ./cmd/storycheck -dir ./stories -untranslated
+//
+// Если в папке есть story.yaml, проверяется одна история, иначе — каждая
+// подпапка с манифестом. Код возврата 1, если найдены ошибки;
+// предупреждения и неполные переводы код возврата не меняют.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/repo"
+	"blood-on-maple-leaves/backend/service"
+)
+
+func main() {
+	dir := flag.String("dir", "./stories", "папка каталога историй или одной истории")
+	untranslated := flag.Bool("untranslated", false, "перечислить непереведённые строки каждого языка")
+	flag.Parse()
+
+	// 1. Одна история или каталог
+	dirs := []string{*dir}
+	if _, err := os.Stat(filepath.Join(*dir, repo.StoryManifestFile)); err != nil {
+		entries, err := os.ReadDir(*dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "storycheck: %v\n", err)
+			os.Exit(2)
+		}
+		dirs = dirs[:0]
+		for _, e := range entries {
+			d := filepath.Join(*dir, e.Name())
+			if _, err := os.Stat(filepath.Join(d, repo.StoryManifestFile)); e.IsDir() && err == nil {
+				dirs = append(dirs, d)
+			}
+		}
+	}
+
+	// 2. Проверяем каждую историю
+	failed := false
+	for _, d := range dirs {
+		if !checkStory(d, *untranslated) {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// checkStory проверяет одну историю и её переводы и печатает отчёт; false — найдены ошибки.
+func checkStory(dir string, listUntranslated bool) bool {
+	name := filepath.Base(dir)
+	sceneRepo := repo.NewSceneRepoFS(dir)
+	story, err := sceneRepo.LoadStory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: story manifest error: %v\n", name, err)
+		os.Exit(2)
+	}
+
+	report, err := service.CheckStory(sceneRepo, story)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: storycheck: %v\n", name, err)
+		os.Exit(2)
+	}
+
+	for _, issue := range report.Issues {
+		fmt.Printf("%s: %s\n", name, issue)
+	}
+	translationsOK := checkTranslations(name, sceneRepo, story, listUntranslated)
+	if report.HasErrors() || !translationsOK {
+		return false
+	}
+	fmt.Printf("%s: story OK\n", name)
+	return true
+}
+
+// checkTranslations печатает состояние переводов истории: сколько строк не переведено
+// и какие ключи перевода не соответствуют ни одной строке, а также проверяет вставки {{...}}
+// в переводах. false — переводы не загружаются или в их вставках есть ошибки.
+func checkTranslations(name string, sceneRepo *repo.SceneRepoFS, story domain.Story, listUntranslated bool) bool {
+	translations, err := sceneRepo.LoadTranslations()
+	if err != nil {
+		fmt.Printf("%s: error: translations: %v\n", name, err)
+		return false
+	}
+	if len(translations) == 0 {
+		return true
+	}
+	strs, err := service.StoryStrings(sceneRepo, story)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: storycheck: %v\n", name, err)
+		os.Exit(2)
+	}
+	scenes := loadScenes(sceneRepo)
+
+	ok := true
+	for _, tr := range service.CheckTranslations(strs, translations) {
+		report := service.CheckTranslationTemplates(scenes, story, translations[tr.Locale])
+		for _, issue := range report.Issues {
+			fmt.Printf("%s: locale %s: %s\n", name, tr.Locale, issue)
+		}
+		if report.HasErrors() {
+			ok = false
+		}
+		fmt.Printf("%s: locale %s: %d/%d strings untranslated\n", name, tr.Locale, len(tr.Untranslated), tr.Total)
+		if listUntranslated {
+			for _, s := range tr.Untranslated {
+				fmt.Printf("%s: locale %s: untranslated %s: %q\n", name, tr.Locale, s.Key, s.Text)
+			}
+		}
+		for _, key := range tr.Unknown {
+			fmt.Printf("%s: locale %s: warning: unknown key %s\n", name, tr.Locale, key)
+		}
+	}
+	return ok
+}
+
+// loadScenes загружает все сцены, которые удаётся разобрать; об остальных сообщает CheckStory.
+func loadScenes(sceneRepo *repo.SceneRepoFS) map[string]domain.Scene {
+	scenes := map[string]domain.Scene{}
+	ids, _ := sceneRepo.List()
+	for _, id := range ids {
+		if scene, err := sceneRepo.Load(id); err == nil {
+			scenes[id] = scene
+		}
+	}
+	return scenes
+}
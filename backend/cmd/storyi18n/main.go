@@ -0,0 +1,241 @@
+// Команда storyi18n выгружает тексты истории для переводчиков в формате gettext PO
+// и загружает готовые переводы обратно в locales/<язык>.yaml.
+//
+// Использование:
+//
+//	go run ./cmd/storyi18n export -dir ./stories/blood-on-maple-leaves -locale en -o en.po
+//	go run ./cmd/storyi18n import -dir ./stories/blood-on-maple-leaves en.po
+//
+// В PO-файле msgctxt — ключ строки (intro.text, intro.choice.attack, hallway.ending),
+// msgid — исходный текст, msgstr — перевод. Экспорт подставляет в msgstr уже
+// имеющиеся переводы. Импорт применяет непустые переводы без флага fuzzy и сообщает
+// о записях, исходный текст которых изменился после выгрузки (stale), и о ключах,
+// которых больше нет в истории (unknown), — такие записи не применяются.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/internal/po"
+	"blood-on-maple-leaves/backend/repo"
+	"blood-on-maple-leaves/backend/service"
+
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = runExport(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "storyi18n: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: storyi18n export -dir <story> -locale <lang> [-o file.po]")
+	fmt.Fprintln(os.Stderr, "       storyi18n import -dir <story> [-locale <lang>] file.po")
+	os.Exit(2)
+}
+
+// runExport выгружает строки истории с текущими переводами в PO.
+func runExport(args []string) error {
+	fl := flag.NewFlagSet("export", flag.ExitOnError)
+	dir := fl.String("dir", "", "папка истории")
+	locale := fl.String("locale", "", "язык перевода (тег BCP 47)")
+	out := fl.String("o", "", "PO-файл; по умолчанию stdout")
+	fl.Parse(args)
+	if *dir == "" || *locale == "" {
+		usage()
+	}
+
+	// 1. Строки истории и имеющийся перевод
+	sceneRepo, strs, err := loadStrings(*dir)
+	if err != nil {
+		return err
+	}
+	tag, err := language.Parse(*locale)
+	if err != nil {
+		return fmt.Errorf("invalid locale %q: %w", *locale, err)
+	}
+	translations, err := sceneRepo.LoadTranslations()
+	if err != nil {
+		return err
+	}
+	current := translations[tag.String()]
+
+	// 2. Записи PO в порядке строк истории
+	f := po.File{Language: tag.String()}
+	for _, s := range strs {
+		f.Entries = append(f.Entries, po.Entry{
+			Context:    s.Key,
+			ID:         s.Text,
+			Str:        current[s.Key],
+			References: []string{sourceFile(s.Key)},
+		})
+	}
+
+	// 3. Запись в файл или stdout
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := po.Write(w, f); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d strings (%d translated) for %s\n",
+		len(strs), len(strs)-len(current.Missing(strs)), tag)
+	return nil
+}
+
+// runImport применяет переводы из PO к locales/<язык>.yaml.
+func runImport(args []string) error {
+	fl := flag.NewFlagSet("import", flag.ExitOnError)
+	dir := fl.String("dir", "", "папка истории")
+	locale := fl.String("locale", "", "язык перевода; по умолчанию из заголовка PO")
+	fl.Parse(args)
+	if *dir == "" || fl.NArg() != 1 {
+		usage()
+	}
+
+	// 1. Разбираем PO
+	file, err := os.Open(fl.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	f, err := po.Read(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fl.Arg(0), err)
+	}
+	if *locale == "" {
+		*locale = f.Language
+	}
+	tag, err := language.Parse(*locale)
+	if err != nil {
+		return fmt.Errorf("invalid locale %q (set -locale or the Language header): %w", *locale, err)
+	}
+
+	// 2. Строки истории и текущий перевод
+	sceneRepo, strs, err := loadStrings(*dir)
+	if err != nil {
+		return err
+	}
+	translations, err := sceneRepo.LoadTranslations()
+	if err != nil {
+		return err
+	}
+
+	// 3. Накладываем переводы; fuzzy-записи требуют проверки и не применяются
+	var entries []service.TranslatedString
+	fuzzy := 0
+	for _, e := range f.Entries {
+		if e.Fuzzy {
+			fuzzy++
+			continue
+		}
+		entries = append(entries, service.TranslatedString{Key: e.Context, Source: e.ID, Text: e.Str})
+	}
+	next, report := service.ImportTranslation(strs, translations[tag.String()], entries)
+
+	// 4. Записываем перевод, если что-то изменилось
+	if len(report.Updated) > 0 {
+		path, err := translationPath(*dir, tag)
+		if err != nil {
+			return err
+		}
+		if err := writeTranslation(path, next); err != nil {
+			return err
+		}
+		fmt.Printf("wrote %s\n", path)
+	}
+
+	// 5. Отчёт
+	fmt.Printf("%s: %d updated, %d unchanged, %d empty, %d fuzzy, %d stale, %d unknown\n",
+		tag, len(report.Updated), report.Unchanged, report.Empty, fuzzy, len(report.Stale), len(report.Unknown))
+	for _, key := range report.Stale {
+		fmt.Printf("stale: %s: source text changed since export, re-translate\n", key)
+	}
+	for _, key := range report.Unknown {
+		fmt.Printf("unknown: %s: no such string in the story\n", key)
+	}
+	return nil
+}
+
+// loadStrings открывает историю и собирает её переводимые строки.
+func loadStrings(dir string) (*repo.SceneRepoFS, []domain.LocalString, error) {
+	sceneRepo := repo.NewSceneRepoFS(dir)
+	story, err := sceneRepo.LoadStory()
+	if err != nil {
+		return nil, nil, fmt.Errorf("story manifest: %w", err)
+	}
+	strs, err := service.StoryStrings(sceneRepo, story)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sceneRepo, strs, nil
+}
+
+// sourceFile возвращает файл, из которого взята строка: первая часть ключа —
+// идентификатор сцены, а для строк манифеста — "story".
+func sourceFile(key string) string {
+	id, _, _ := strings.Cut(key, ".")
+	if id == "story" {
+		return repo.StoryManifestFile
+	}
+	return id + ".yaml"
+}
+
+// translationPath возвращает файл перевода на язык tag; если файл уже есть
+// под неканоническим именем (pt-br.yaml), используется он.
+func translationPath(dir string, tag language.Tag) (string, error) {
+	localesDir := filepath.Join(dir, repo.LocalesDir)
+	entries, err := os.ReadDir(localesDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".yaml")
+		if t, err := language.Parse(name); err == nil && t == tag && name != e.Name() {
+			return filepath.Join(localesDir, e.Name()), nil
+		}
+	}
+	if err := os.MkdirAll(localesDir, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(localesDir, tag.String()+".yaml"), nil
+}
+
+// writeTranslation записывает перевод плоским YAML с ключами по алфавиту.
+func writeTranslation(path string, t domain.Translation) error {
+	data, err := yaml.Marshal(map[string]string(t))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
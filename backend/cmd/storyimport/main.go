@@ -0,0 +1,147 @@
+// Команда storyimport переводит черновик истории из Twine или Ink в формат проекта:
+// story.yaml и по файлу <сцена>.yaml на каждую сцену.
+//
+// Использование:
+//
+//	go run ./cmd/storyimport -o ./stories/ronin ronin.twee
+//	go run ./cmd/storyimport -o ./stories/ronin -title "Ронин" ronin.ink.json
+//
+// Формат определяется по расширению (.twee, .tw — Twine в Twee 3 с разметкой Harlowe;
+// .json — скомпилированный Ink) или задаётся флагом -format. Конструкции, которые
+// не удалось перенести, печатаются как предупреждения; затем результат проверяется
+// так же, как в storycheck. Существующие файлы перезаписываются только с -force.
+// Код возврата 1, если в импортированной истории есть ошибки.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/internal/storyimport"
+	"blood-on-maple-leaves/backend/repo"
+	"blood-on-maple-leaves/backend/service"
+
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	out := flag.String("o", "", "папка, куда записать историю")
+	format := flag.String("format", "", "формат исходника: twee или ink; по умолчанию по расширению")
+	title := flag.String("title", "", "название истории; по умолчанию из StoryTitle или имени файла")
+	locale := flag.String("locale", domain.DefaultLocale, "язык текста истории")
+	force := flag.Bool("force", false, "перезаписывать существующие файлы")
+	flag.Parse()
+	if *out == "" || flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: storyimport -o <story dir> [-format twee|ink] [-title T] [-locale ru] [-force] <file>")
+		os.Exit(2)
+	}
+	src := flag.Arg(0)
+
+	// 1. Импорт
+	res, err := convert(src, *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "storyimport: %s: %v\n", src, err)
+		os.Exit(2)
+	}
+	switch {
+	case *title != "":
+		res.Story.Title = *title
+	case res.Story.Title == "":
+		res.Story.Title = strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	}
+	res.Story.Locale = *locale
+
+	// 2. Запись story.yaml и сцен
+	if err := write(*out, res, *force); err != nil {
+		fmt.Fprintf(os.Stderr, "storyimport: %v\n", err)
+		os.Exit(2)
+	}
+	fmt.Printf("wrote %d scenes to %s\n", len(res.Scenes), *out)
+
+	// 3. Что не перенесено и что скажет storycheck
+	for _, issue := range res.Issues {
+		fmt.Printf("warning: %s\n", issue)
+	}
+	scenes := make(map[string]domain.Scene, len(res.Scenes))
+	for _, sc := range res.Scenes {
+		scenes[sc.ID] = sc
+	}
+	report := service.ValidateScenes(scenes, res.Story)
+	for _, issue := range report.Issues {
+		fmt.Println(issue)
+	}
+	if report.HasErrors() {
+		os.Exit(1)
+	}
+}
+
+// convert читает исходник в формате format или определённом по расширению.
+func convert(path, format string) (storyimport.Result, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".twee", ".tw":
+			format = "twee"
+		case ".json":
+			format = "ink"
+		default:
+			return storyimport.Result{}, errors.New("cannot guess the format from the extension; set -format")
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return storyimport.Result{}, err
+	}
+	defer f.Close()
+	switch format {
+	case "twee":
+		return storyimport.Twee(f)
+	case "ink":
+		return storyimport.Ink(f)
+	}
+	return storyimport.Result{}, fmt.Errorf("unknown format %q", format)
+}
+
+// write записывает манифест и сцены в dir; без force существующие файлы не трогает.
+func write(dir string, res storyimport.Result, force bool) error {
+	files := map[string]any{repo.StoryManifestFile: res.Story}
+	for _, sc := range res.Scenes {
+		files[sc.ID+".yaml"] = sc
+	}
+	if !force {
+		for name := range files {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return fmt.Errorf("%s already exists; use -force to overwrite", filepath.Join(dir, name))
+			}
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, v := range files {
+		if err := writeYAML(filepath.Join(dir, name), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeYAML записывает v с отступом в два пробела, как в историях каталога.
+func writeYAML(path string, v any) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
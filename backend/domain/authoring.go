@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DraftScene — сцена черновика истории в исходном YAML, как её написал автор.
+type DraftScene struct {
+	ID        string    `json:"id"`
+	Source    string    `json:"-"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StoryVersion — опубликованная версия истории. Версии неизменяемы,
+// игра читает последнюю.
+type StoryVersion struct {
+	StoryID     string     `json:"story_id"`
+	Version     int        `json:"version"`
+	Scenes      int        `json:"scenes"`                 // число сцен в версии
+	PublishedBy *uuid.UUID `json:"published_by,omitempty"` // nil — автор удалён
+	PublishedAt time.Time  `json:"published_at"`
+}
@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Condition — условие доступности выбора.
+// Простое условие сравнивает характеристику с числом: {stat: honor, op: ">=", value: 3}
+// или проверяет флаг сюжета: {flag: spared_monk} / {not_flag: took_sword}.
+// Составное условие выполняется, если выполнено хотя бы одно из Any
+// и все из All; простые и составные проверки можно сочетать в одном узле.
+type Condition struct {
+	Stat    string      `yaml:"stat,omitempty" json:"stat,omitempty"`
+	Op      string      `yaml:"op,omitempty" json:"op,omitempty"` // >=, >, <=, <, ==, !=
+	Value   int         `yaml:"value,omitempty" json:"value,omitempty"`
+	Flag    string      `yaml:"flag,omitempty" json:"flag,omitempty"`         // флаг должен быть поднят
+	NotFlag string      `yaml:"not_flag,omitempty" json:"not_flag,omitempty"` // флаг должен быть снят
+	Any     []Condition `yaml:"any,omitempty" json:"any,omitempty"`
+	All     []Condition `yaml:"all,omitempty" json:"all,omitempty"`
+}
+
+// ConditionOps — допустимые операторы сравнения.
+var ConditionOps = []string{">=", ">", "<=", "<", "==", "!="}
+
+// Met проверяет условие для состояния сохранения.
+// Отсутствующая характеристика считается равной нулю.
+func (c Condition) Met(s Save) bool {
+	if c.Stat != "" && !compare(s.Stats[c.Stat], c.Op, c.Value) {
+		return false
+	}
+	if c.Flag != "" && !s.HasFlag(c.Flag) {
+		return false
+	}
+	if c.NotFlag != "" && s.HasFlag(c.NotFlag) {
+		return false
+	}
+	for _, sub := range c.All {
+		if !sub.Met(s) {
+			return false
+		}
+	}
+	if len(c.Any) > 0 {
+		for _, sub := range c.Any {
+			if sub.Met(s) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// String возвращает человекочитаемую запись условия, например "honor >= 3 or rage < 2".
+func (c Condition) String() string {
+	var parts []string
+	if c.Stat != "" {
+		parts = append(parts, fmt.Sprintf("%s %s %d", c.Stat, c.Op, c.Value))
+	}
+	if c.Flag != "" {
+		parts = append(parts, c.Flag)
+	}
+	if c.NotFlag != "" {
+		parts = append(parts, "not "+c.NotFlag)
+	}
+	for _, sub := range c.All {
+		parts = append(parts, sub.group())
+	}
+	if len(c.Any) > 0 {
+		alts := make([]string, len(c.Any))
+		for i, sub := range c.Any {
+			alts[i] = sub.group()
+		}
+		anyStr := strings.Join(alts, " or ")
+		if len(parts) > 0 {
+			anyStr = "(" + anyStr + ")"
+		}
+		parts = append(parts, anyStr)
+	}
+	return strings.Join(parts, " and ")
+}
+
+// group оборачивает составное условие в скобки, чтобы сохранить приоритет при склейке.
+func (c Condition) group() string {
+	s := c.String()
+	if strings.Contains(s, " and ") || strings.Contains(s, " or ") {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// Unmet возвращает первое невыполненное условие из списка (все условия связаны через «и»).
+func Unmet(conds []Condition, s Save) (Condition, bool) {
+	for _, c := range conds {
+		if !c.Met(s) {
+			return c, true
+		}
+	}
+	return Condition{}, false
+}
+
+func compare(a int, op string, b int) bool {
+	switch op {
+	case ">=":
+		return a >= b
+	case ">":
+		return a > b
+	case "<=":
+		return a <= b
+	case "<":
+		return a < b
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	}
+	return false
+}
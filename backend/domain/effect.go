@@ -0,0 +1,109 @@
+package domain
+
+import "sort"
+
+// Операции над характеристиками в списке ops.
+const (
+	OpAdd    = "add"    // прибавить Value
+	OpSet    = "set"    // присвоить Value
+	OpMul    = "mul"    // умножить на Value
+	OpClamp  = "clamp"  // ограничить границами Min и Max; любую из них можно не указывать
+	OpRandom = "random" // прибавить случайное число от Min до Max включительно
+	OpCopy   = "copy"   // присвоить значение характеристики From
+)
+
+// EffectOps — допустимые операции эффектов.
+var EffectOps = []string{OpAdd, OpSet, OpMul, OpClamp, OpRandom, OpCopy}
+
+// Effect — одна операция над характеристикой, например
+// {stat: rage, op: set, value: 0} или {stat: karma, op: random, min: -2, max: 2}.
+// Операции применяются по порядку, каждая видит результат предыдущих.
+type Effect struct {
+	Stat  string `yaml:"stat"`
+	Op    string `yaml:"op"`
+	Value int    `yaml:"value,omitempty"` // для add, set, mul
+	Min   *int   `yaml:"min,omitempty"`   // для clamp и random
+	Max   *int   `yaml:"max,omitempty"`   // для clamp и random
+	From  string `yaml:"from,omitempty"`  // для copy
+}
+
+// AppliedEffect — изменение характеристики одной операцией эффекта.
+// Значения указаны уже с учётом границ характеристики из манифеста.
+type AppliedEffect struct {
+	Stat   string `json:"stat"`
+	Op     string `json:"op"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+	Delta  int    `json:"delta"`
+}
+
+// ApplyOps применяет к сохранению сначала прибавки effects в алфавитном порядке
+// характеристик, затем операции ops по порядку. После каждой операции объявленная
+// характеристика ограничивается своими границами. Каждое применённое изменение
+// дописывается в save.Effects. Случайные операции бросают кубик прохождения,
+// как и броски выбора, и увеличивают save.Rolls. Исходное сохранение не меняется.
+func (s Story) ApplyOps(save Save, effects map[string]int, ops []Effect) Save {
+	if len(effects) == 0 && len(ops) == 0 {
+		return save
+	}
+	stats := make(map[string]int, len(save.Stats)+len(effects)+len(ops))
+	for k, v := range save.Stats {
+		stats[k] = v
+	}
+	save.Effects = append([]AppliedEffect(nil), save.Effects...)
+
+	apply := func(stat, op string, v int) {
+		if d, ok := s.Stat(stat); ok {
+			v = d.Clamp(v)
+		}
+		before := stats[stat]
+		stats[stat] = v
+		save.Effects = append(save.Effects, AppliedEffect{Stat: stat, Op: op, Before: before, After: v, Delta: v - before})
+	}
+
+	keys := make([]string, 0, len(effects))
+	for k := range effects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		apply(k, OpAdd, stats[k]+effects[k])
+	}
+
+	for _, e := range ops {
+		v := stats[e.Stat]
+		switch e.Op {
+		case OpAdd:
+			v += e.Value
+		case OpSet:
+			v = e.Value
+		case OpMul:
+			v *= e.Value
+		case OpClamp:
+			v = StatDef{Min: e.Min, Max: e.Max}.Clamp(v)
+		case OpRandom:
+			lo, hi := bound(e.Min), bound(e.Max)
+			if hi < lo {
+				lo, hi = hi, lo
+			}
+			v += lo + save.rng().IntN(hi-lo+1)
+			save.Rolls++
+		case OpCopy:
+			v = stats[e.From]
+		default:
+			continue // неизвестную операцию отклоняет проверка истории
+		}
+		apply(e.Stat, e.Op, v)
+	}
+
+	save.Stats = stats
+	return save
+}
+
+// bound возвращает значение необязательной границы; nil — ноль.
+func bound(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
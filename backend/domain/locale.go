@@ -0,0 +1,165 @@
+package domain
+
+import (
+	"sort"
+	"strconv"
+)
+
+// DefaultLocale — язык исходного текста истории, если манифест не указывает иной.
+const DefaultLocale = "ru"
+
+// Translation — перевод истории на один язык: ключ строки → переведённый текст.
+// Ключи строятся из идентификаторов сцен и выборов (см. SceneTextKey и соседние функции),
+// поэтому перевод не зависит от порядка сцен и выборов в файлах.
+// Отсутствующий или пустой ключ означает, что строка не переведена
+// и показывается на языке исходного текста.
+type Translation map[string]string
+
+// LocalString — переводимая строка истории: ключ и исходный текст.
+type LocalString struct {
+	Key  string `json:"key"`
+	Text string `json:"text"`
+}
+
+// Ключи строк манифеста истории.
+const (
+	StoryTitleKey       = "story.title"
+	StoryDescriptionKey = "story.description"
+)
+
+// SceneTextKey — ключ основного текста сцены.
+func SceneTextKey(sceneID string) string {
+	return sceneID + ".text"
+}
+
+// ConditionalTextKey — ключ i-го условного абзаца сцены.
+func ConditionalTextKey(sceneID string, i int) string {
+	return sceneID + ".text." + strconv.Itoa(i)
+}
+
+// ChoiceTextKey — ключ текста выбора.
+func ChoiceTextKey(sceneID, choiceID string) string {
+	return sceneID + ".choice." + choiceID
+}
+
+// EndingTitleKey — ключ названия концовки сцены.
+func EndingTitleKey(sceneID string) string {
+	return sceneID + ".ending"
+}
+
+// Text возвращает перевод строки или fallback, если перевода нет.
+func (t Translation) Text(key, fallback string) string {
+	if s := t[key]; s != "" {
+		return s
+	}
+	return fallback
+}
+
+// Missing возвращает строки, для которых в переводе нет текста, в исходном порядке.
+func (t Translation) Missing(strs []LocalString) []LocalString {
+	var missing []LocalString
+	for _, s := range strs {
+		if t[s.Key] == "" {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+// Unknown возвращает отсортированные ключи перевода, которым нет соответствия среди strs:
+// обычно это следы удалённых сцен и выборов.
+func (t Translation) Unknown(strs []LocalString) []string {
+	known := make(map[string]bool, len(strs))
+	for _, s := range strs {
+		known[s.Key] = true
+	}
+	var unknown []string
+	for key := range t {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// Strings возвращает переводимые строки сцены: текст, условные абзацы,
+// тексты выборов и название концовки. Пустые строки пропускаются.
+func (sc Scene) Strings() []LocalString {
+	var strs []LocalString
+	add := func(key, text string) {
+		if text != "" {
+			strs = append(strs, LocalString{Key: key, Text: text})
+		}
+	}
+
+	add(SceneTextKey(sc.ID), sc.Text)
+	for i, ct := range sc.ConditionalText {
+		add(ConditionalTextKey(sc.ID, i), ct.Text)
+	}
+	for _, c := range sc.Choices {
+		add(ChoiceTextKey(sc.ID, c.ID), c.Text)
+	}
+	if sc.Ending != nil {
+		add(EndingTitleKey(sc.ID), sc.Ending.Title)
+	}
+	return strs
+}
+
+// Localize возвращает копию сцены с текстом из перевода t; непереведённые строки
+// остаются на исходном языке. Исходная сцена не меняется: она может принадлежать
+// общему снимку истории.
+func (sc Scene) Localize(t Translation) Scene {
+	if len(t) == 0 {
+		return sc
+	}
+
+	out := sc
+	out.Text = t.Text(SceneTextKey(sc.ID), sc.Text)
+
+	out.ConditionalText = make([]ConditionalText, len(sc.ConditionalText))
+	for i, ct := range sc.ConditionalText {
+		ct.Text = t.Text(ConditionalTextKey(sc.ID, i), ct.Text)
+		out.ConditionalText[i] = ct
+	}
+
+	out.Choices = make([]Choice, len(sc.Choices))
+	for i, c := range sc.Choices {
+		c.Text = t.Text(ChoiceTextKey(sc.ID, c.ID), c.Text)
+		out.Choices[i] = c
+	}
+
+	if sc.Ending != nil {
+		ending := *sc.Ending
+		ending.Title = t.Text(EndingTitleKey(sc.ID), ending.Title)
+		out.Ending = &ending
+	}
+	return out
+}
+
+// Strings возвращает переводимые строки манифеста: название и описание.
+func (s Story) Strings() []LocalString {
+	var strs []LocalString
+	if s.Title != "" {
+		strs = append(strs, LocalString{Key: StoryTitleKey, Text: s.Title})
+	}
+	if s.Description != "" {
+		strs = append(strs, LocalString{Key: StoryDescriptionKey, Text: s.Description})
+	}
+	return strs
+}
+
+// Localize возвращает копию манифеста с названием и описанием из перевода t.
+func (s Story) Localize(t Translation) Story {
+	s.Title = t.Text(StoryTitleKey, s.Title)
+	s.Description = t.Text(StoryDescriptionKey, s.Description)
+	return s
+}
+
+// SourceLocale возвращает язык исходного текста истории.
+func (s Story) SourceLocale() string {
+	if s.Locale != "" {
+		return s.Locale
+	}
+	return DefaultLocale
+}
@@ -0,0 +1,48 @@
+package domain
+
+import "sort"
+
+// Migration — перенос сохранений на версию Version истории, объявляется в манифесте
+// рядом с контентом, который её потребовал:
+//
+//	version: 3
+//	migrations:
+//	  - version: 3
+//	    scenes:
+//	      old_bridge: river     # сцену удалили: игроки с моста попадают к реке
+//	    stats:
+//	      honor: 1              # компенсация за вырезанный выбор
+//
+// Миграция применяется к сохранениям, сделанным на версиях ниже Version.
+type Migration struct {
+	Version int               `yaml:"version"`
+	Scenes  map[string]string `yaml:"scenes,omitempty"` // прежний ID сцены → новый
+	Stats   map[string]int    `yaml:"stats,omitempty"`  // прибавляется к характеристикам
+}
+
+// MigrateSave переносит сохранение с версии save.StoryVersion на текущую версию истории:
+// по порядку применяет миграции с версиями выше версии сохранения и не выше текущей.
+// Существует ли сцена, в которой окажется игрок, проверяет вызывающий код.
+func (s Story) MigrateSave(save Save) Save {
+	if save.StoryVersion == s.Version {
+		return save
+	}
+	migrations := make([]Migration, 0, len(s.Migrations))
+	for _, m := range s.Migrations {
+		if m.Version > save.StoryVersion && m.Version <= s.Version {
+			migrations = append(migrations, m)
+		}
+	}
+	sort.SliceStable(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for _, m := range migrations {
+		if next, ok := m.Scenes[save.SceneID]; ok {
+			save.SceneID = next
+		}
+		if len(m.Stats) > 0 {
+			save.Stats = s.ApplyEffects(save.Stats, m.Stats)
+		}
+	}
+	save.StoryVersion = s.Version
+	return save
+}
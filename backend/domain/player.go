@@ -2,17 +2,22 @@ package domain
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrInvalidInput — данные игрока не прошли проверку.
+var ErrInvalidInput = errors.New("invalid input")
+
 // Player — доменная сущность игрока
 type Player struct {
 	ID           uuid.UUID // Уникальный идентификатор
 	Username     string    // Имя игрока
 	PasswordHash string    // Хеш пароля (а не сам пароль)
+	Locale       string    // Выбранный язык (тег BCP 47); пусто — по Accept-Language
 	CreatedAt    time.Time
 }
 
@@ -20,12 +25,12 @@ type Player struct {
 func NewPlayer(username, rawPassword string) (Player, error) {
 	// Проверка логина
 	if len(username) < 3 {
-		return Player{}, errors.New("username must be at least 3 characters")
+		return Player{}, fmt.Errorf("%w: username must be at least 3 characters", ErrInvalidInput)
 	}
 
 	// Проверка пароля
 	if len(rawPassword) < 6 {
-		return Player{}, errors.New("password must be at least 6 characters")
+		return Player{}, fmt.Errorf("%w: password must be at least 6 characters", ErrInvalidInput)
 	}
 
 	// Генерация ID
@@ -0,0 +1,146 @@
+package domain
+
+import "math/rand/v2"
+
+// DefaultDice — число граней кубика проверки навыка, если в сцене не указано иное.
+const DefaultDice = 20
+
+// Виды бросков.
+const (
+	RollCheck  = "check"  // проверка навыка: кубик + характеристика против сложности
+	RollRandom = "random" // выбор исхода по весам
+)
+
+// Outcome — один из исходов выбора со своей следующей сценой и последствиями.
+type Outcome struct {
+	ID         string         `yaml:"id,omitempty" json:"id"`
+	Weight     int            `yaml:"weight,omitempty" json:"weight,omitempty"` // вес для случайного исхода; 0 — считается 1
+	Next       string         `yaml:"next,omitempty" json:"next"`
+	Effects    map[string]int `yaml:"effects,omitempty" json:"effects,omitempty"`
+	Ops        []Effect       `yaml:"ops,omitempty" json:"-"`
+	SetFlags   []string       `yaml:"set_flags,omitempty" json:"-"`
+	ClearFlags []string       `yaml:"clear_flags,omitempty" json:"-"`
+}
+
+// SkillCheck — проверка навыка: бросок кубика Dice плюс значение характеристики Stat
+// сравнивается со сложностью Difficulty. Успех — если сумма не меньше сложности.
+type SkillCheck struct {
+	Stat       string  `yaml:"stat" json:"stat"`
+	Dice       int     `yaml:"dice,omitempty" json:"dice,omitempty"` // граней у кубика; 0 — DefaultDice
+	Difficulty int     `yaml:"difficulty" json:"difficulty"`
+	Success    Outcome `yaml:"success" json:"-"`
+	Failure    Outcome `yaml:"failure" json:"-"`
+}
+
+// Sides возвращает число граней кубика проверки.
+func (c SkillCheck) Sides() int {
+	if c.Dice > 0 {
+		return c.Dice
+	}
+	return DefaultDice
+}
+
+// Roll — результат броска, которым разрешился выбор.
+type Roll struct {
+	Kind       string `json:"kind"`                 // check или random
+	Stat       string `json:"stat,omitempty"`       // для check
+	Dice       int    `json:"dice,omitempty"`       // для check: граней у кубика
+	Value      int    `json:"value"`                // выпавшее значение: грань кубика или номер исхода с 1
+	Modifier   int    `json:"modifier,omitempty"`   // для check: значение характеристики
+	Total      int    `json:"total,omitempty"`      // для check: Value + Modifier
+	Difficulty int    `json:"difficulty,omitempty"` // для check
+	Success    bool   `json:"success,omitempty"`    // для check
+	Outcome    string `json:"outcome"`              // идентификатор выпавшего исхода
+}
+
+// Resolution — итог выбора: куда ведёт, что меняет и каким броском определён.
+type Resolution struct {
+	Next       string
+	Effects    map[string]int
+	Ops        []Effect
+	SetFlags   []string
+	ClearFlags []string
+	Roll       *Roll // nil — выбор без случайности
+}
+
+// IsRandom сообщает, зависит ли итог выбора от броска.
+func (c Choice) IsRandom() bool {
+	return c.Check != nil || len(c.Outcomes) > 0
+}
+
+// Branches возвращает все возможные исходы выбора: исходы проверки навыка,
+// случайные исходы или единственный исход из Next, Effects и Ops.
+func (c Choice) Branches() []Outcome {
+	switch {
+	case c.Check != nil:
+		return []Outcome{c.Check.Success, c.Check.Failure}
+	case len(c.Outcomes) > 0:
+		return c.Outcomes
+	}
+	return []Outcome{{Next: c.Next, Effects: c.Effects, Ops: c.Ops, SetFlags: c.SetFlags, ClearFlags: c.ClearFlags}}
+}
+
+// Resolve определяет итог выбора для состояния s. Бросок детерминирован:
+// он зависит только от зерна прохождения s.Seed и номера броска s.Rolls,
+// поэтому повтор запроса или откат к снимку дают тот же результат.
+// Вызывающий код увеличивает Rolls в новом сохранении, если Roll не nil.
+func (c Choice) Resolve(s Save) Resolution {
+	switch {
+	case c.Check != nil:
+		sides := c.Check.Sides()
+		value := s.rng().IntN(sides) + 1
+		mod := s.Stats[c.Check.Stat]
+		roll := &Roll{
+			Kind: RollCheck, Stat: c.Check.Stat, Dice: sides,
+			Value: value, Modifier: mod, Total: value + mod, Difficulty: c.Check.Difficulty,
+		}
+		outcome := c.Check.Failure
+		if roll.Total >= c.Check.Difficulty {
+			roll.Success = true
+			outcome = c.Check.Success
+		}
+		roll.Outcome = outcomeID(outcome, roll.Success)
+		return outcome.resolution(roll)
+
+	case len(c.Outcomes) > 0:
+		total := 0
+		for _, o := range c.Outcomes {
+			total += o.weight()
+		}
+		n := s.rng().IntN(total)
+		i := 0
+		for ; n >= c.Outcomes[i].weight(); i++ {
+			n -= c.Outcomes[i].weight()
+		}
+		o := c.Outcomes[i]
+		return o.resolution(&Roll{Kind: RollRandom, Value: i + 1, Outcome: o.ID})
+	}
+	return Resolution{Next: c.Next, Effects: c.Effects, Ops: c.Ops, SetFlags: c.SetFlags, ClearFlags: c.ClearFlags}
+}
+
+func (o Outcome) weight() int {
+	if o.Weight > 0 {
+		return o.Weight
+	}
+	return 1
+}
+
+func (o Outcome) resolution(roll *Roll) Resolution {
+	return Resolution{Next: o.Next, Effects: o.Effects, Ops: o.Ops, SetFlags: o.SetFlags, ClearFlags: o.ClearFlags, Roll: roll}
+}
+
+// outcomeID возвращает идентификатор исхода проверки: заданный в сцене или success/failure.
+func outcomeID(o Outcome, success bool) string {
+	switch {
+	case o.ID != "":
+		return o.ID
+	case success:
+		return "success"
+	}
+	return "failure"
+}
+
+// rng возвращает генератор для очередного броска прохождения.
+func (s Save) rng() *rand.Rand {
+	return rand.New(rand.NewPCG(uint64(s.Seed), uint64(s.Rolls)))
+}
@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CompletedRun — запись о завершённом прохождении игрока.
+type CompletedRun struct {
+	ID         uuid.UUID      `json:"id"`
+	PlayerID   uuid.UUID      `json:"-"`
+	StoryID    string         `json:"story_id"`
+	SceneID    string         `json:"scene_id"`
+	Ending     Ending         `json:"ending"`
+	Stats      map[string]int `json:"stats"`
+	Flags      []string       `json:"flags"`
+	FinishedAt time.Time      `json:"finished_at"`
+}
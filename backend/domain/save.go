@@ -1,17 +1,65 @@
 package domain
 
 import (
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// DefaultSlot — слот сохранения, в котором начинается первая игра.
+const DefaultSlot = "main"
+
 type Save struct {
-	ID        uuid.UUID
-	PlayerID  uuid.UUID
-	SceneID   string
-	Honor     int
-	Rage      int
-	Karma     int
-	CreatedAt time.Time
+	ID           uuid.UUID
+	PlayerID     uuid.UUID
+	StoryID      string // история, к которой относится прохождение
+	StoryVersion int    // версия истории, на которой сделано сохранение; 0 — до учёта версий
+	Slot         string // слот сохранения; у игрока может быть несколько независимых прохождений
+	SceneID      string
+	Stats        map[string]int  // характеристики по именам из манифеста истории
+	Flags        []string        // поднятые флаги сюжета, отсортированы и без повторов
+	EndingID     string          // непусто, если прохождение завершено этой концовкой
+	Seed         int64           // зерно бросков прохождения, задаётся в начале игры
+	Rolls        int             // сколько бросков уже сделано; номер следующего броска
+	Roll         *Roll           // бросок, которым определён переход в этот снимок; nil — без броска
+	Effects      []AppliedEffect // изменения характеристик по пути в этот снимок; не хранятся
+	CreatedAt    time.Time
+}
+
+// Finished сообщает, завершено ли прохождение.
+func (s Save) Finished() bool {
+	return s.EndingID != ""
+}
+
+// HasFlag сообщает, поднят ли флаг сюжета.
+func (s Save) HasFlag(name string) bool {
+	_, found := slices.BinarySearch(s.Flags, name)
+	return found
+}
+
+// ApplyFlags возвращает новый набор флагов: flags плюс set минус clear.
+// Результат отсортирован и не содержит повторов; исходный срез не меняется.
+func ApplyFlags(flags, set, clear []string) []string {
+	out := make([]string, 0, len(flags)+len(set))
+	out = append(out, flags...)
+	out = append(out, set...)
+	out = slices.DeleteFunc(out, func(f string) bool {
+		return slices.Contains(clear, f)
+	})
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// SaveSlot — сводка по слоту сохранения: последнее состояние прохождения в нём.
+type SaveSlot struct {
+	Slot         string         `json:"slot"`
+	Name         string         `json:"name"`
+	Active       bool           `json:"active"`
+	SceneID      string         `json:"scene_id"`
+	Stats        map[string]int `json:"stats"`
+	Finished     bool           `json:"finished"`
+	LastPlayed   time.Time      `json:"last_played"`
+	Outdated     bool           `json:"outdated,omitempty"` // сохранение не переносится на текущую версию истории
+	StoryVersion int            `json:"-"`                  // версия истории последнего сохранения слота
 }
@@ -1,14 +1,118 @@
 package domain
 
+import "regexp"
+
+// sceneIDPattern — грамматика идентификатора сцены: строчные латинские буквы,
+// цифры, "_" и "-", не длиннее 64 символов. Идентификатор одновременно служит
+// именем файла, поэтому точки и разделители пути в нём запрещены.
+var sceneIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// ValidSceneID сообщает, соответствует ли идентификатор грамматике сцен.
+func ValidSceneID(id string) bool {
+	return sceneIDPattern.MatchString(id)
+}
+
+// ValidStoryID сообщает, допустим ли идентификатор истории.
+// Грамматика та же, что у сцен: идентификатор истории — имя её папки в каталоге.
+func ValidStoryID(id string) bool {
+	return sceneIDPattern.MatchString(id)
+}
+
 type Choice struct {
-	ID      string         `yaml:"id"`
-	Text    string         `yaml:"text"`
-	Next    string         `yaml:"next"`
-	Effects map[string]int `yaml:"effects"` // rage, honor, karma и т.д.
+	ID         string         `yaml:"id" json:"id"`
+	Text       string         `yaml:"text" json:"text"`
+	Next       string         `yaml:"next,omitempty" json:"next"`
+	Effects    map[string]int `yaml:"effects,omitempty" json:"effects,omitempty"`   // rage, honor, karma и т.д.
+	Ops        []Effect       `yaml:"ops,omitempty" json:"-"`                       // операции над характеристиками после Effects
+	Requires   []Condition    `yaml:"requires,omitempty" json:"requires,omitempty"` // все условия должны выполняться
+	Hidden     bool           `yaml:"hidden,omitempty" json:"-"`                    // скрывать выбор, пока условия не выполнены
+	SetFlags   []string       `yaml:"set_flags,omitempty" json:"-"`                 // флаги, которые выбор поднимает
+	ClearFlags []string       `yaml:"clear_flags,omitempty" json:"-"`               // флаги, которые выбор снимает
+	Check      *SkillCheck    `yaml:"check,omitempty" json:"check,omitempty"`       // проверка навыка; заменяет Next и Effects
+	Outcomes   []Outcome      `yaml:"outcomes,omitempty" json:"-"`                  // случайные исходы по весам; заменяют Next и Effects
+}
+
+// ConditionalText — абзац сцены, который показывается только при выполнении условий.
+type ConditionalText struct {
+	Requires []Condition `yaml:"requires,omitempty"`
+	Text     string      `yaml:"text"`
+}
+
+// Категории концовок.
+const (
+	EndingGood   = "good"
+	EndingBad    = "bad"
+	EndingSecret = "secret"
+)
+
+// Ending — описание концовки; сцена с Ending завершает прохождение.
+type Ending struct {
+	ID       string `yaml:"id" json:"id"`
+	Title    string `yaml:"title,omitempty" json:"title"`
+	Category string `yaml:"category,omitempty" json:"category"` // good, bad, secret
+}
+
+// SceneEntry — изменения состояния, которые сцена применяет каждый раз, когда игрок
+// в неё приходит: выбором, переходом или в начале игры. Повторный показ сцены
+// без нового прихода их не применяет.
+type SceneEntry struct {
+	Effects    map[string]int `yaml:"effects,omitempty"`
+	Ops        []Effect       `yaml:"ops,omitempty"`
+	SetFlags   []string       `yaml:"set_flags,omitempty"`
+	ClearFlags []string       `yaml:"clear_flags,omitempty"`
+}
+
+// Redirect — автоматический переход: если при приходе в сцену выполнены все условия,
+// игрок сразу оказывается в сцене Next. Redirect без условий срабатывает всегда.
+type Redirect struct {
+	Requires []Condition `yaml:"requires,omitempty"`
+	Next     string      `yaml:"next"`
 }
 
 type Scene struct {
-	ID      string   `yaml:"id"`
-	Text    string   `yaml:"text"`
-	Choices []Choice `yaml:"choices"`
+	ID              string            `yaml:"id" json:"id"`
+	Text            string            `yaml:"text" json:"text"`
+	ConditionalText []ConditionalText `yaml:"conditional_text,omitempty" json:"-"` // абзацы, зависящие от флагов и характеристик
+	Choices         []Choice          `yaml:"choices,omitempty" json:"choices"`
+	Ending          *Ending           `yaml:"ending,omitempty" json:"ending,omitempty"` // nil — обычная сцена
+	OnEnter         *SceneEntry       `yaml:"on_enter,omitempty" json:"-"`              // изменения состояния при приходе в сцену
+	Redirects       []Redirect        `yaml:"redirects,omitempty" json:"-"`             // проверяются по порядку до OnEnter
+}
+
+// IsEnding сообщает, завершает ли сцена прохождение.
+func (sc Scene) IsEnding() bool {
+	return sc.Ending != nil
+}
+
+// RedirectFor возвращает сцену первого перехода, условия которого выполнены для состояния s.
+func (sc Scene) RedirectFor(s Save) (string, bool) {
+	for _, r := range sc.Redirects {
+		if _, unmet := Unmet(r.Requires, s); !unmet {
+			return r.Next, true
+		}
+	}
+	return "", false
+}
+
+// AlwaysRedirects сообщает, уводит ли сцена игрока при любом состоянии:
+// среди переходов есть переход без условий. Такую сцену игрок никогда не видит.
+func (sc Scene) AlwaysRedirects() bool {
+	for _, r := range sc.Redirects {
+		if len(r.Requires) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// TextFor собирает текст сцены для состояния s: основной текст
+// и все абзацы ConditionalText, чьи условия выполнены, через пустую строку.
+func (sc Scene) TextFor(s Save) string {
+	text := sc.Text
+	for _, ct := range sc.ConditionalText {
+		if _, unmet := Unmet(ct.Requires, s); !unmet {
+			text += "\n\n" + ct.Text
+		}
+	}
+	return text
 }
@@ -0,0 +1,152 @@
+package domain
+
+// StatDef — описание характеристики из манифеста истории.
+type StatDef struct {
+	Name    string `yaml:"name" json:"name"`
+	Default int    `yaml:"default" json:"default"`
+	Min     *int   `yaml:"min,omitempty" json:"min,omitempty"` // нижняя граница; nil — без ограничения
+	Max     *int   `yaml:"max,omitempty" json:"max,omitempty"` // верхняя граница; nil — без ограничения
+	Visible *bool  `yaml:"visible,omitempty" json:"-"`         // показывать ли игроку; по умолчанию true
+}
+
+// IsVisible сообщает, показывается ли характеристика игроку.
+func (d StatDef) IsVisible() bool {
+	return d.Visible == nil || *d.Visible
+}
+
+// Clamp ограничивает значение границами характеристики.
+func (d StatDef) Clamp(v int) int {
+	if d.Min != nil && v < *d.Min {
+		return *d.Min
+	}
+	if d.Max != nil && v > *d.Max {
+		return *d.Max
+	}
+	return v
+}
+
+// Story — манифест истории (story.yaml): описание, стартовая сцена и объявленные характеристики.
+// ID совпадает с именем папки истории в каталоге; в манифесте его можно не указывать.
+type Story struct {
+	ID          string      `yaml:"id,omitempty" json:"id"`
+	Title       string      `yaml:"title" json:"title"`
+	Description string      `yaml:"description,omitempty" json:"description,omitempty"`
+	Version     int         `yaml:"version" json:"version"` // растёт с каждой выкладкой контента
+	Start       string      `yaml:"start" json:"start"`
+	Stats       []StatDef   `yaml:"stats,omitempty" json:"stats"`
+	AllowRewind bool        `yaml:"allow_rewind,omitempty" json:"allow_rewind"` // разрешено ли откатываться к прошлым решениям
+	Locale      string      `yaml:"locale,omitempty" json:"locale"`             // язык исходного текста; пусто — DefaultLocale
+	Locales     []string    `yaml:"-" json:"locales,omitempty"`                 // язык исходного текста и все языки переводов
+	Migrations  []Migration `yaml:"migrations,omitempty" json:"-"`              // как переносить сохранения прежних версий
+}
+
+// Stat возвращает описание характеристики по имени.
+func (s Story) Stat(name string) (StatDef, bool) {
+	for _, d := range s.Stats {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return StatDef{}, false
+}
+
+// DefaultStats возвращает стартовые значения всех объявленных характеристик.
+func (s Story) DefaultStats() map[string]int {
+	stats := make(map[string]int, len(s.Stats))
+	for _, d := range s.Stats {
+		stats[d.Name] = d.Clamp(d.Default)
+	}
+	return stats
+}
+
+// Normalize возвращает копию stats, дополненную значениями по умолчанию
+// для характеристик, объявленных позже, чем было сделано сохранение.
+func (s Story) Normalize(stats map[string]int) map[string]int {
+	out := s.DefaultStats()
+	for k, v := range stats {
+		out[k] = v
+	}
+	return out
+}
+
+// Enter применяет к сохранению изменения OnEnter сцены: эффекты и операции
+// так же, как ApplyOps, и флаги. Исходное сохранение не меняется.
+func (s Story) Enter(sc Scene, save Save) Save {
+	if sc.OnEnter == nil {
+		return save
+	}
+	save = s.ApplyOps(save, sc.OnEnter.Effects, sc.OnEnter.Ops)
+	save.Flags = ApplyFlags(save.Flags, sc.OnEnter.SetFlags, sc.OnEnter.ClearFlags)
+	return save
+}
+
+// ApplyEffects прибавляет эффекты к характеристикам и возвращает новый словарь.
+// Объявленные характеристики ограничиваются своими границами,
+// необъявленные применяются как есть, чтобы ни один эффект не терялся молча.
+func (s Story) ApplyEffects(stats map[string]int, effects map[string]int) map[string]int {
+	out := make(map[string]int, len(stats)+len(effects))
+	for k, v := range stats {
+		out[k] = v
+	}
+	for k, delta := range effects {
+		v := out[k] + delta
+		if d, ok := s.Stat(k); ok {
+			v = d.Clamp(v)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// Public возвращает копию манифеста для показа игроку: без скрытых характеристик.
+func (s Story) Public() Story {
+	out := s
+	out.Stats = make([]StatDef, 0, len(s.Stats))
+	for _, d := range s.Stats {
+		if d.IsVisible() {
+			out.Stats = append(out.Stats, d)
+		}
+	}
+	return out
+}
+
+// VisibleStats возвращает только те характеристики, которые можно показать игроку.
+func (s Story) VisibleStats(stats map[string]int) map[string]int {
+	out := make(map[string]int, len(stats))
+	for k, v := range stats {
+		if d, ok := s.Stat(k); ok && !d.IsVisible() {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// VisibleEffects возвращает только изменения характеристик, которые можно показать игроку.
+func (s Story) VisibleEffects(effects []AppliedEffect) []AppliedEffect {
+	out := make([]AppliedEffect, 0, len(effects))
+	for _, e := range effects {
+		if d, ok := s.Stat(e.Stat); ok && !d.IsVisible() {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// StatDeltas возвращает изменения характеристик между двумя состояниями (after - before).
+// Характеристики без изменений не попадают в результат.
+func StatDeltas(before, after map[string]int) map[string]int {
+	deltas := map[string]int{}
+	for k, v := range after {
+		if d := v - before[k]; d != 0 {
+			deltas[k] = d
+		}
+	}
+	for k, v := range before {
+		if _, ok := after[k]; !ok && v != 0 {
+			deltas[k] = -v
+		}
+	}
+	return deltas
+}
@@ -0,0 +1,384 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Шаблоны текста сцен и выборов.
+//
+// Текст может содержать вставки в двойных фигурных скобках:
+//
+//	{{player}}                 — имя игрока
+//	{{honor}}                  — текущее значение характеристики honor
+//	{{if honor >= 3}}...{{end}} — фрагмент, который показывается при выполнении условия
+//	{{if spared_monk}}...{{else}}...{{end}} — условие на флаг с альтернативой
+//
+// Условия записываются так же, как Condition.String(): сравнения характеристик,
+// флаги, "not флаг", "and", "or" и скобки. Шаблон не может вызывать код
+// и обращаться к чему-либо, кроме имени игрока, характеристик и флагов.
+
+// ErrTemplate — текст содержит некорректную вставку.
+var ErrTemplate = errors.New("invalid template")
+
+// PlayerVar — имя вставки с именем игрока; характеристику с таким именем вставить нельзя.
+const PlayerVar = "player"
+
+// TextVars — данные, которые подставляются в шаблон.
+type TextVars struct {
+	Player string // имя игрока
+	Save   Save   // характеристики и флаги
+}
+
+// Template — разобранный шаблон текста.
+type Template struct {
+	nodes []tmplNode
+}
+
+// tmplNode — литерал, вставка или условный фрагмент шаблона.
+type tmplNode struct {
+	text   string     // литеральный текст
+	player bool       // {{player}}
+	stat   string     // {{<характеристика>}}
+	cond   *Condition // {{if ...}}
+	then   []tmplNode
+	els    []tmplNode
+}
+
+// ParseTemplate разбирает текст с вставками. Текст без "{{" — корректный шаблон из одного литерала.
+func ParseTemplate(text string) (*Template, error) {
+	p := tmplParser{src: text}
+	nodes, end, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if end != "" {
+		return nil, fmt.Errorf("%w: unexpected {{%s}}", ErrTemplate, end)
+	}
+	return &Template{nodes: nodes}, nil
+}
+
+// Render подставляет данные в шаблон.
+func (t *Template) Render(vars TextVars) string {
+	var b strings.Builder
+	renderNodes(&b, t.nodes, vars)
+	return b.String()
+}
+
+func renderNodes(b *strings.Builder, nodes []tmplNode, vars TextVars) {
+	for _, n := range nodes {
+		switch {
+		case n.cond != nil:
+			if n.cond.Met(vars.Save) {
+				renderNodes(b, n.then, vars)
+			} else {
+				renderNodes(b, n.els, vars)
+			}
+		case n.player:
+			b.WriteString(vars.Player)
+		case n.stat != "":
+			b.WriteString(strconv.Itoa(vars.Save.Stats[n.stat]))
+		default:
+			b.WriteString(n.text)
+		}
+	}
+}
+
+// Stats возвращает характеристики, которые шаблон вставляет в текст.
+func (t *Template) Stats() []string {
+	var stats []string
+	walkNodes(t.nodes, func(n tmplNode) {
+		if n.stat != "" {
+			stats = append(stats, n.stat)
+		}
+	})
+	return stats
+}
+
+// Conditions возвращает условия всех фрагментов {{if}} шаблона.
+func (t *Template) Conditions() []Condition {
+	var conds []Condition
+	walkNodes(t.nodes, func(n tmplNode) {
+		if n.cond != nil {
+			conds = append(conds, *n.cond)
+		}
+	})
+	return conds
+}
+
+func walkNodes(nodes []tmplNode, fn func(tmplNode)) {
+	for _, n := range nodes {
+		fn(n)
+		walkNodes(n.then, fn)
+		walkNodes(n.els, fn)
+	}
+}
+
+// RenderText разбирает и отрисовывает text. Некорректный шаблон возвращается
+// как есть: истории проверяются при загрузке, и ошибка шаблона не должна
+// превращаться в ошибку запроса.
+func RenderText(text string, vars TextVars) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	t, err := ParseTemplate(text)
+	if err != nil {
+		return text
+	}
+	return t.Render(vars)
+}
+
+// tmplParser — рекурсивный разбор шаблона.
+type tmplParser struct {
+	src string
+	pos int
+}
+
+// parse читает узлы до конца текста или до {{else}}/{{end}}; возвращает встреченное ключевое слово.
+func (p *tmplParser) parse() ([]tmplNode, string, error) {
+	var nodes []tmplNode
+	for p.pos < len(p.src) {
+		// 1. Литерал до следующей вставки
+		i := strings.Index(p.src[p.pos:], "{{")
+		if i < 0 {
+			nodes = append(nodes, tmplNode{text: p.src[p.pos:]})
+			p.pos = len(p.src)
+			break
+		}
+		if i > 0 {
+			nodes = append(nodes, tmplNode{text: p.src[p.pos : p.pos+i]})
+		}
+		p.pos += i + 2
+
+		// 2. Содержимое вставки
+		j := strings.Index(p.src[p.pos:], "}}")
+		if j < 0 {
+			return nil, "", fmt.Errorf("%w: unclosed {{", ErrTemplate)
+		}
+		action := strings.TrimSpace(p.src[p.pos : p.pos+j])
+		p.pos += j + 2
+
+		// 3. Вид вставки
+		switch {
+		case action == "else" || action == "end":
+			return nodes, action, nil
+		case action == "if" || strings.HasPrefix(action, "if "):
+			node, err := p.parseIf(strings.TrimSpace(strings.TrimPrefix(action, "if")))
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, node)
+		case action == PlayerVar:
+			nodes = append(nodes, tmplNode{player: true})
+		case isIdent(action):
+			nodes = append(nodes, tmplNode{stat: action})
+		default:
+			return nil, "", fmt.Errorf("%w: unknown action {{%s}}", ErrTemplate, action)
+		}
+	}
+	return nodes, "", nil
+}
+
+// parseIf читает ветви {{if}} до {{end}}.
+func (p *tmplParser) parseIf(expr string) (tmplNode, error) {
+	cond, err := ParseCondition(expr)
+	if err != nil {
+		return tmplNode{}, fmt.Errorf("%w: {{if %s}}: %v", ErrTemplate, expr, err)
+	}
+	node := tmplNode{cond: &cond}
+
+	then, end, err := p.parse()
+	if err != nil {
+		return tmplNode{}, err
+	}
+	node.then = then
+	if end == "else" {
+		els, end2, err := p.parse()
+		if err != nil {
+			return tmplNode{}, err
+		}
+		node.els, end = els, end2
+	}
+	if end != "end" {
+		return tmplNode{}, fmt.Errorf("%w: {{if %s}} without {{end}}", ErrTemplate, expr)
+	}
+	return node, nil
+}
+
+// ParseCondition разбирает условие в записи Condition.String():
+//
+//	honor >= 3
+//	spared_monk and not took_sword
+//	(honor >= 3 or rage < 2) and karma != 0
+//
+// "and" связывает сильнее, чем "or". Идентификатор без сравнения — флаг.
+func ParseCondition(s string) (Condition, error) {
+	toks, err := condTokens(s)
+	if err != nil {
+		return Condition{}, err
+	}
+	p := condParser{toks: toks}
+	c, err := p.or()
+	if err != nil {
+		return Condition{}, err
+	}
+	if p.pos < len(p.toks) {
+		return Condition{}, fmt.Errorf("unexpected %q", p.toks[p.pos])
+	}
+	return c, nil
+}
+
+// condTokens делит условие на скобки, операторы, числа и идентификаторы.
+func condTokens(s string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			toks = append(toks, string(c))
+			i++
+		case strings.ContainsRune("<>=!", rune(c)):
+			j := i + 1
+			if j < len(s) && s[j] == '=' {
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n()<>=!", rune(s[j])) {
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		}
+	}
+	if len(toks) == 0 {
+		return nil, errors.New("empty condition")
+	}
+	return toks, nil
+}
+
+// condParser — разбор условия рекурсивным спуском.
+type condParser struct {
+	toks []string
+	pos  int
+}
+
+func (p *condParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *condParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// or := and ("or" and)*
+func (p *condParser) or() (Condition, error) {
+	first, err := p.and()
+	if err != nil {
+		return Condition{}, err
+	}
+	alts := []Condition{first}
+	for p.peek() == "or" {
+		p.next()
+		c, err := p.and()
+		if err != nil {
+			return Condition{}, err
+		}
+		alts = append(alts, c)
+	}
+	if len(alts) == 1 {
+		return first, nil
+	}
+	return Condition{Any: alts}, nil
+}
+
+// and := atom ("and" atom)*
+func (p *condParser) and() (Condition, error) {
+	first, err := p.atom()
+	if err != nil {
+		return Condition{}, err
+	}
+	all := []Condition{first}
+	for p.peek() == "and" {
+		p.next()
+		c, err := p.atom()
+		if err != nil {
+			return Condition{}, err
+		}
+		all = append(all, c)
+	}
+	if len(all) == 1 {
+		return first, nil
+	}
+	return Condition{All: all}, nil
+}
+
+// atom := "(" or ")" | "not" флаг | характеристика оператор число | флаг
+func (p *condParser) atom() (Condition, error) {
+	t := p.next()
+	switch {
+	case t == "(":
+		c, err := p.or()
+		if err != nil {
+			return Condition{}, err
+		}
+		if p.next() != ")" {
+			return Condition{}, errors.New("missing )")
+		}
+		return c, nil
+	case t == "not":
+		flag := p.next()
+		if !isIdent(flag) {
+			return Condition{}, fmt.Errorf("expected flag after not, got %q", flag)
+		}
+		return Condition{NotFlag: flag}, nil
+	case !isIdent(t):
+		return Condition{}, fmt.Errorf("expected stat or flag, got %q", t)
+	}
+
+	op := p.peek()
+	if !isConditionOp(op) {
+		return Condition{Flag: t}, nil
+	}
+	p.next()
+	v, err := strconv.Atoi(p.next())
+	if err != nil {
+		return Condition{}, fmt.Errorf("%s %s: expected integer", t, op)
+	}
+	return Condition{Stat: t, Op: op, Value: v}, nil
+}
+
+func isConditionOp(s string) bool {
+	for _, op := range ConditionOps {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdent сообщает, годится ли строка в имя характеристики или флага:
+// буквы, цифры и "_", не начиная с цифры; ключевые слова условий запрещены.
+func isIdent(s string) bool {
+	if s == "" || s == "and" || s == "or" || s == "not" || s == "if" || s == "else" || s == "end" {
+		return false
+	}
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
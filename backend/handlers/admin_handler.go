@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"blood-on-maple-leaves/backend/internal/problem"
+	"blood-on-maple-leaves/backend/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxSourceBytes ограничивает размер тела с YAML манифеста или сцены.
+const maxSourceBytes = 1 << 20
+
+// yamlContentType — медиатип исходников историй в запросах и ответах.
+const yamlContentType = "application/yaml; charset=utf-8"
+
+// AdminHandler отвечает за HTTP-эндпоинты редактирования историй:
+// черновик, его проверку и публикацию. Маршруты закрыты AdminMiddleware.
+// Манифест и сцены передаются в исходном YAML — так же, как они лежат в файлах каталога.
+type AdminHandler struct {
+	Authoring *service.AuthoringService
+	GameSvc   *service.GameService
+}
+
+// NewAdminHandler создаёт AdminHandler с внедрёнными AuthoringService и GameService.
+func NewAdminHandler(as *service.AuthoringService, gs *service.GameService) *AdminHandler {
+	return &AdminHandler{Authoring: as, GameSvc: gs}
+}
+
+// Routes регистрирует маршруты внутри /admin/stories/{story}.
+func (h *AdminHandler) Routes(r chi.Router) {
+	r.Get("/draft/manifest", h.GetManifest)
+	r.Put("/draft/manifest", h.PutManifest)
+	r.Get("/draft/scenes", h.ListScenes)
+	r.Post("/draft/scenes/{id}", h.CreateScene)
+	r.Get("/draft/scenes/{id}", h.GetScene)
+	r.Put("/draft/scenes/{id}", h.UpdateScene)
+	r.Delete("/draft/scenes/{id}", h.DeleteScene)
+	r.Post("/draft/validate", h.Validate)
+	r.Post("/draft/checkout", h.Checkout)
+	r.Post("/publish", h.Publish)
+	r.Get("/versions", h.Versions)
+	r.Get("/saves/outdated", h.OutdatedSaves)
+}
+
+// GetManifest обрабатывает GET /admin/stories/{story}/draft/manifest: YAML манифеста черновика.
+func (h *AdminHandler) GetManifest(w http.ResponseWriter, r *http.Request) {
+	manifest, err := h.Authoring.Manifest(r.Context(), chi.URLParam(r, "story"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeSource(w, manifest)
+}
+
+// PutManifest обрабатывает PUT /admin/stories/{story}/draft/manifest.
+// Создаёт черновик или заменяет его манифест; отвечает 204.
+func (h *AdminHandler) PutManifest(w http.ResponseWriter, r *http.Request) {
+	source, ok := readSource(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Authoring.PutManifest(r.Context(), chi.URLParam(r, "story"), source); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListScenes обрабатывает GET /admin/stories/{story}/draft/scenes:
+//
+//	[ { "id": "intro", "updated_at": "..." } ]
+func (h *AdminHandler) ListScenes(w http.ResponseWriter, r *http.Request) {
+	scenes, err := h.Authoring.Scenes(r.Context(), chi.URLParam(r, "story"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scenes)
+}
+
+// GetScene обрабатывает GET /admin/stories/{story}/draft/scenes/{id}: YAML сцены черновика.
+func (h *AdminHandler) GetScene(w http.ResponseWriter, r *http.Request) {
+	scene, err := h.Authoring.Scene(r.Context(), chi.URLParam(r, "story"), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Last-Modified", scene.UpdatedAt.UTC().Format(http.TimeFormat))
+	writeSource(w, scene.Source)
+}
+
+// CreateScene обрабатывает POST /admin/stories/{story}/draft/scenes/{id}.
+// Тело — YAML сцены; отвечает 201 с { "id", "updated_at" } или 409 с кодом scene_exists.
+func (h *AdminHandler) CreateScene(w http.ResponseWriter, r *http.Request) {
+	source, ok := readSource(w, r)
+	if !ok {
+		return
+	}
+	scene, err := h.Authoring.CreateScene(r.Context(), chi.URLParam(r, "story"), chi.URLParam(r, "id"), source)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(scene)
+}
+
+// UpdateScene обрабатывает PUT /admin/stories/{story}/draft/scenes/{id}: заменяет сцену черновика.
+func (h *AdminHandler) UpdateScene(w http.ResponseWriter, r *http.Request) {
+	source, ok := readSource(w, r)
+	if !ok {
+		return
+	}
+	scene, err := h.Authoring.UpdateScene(r.Context(), chi.URLParam(r, "story"), chi.URLParam(r, "id"), source)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scene)
+}
+
+// DeleteScene обрабатывает DELETE /admin/stories/{story}/draft/scenes/{id}; отвечает 204.
+func (h *AdminHandler) DeleteScene(w http.ResponseWriter, r *http.Request) {
+	if err := h.Authoring.DeleteScene(r.Context(), chi.URLParam(r, "story"), chi.URLParam(r, "id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Validate обрабатывает POST /admin/stories/{story}/draft/validate.
+// Возвращает отчёт проверки, как у storycheck: { "issues": [...] }.
+func (h *AdminHandler) Validate(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Authoring.Validate(r.Context(), chi.URLParam(r, "story"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if report.Issues == nil {
+		report.Issues = []service.Issue{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// Checkout обрабатывает POST /admin/stories/{story}/draft/checkout:
+// заменяет черновик историей, которая сейчас в игре; отвечает 204.
+func (h *AdminHandler) Checkout(w http.ResponseWriter, r *http.Request) {
+	if err := h.Authoring.Checkout(r.Context(), chi.URLParam(r, "story")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Publish обрабатывает POST /admin/stories/{story}/publish.
+// Отвечает 201 с опубликованной версией или 422 с кодом draft_invalid и списком issues.
+func (h *AdminHandler) Publish(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := playerIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	version, err := h.Authoring.Publish(r.Context(), chi.URLParam(r, "story"), adminID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(version)
+}
+
+// Versions обрабатывает GET /admin/stories/{story}/versions: опубликованные версии, новые первыми.
+func (h *AdminHandler) Versions(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.Authoring.Versions(r.Context(), chi.URLParam(r, "story"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// OutdatedSaves обрабатывает GET /admin/stories/{story}/saves/outdated:
+// сколько слотов сохранено на прежних версиях истории и какие из них миграции не переносят.
+//
+//	{ "story_id": "...", "version": 3, "outdated": 12,
+//	  "failed": [ { "save_id": "...", "player_id": "...", "slot": "main",
+//	                "story_version": 2, "scene_id": "old_bridge" } ] }
+func (h *AdminHandler) OutdatedSaves(w http.ResponseWriter, r *http.Request) {
+	report, err := h.GameSvc.MigrationReport(r.Context(), chi.URLParam(r, "story"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// readSource читает тело запроса с YAML не длиннее maxSourceBytes.
+func readSource(w http.ResponseWriter, r *http.Request) (string, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSourceBytes))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		problem.Error(w, http.StatusRequestEntityTooLarge, "body_too_large", "source is larger than 1 MiB")
+		return "", false
+	}
+	if err != nil {
+		badRequest(w, "invalid_body", "cannot read request body")
+		return "", false
+	}
+	return string(data), true
+}
+
+// writeSource отдаёт исходный YAML.
+func writeSource(w http.ResponseWriter, source string) {
+	w.Header().Set("Content-Type", yamlContentType)
+	io.WriteString(w, source)
+}
@@ -19,20 +19,25 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// RefreshRequest — форма запроса для /token/refresh и /logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
 // SignupHandler возвращает http.HandlerFunc, замыкая authSvc
 func SignupHandler(authSvc *service.AuthService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// 1. Распарсить тело запроса
 		var req SignupRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			badRequest(w, "invalid_json", "request body is not valid JSON")
 			return
 		}
 
 		// 2. Вызвать сервис регистрации
 		tokens, err := authSvc.Signup(r.Context(), req.Username, req.Password)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
@@ -49,14 +54,14 @@ func LoginHandler(authSvc *service.AuthService) http.HandlerFunc {
 		// 1. Распарсить тело запроса
 		var req LoginRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+			badRequest(w, "invalid_json", "request body is not valid JSON")
 			return
 		}
 
 		// 2. Вызвать сервис логина
 		tokens, err := authSvc.Login(r.Context(), req.Username, req.Password)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			writeError(w, err)
 			return
 		}
 
@@ -65,3 +70,67 @@ func LoginHandler(authSvc *service.AuthService) http.HandlerFunc {
 		json.NewEncoder(w).Encode(tokens)
 	}
 }
+
+// RefreshHandler возвращает http.HandlerFunc для POST /token/refresh:
+// обменивает refresh-токен на новую пару. Старый refresh-токен больше не действует;
+// повторное его предъявление отзывает все токены этого входа
+// (401 с кодом refresh_token_reused).
+func RefreshHandler(authSvc *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 1. Распарсить тело запроса
+		var req RefreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			badRequest(w, "invalid_json", "request body is not valid JSON")
+			return
+		}
+
+		// 2. Обменять токен
+		tokens, err := authSvc.Refresh(r.Context(), req.RefreshToken)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// 3. Ответить JSON-ом с новой парой токенов
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tokens)
+	}
+}
+
+// LogoutHandler возвращает http.HandlerFunc для POST /logout:
+// отзывает переданный refresh-токен вместе с токенами того же входа и отвечает 204.
+// Access-токен не нужен: к моменту выхода он мог уже истечь.
+func LogoutHandler(authSvc *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 1. Распарсить тело запроса
+		var req RefreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			badRequest(w, "invalid_json", "request body is not valid JSON")
+			return
+		}
+
+		// 2. Отозвать токен
+		if err := authSvc.Logout(r.Context(), req.RefreshToken); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// LogoutAllHandler возвращает http.HandlerFunc для POST /logout/all:
+// отзывает все refresh-токены текущего игрока и отвечает 204.
+// Маршрут закрыт AuthMiddleware.
+func LogoutAllHandler(authSvc *service.AuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		playerID, ok := playerIDFromRequest(w, r)
+		if !ok {
+			return
+		}
+		if err := authSvc.LogoutAll(r.Context(), playerID.String()); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
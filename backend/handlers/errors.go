@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"blood-on-maple-leaves/backend/internal/problem"
+	"blood-on-maple-leaves/backend/service"
+)
+
+// errorMapping связывает ошибку сервисного слоя с HTTP-статусом и постоянным кодом.
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorMappings — таблица известных ошибок; проверяется по порядку через errors.Is.
+// Коды — часть контракта API: клиенты различают ошибки по ним, поэтому их нельзя менять.
+var errorMappings = []errorMapping{
+	{service.ErrStoryNotFound, http.StatusNotFound, "story_not_found"},
+	{service.ErrSceneNotFound, http.StatusNotFound, "scene_not_found"},
+	{service.ErrInvalidChoice, http.StatusBadRequest, "invalid_choice"},
+	{service.ErrGameNotStarted, http.StatusConflict, "game_not_started"},
+	{service.ErrRunFinished, http.StatusConflict, "run_finished"},
+	{service.ErrInvalidSlot, http.StatusBadRequest, "invalid_slot"},
+	{service.ErrSlotNotFound, http.StatusNotFound, "slot_not_found"},
+	{service.ErrSnapshotNotFound, http.StatusNotFound, "snapshot_not_found"},
+	{service.ErrRewindForbidden, http.StatusForbidden, "rewind_forbidden"},
+	{service.ErrInvalidInput, http.StatusBadRequest, "invalid_input"},
+	{service.ErrUsernameTaken, http.StatusConflict, "username_taken"},
+	{service.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{service.ErrInvalidRefreshToken, http.StatusUnauthorized, "invalid_refresh_token"},
+	{service.ErrRefreshTokenReused, http.StatusUnauthorized, "refresh_token_reused"},
+	{service.ErrPlayerNotFound, http.StatusNotFound, "player_not_found"},
+	{service.ErrDraftNotFound, http.StatusNotFound, "draft_not_found"},
+	{service.ErrSceneExists, http.StatusConflict, "scene_exists"},
+	{service.ErrRedirectLoop, http.StatusInternalServerError, "redirect_loop"},
+}
+
+// writeError — единая точка перевода ошибок в ответы application/problem+json.
+// Неизвестные ошибки логируются и отдаются как 500 без подробностей,
+// чтобы внутренние сообщения (SQL, пути к файлам) не уходили клиенту.
+func writeError(w http.ResponseWriter, err error) {
+	problem.Write(w, problemFor(err))
+}
+
+// problemFor подбирает Problem для ошибки.
+func problemFor(err error) problem.Problem {
+	// 1. Типизированные ошибки с дополнительными полями
+	var wrongScene *service.WrongSceneError
+	if errors.As(err, &wrongScene) {
+		p := problem.New(http.StatusConflict, "wrong_scene", err.Error())
+		p.Extra = map[string]any{"current_scene_id": wrongScene.CurrentSceneID}
+		return p
+	}
+	var locked *service.ChoiceLockedError
+	if errors.As(err, &locked) {
+		p := problem.New(http.StatusForbidden, "choice_locked", err.Error())
+		p.Extra = map[string]any{"choice_id": locked.ChoiceID, "reason": locked.Reason}
+		return p
+	}
+	var outdated *service.SaveOutdatedError
+	if errors.As(err, &outdated) {
+		p := problem.New(http.StatusConflict, "save_outdated", err.Error())
+		p.Extra = map[string]any{"slot": outdated.Slot, "scene_id": outdated.SceneID, "story_version": outdated.FromVersion}
+		return p
+	}
+	var invalid *service.DraftInvalidError
+	if errors.As(err, &invalid) {
+		p := problem.New(http.StatusUnprocessableEntity, "draft_invalid", err.Error())
+		p.Extra = map[string]any{"issues": invalid.Report.Issues}
+		return p
+	}
+
+	// 2. Простые ошибки-сигналы
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return problem.New(m.status, m.code, err.Error())
+		}
+	}
+
+	// 3. Всё остальное — внутренняя ошибка
+	log.Printf("internal error: %v", err)
+	return problem.New(http.StatusInternalServerError, "internal", "")
+}
+
+// badRequest — ответ 400 на некорректный запрос (тело, параметры пути или запроса).
+func badRequest(w http.ResponseWriter, code, detail string) {
+	problem.Error(w, http.StatusBadRequest, code, detail)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"blood-on-maple-leaves/backend/internal/problem"
+	"blood-on-maple-leaves/backend/service"
+)
+
+func TestWriteError(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"scene", fmt.Errorf("load: %w", service.ErrSceneNotFound), http.StatusNotFound, "scene_not_found"},
+		{"choice", fmt.Errorf("%w: x", service.ErrInvalidChoice), http.StatusBadRequest, "invalid_choice"},
+		{"taken", service.ErrUsernameTaken, http.StatusConflict, "username_taken"},
+		{"wrong scene", &service.WrongSceneError{SceneID: "a", CurrentSceneID: "b"}, http.StatusConflict, "wrong_scene"},
+		{"locked", &service.ChoiceLockedError{ChoiceID: "c", Reason: "requires honor >= 3"}, http.StatusForbidden, "choice_locked"},
+		{"draft invalid", &service.DraftInvalidError{Report: service.StoryReport{Issues: []service.Issue{{Severity: service.SeverityError, Message: "unreachable"}}}}, http.StatusUnprocessableEntity, "draft_invalid"},
+		{"save outdated", &service.SaveOutdatedError{Slot: "main", SceneID: "gate", FromVersion: 1, ToVersion: 2}, http.StatusConflict, "save_outdated"},
+		{"scene exists", service.ErrSceneExists, http.StatusConflict, "scene_exists"},
+		{"refresh reused", service.ErrRefreshTokenReused, http.StatusUnauthorized, "refresh_token_reused"},
+		{"invalid refresh", service.ErrInvalidRefreshToken, http.StatusUnauthorized, "invalid_refresh_token"},
+		{"redirect loop", &service.RedirectLoopError{Path: []string{"a", "b", "a"}}, http.StatusInternalServerError, "redirect_loop"},
+		{"internal", errors.New("connection refused"), http.StatusInternalServerError, "internal"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeError(rec, tc.err)
+
+			if rec.Code != tc.status {
+				t.Errorf("status = %d, want %d", rec.Code, tc.status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != problem.ContentType {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["code"] != tc.code {
+				t.Errorf("code = %v, want %s", body["code"], tc.code)
+			}
+			if tc.code == "wrong_scene" && body["current_scene_id"] != "b" {
+				t.Errorf("current_scene_id = %v", body["current_scene_id"])
+			}
+			if issues, _ := body["issues"].([]any); tc.code == "draft_invalid" && len(issues) != 1 {
+				t.Errorf("issues = %v", body["issues"])
+			}
+			if tc.code == "internal" && body["detail"] != nil {
+				t.Errorf("internal error leaked detail %v", body["detail"])
+			}
+		})
+	}
+}
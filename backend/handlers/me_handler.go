@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"blood-on-maple-leaves/backend/internal/problem"
 	"blood-on-maple-leaves/backend/middleware"
 	"blood-on-maple-leaves/backend/service"
 )
@@ -16,14 +17,14 @@ func MeHandler(authSvc *service.AuthService) http.HandlerFunc {
 		uidVal := r.Context().Value(middleware.ContextUserID)
 		userID, ok := uidVal.(string)
 		if !ok || userID == "" {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			problem.Error(w, http.StatusUnauthorized, "unauthorized", "")
 			return
 		}
 
 		// 2. Получить данные игрока по ID
 		player, err := authSvc.PlayerRepo.GetByID(r.Context(), userID)
 		if err != nil {
-			http.Error(w, "user not found", http.StatusNotFound)
+			writeError(w, err)
 			return
 		}
 
@@ -32,3 +33,38 @@ func MeHandler(authSvc *service.AuthService) http.HandlerFunc {
 		json.NewEncoder(w).Encode(player)
 	}
 }
+
+// LocaleRequest описывает входной JSON для PUT /me/locale.
+type LocaleRequest struct {
+	Locale string `json:"locale"` // тег BCP 47; пусто — язык по Accept-Language
+}
+
+// SetLocaleHandler обрабатывает PUT /me/locale: сохраняет язык, на котором
+// игрок читает истории. Если история на этот язык не переведена, текст
+// отдаётся по Accept-Language. Отвечает {"locale": "en"}.
+func SetLocaleHandler(gs *service.GameService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 1. Разобрать тело запроса
+		var req LocaleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			badRequest(w, "invalid_json", "request body is not valid JSON")
+			return
+		}
+
+		// 2. Получить playerID из контекста
+		playerID, ok := playerIDFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		// 3. Сохранить язык в каноническом виде
+		locale, err := gs.SetPlayerLocale(r.Context(), playerID, req.Locale)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(LocaleRequest{Locale: locale})
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"blood-on-maple-leaves/backend/internal/problem"
+	"blood-on-maple-leaves/backend/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// SaveHandler отвечает за HTTP-эндпоинты работы со слотами сохранений.
+type SaveHandler struct {
+	GameSvc *service.GameService
+}
+
+// NewSaveHandler создаёт SaveHandler с внедрённым GameService.
+func NewSaveHandler(gs *service.GameService) *SaveHandler {
+	return &SaveHandler{GameSvc: gs}
+}
+
+// ListSaves обрабатывает GET /stories/{story}/saves.
+// Возвращает слоты игрока в истории, недавно игранные первыми:
+//
+//	[
+//	  { "slot": "main", "name": "main", "active": true, "scene_id": "...",
+//	    "stats": {...}, "finished": false, "last_played": "..." }
+//	]
+func (h *SaveHandler) ListSaves(w http.ResponseWriter, r *http.Request) {
+	storyID, ok := storyIDFromRequest(w, r, h.GameSvc)
+	if !ok {
+		return
+	}
+	playerID, ok := playerIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	slots, err := h.GameSvc.ListSlots(r.Context(), playerID, storyID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(slots)
+}
+
+// LoadSave обрабатывает POST /stories/{story}/saves/{slot}/load.
+// Делает слот активным и возвращает его состояние в формате GameResponse.
+func (h *SaveHandler) LoadSave(w http.ResponseWriter, r *http.Request) {
+	storyID, ok := storyIDFromRequest(w, r, h.GameSvc)
+	if !ok {
+		return
+	}
+	playerID, ok := playerIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	save, err := h.GameSvc.LoadSlot(r.Context(), playerID, storyID, chi.URLParam(r, "slot"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeGame(w, h.GameSvc, save, localeFor(w, r, h.GameSvc, playerID, storyID), http.StatusOK)
+}
+
+// RenameSaveRequest описывает входной JSON для PATCH /stories/{story}/saves/{slot}.
+type RenameSaveRequest struct {
+	Name string `json:"name"`
+}
+
+// RenameSave обрабатывает PATCH /stories/{story}/saves/{slot}: меняет отображаемое имя слота.
+func (h *SaveHandler) RenameSave(w http.ResponseWriter, r *http.Request) {
+	var req RenameSaveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		badRequest(w, "invalid_json", "request body is not valid JSON")
+		return
+	}
+
+	storyID, ok := storyIDFromRequest(w, r, h.GameSvc)
+	if !ok {
+		return
+	}
+	playerID, ok := playerIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.GameSvc.RenameSlot(r.Context(), playerID, storyID, chi.URLParam(r, "slot"), req.Name); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteSave обрабатывает DELETE /stories/{story}/saves/{slot}: удаляет слот со всей историей снимков.
+func (h *SaveHandler) DeleteSave(w http.ResponseWriter, r *http.Request) {
+	storyID, ok := storyIDFromRequest(w, r, h.GameSvc)
+	if !ok {
+		return
+	}
+	playerID, ok := playerIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.GameSvc.DeleteSlot(r.Context(), playerID, storyID, chi.URLParam(r, "slot")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// History обрабатывает GET /stories/{story}/saves/history?slot=&limit=&offset=.
+// Возвращает снимки слота (по умолчанию активного), новые первыми,
+// с изменениями характеристик относительно предыдущего снимка:
+//
+//	[
+//	  { "id": "...", "scene_id": "...", "stats": {...}, "deltas": { "rage": 1 },
+//	    "flags": [...], "finished": false, "created_at": "..." }
+//	]
+func (h *SaveHandler) History(w http.ResponseWriter, r *http.Request) {
+	storyID, ok := storyIDFromRequest(w, r, h.GameSvc)
+	if !ok {
+		return
+	}
+	playerID, ok := playerIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	limit, err := intParam(q.Get("limit"), 20)
+	if err != nil {
+		badRequest(w, "invalid_limit", "limit must be an integer")
+		return
+	}
+	offset, err := intParam(q.Get("offset"), 0)
+	if err != nil {
+		badRequest(w, "invalid_offset", "offset must be an integer")
+		return
+	}
+
+	entries, err := h.GameSvc.History(r.Context(), playerID, storyID, q.Get("slot"), limit, offset)
+	if errors.Is(err, service.ErrGameNotStarted) {
+		problem.Error(w, http.StatusNotFound, "game_not_started", err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// Restore обрабатывает POST /stories/{story}/saves/{id}/restore.
+// Откатывает прохождение к снимку {id} из истории и возвращает новое состояние
+// в формате GameResponse. Если история запрещает откат, отвечает 403.
+func (h *SaveHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	storyID, ok := storyIDFromRequest(w, r, h.GameSvc)
+	if !ok {
+		return
+	}
+	playerID, ok := playerIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	saveID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		badRequest(w, "invalid_save_id", "save ID is not a UUID")
+		return
+	}
+
+	save, err := h.GameSvc.Restore(r.Context(), playerID, storyID, saveID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeGame(w, h.GameSvc, save, localeFor(w, r, h.GameSvc, playerID, storyID), http.StatusOK)
+}
+
+// intParam разбирает целочисленный query-параметр; пустое значение заменяется def.
+func intParam(v string, def int) (int, error) {
+	if v == "" {
+		return def, nil
+	}
+	return strconv.Atoi(v)
+}
@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/internal/problem"
 	"blood-on-maple-leaves/backend/middleware"
 	"blood-on-maple-leaves/backend/service"
 
@@ -21,111 +25,332 @@ func NewSceneHandler(gs *service.GameService) *SceneHandler {
 	return &SceneHandler{GameSvc: gs}
 }
 
-// GetScene обрабатывает GET /scenes/{id}.
+// GetScene обрабатывает GET /stories/{story}/scenes/{id}.
+// Текст отдаётся на языке из настроек игрока (PUT /me/locale), иначе — по заголовку
+// Accept-Language; непереведённые строки — на языке исходного текста.
+// Выбранный язык приходит в заголовке Content-Language.
+// Выборы размечаются по последнему сохранению игрока: недоступные помечаются
+// "locked" с причиной "locked_reason", скрытые не возвращаются.
 // Возвращает JSON вида:
 //
 //	{
-//	  "scene": { ...domain.Scene... },
-//	  "stats": { "honor": X, "rage": Y, "karma": Z }
+//	  "scene": { "id": "...", "text": "...", "choices": [...] },
+//	  "stats": { "honor": X, "rage": Y, "karma": Z },
+//	  "flags": ["spared_monk"]
 //	}
 func (h *SceneHandler) GetScene(w http.ResponseWriter, r *http.Request) {
-	sceneID := chi.URLParam(r, "id")
-
-	// Загружаем сцену
-	scene, err := h.GameSvc.GetScene(sceneID)
-	if err != nil {
-		http.Error(w, "scene not found", http.StatusNotFound)
+	storyID, ok := storyIDFromRequest(w, r, h.GameSvc)
+	if !ok {
+		return
+	}
+	sceneID, ok := sceneIDParam(w, r)
+	if !ok {
 		return
 	}
 
 	// Получаем playerID из контекста (AuthMiddleware)
-	playerIDstr, ok := r.Context().Value(middleware.ContextUserID).(string)
+	playerID, ok := playerIDFromRequest(w, r)
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	playerID, err := uuid.Parse(playerIDstr)
+
+	// Загружаем сцену на языке игрока и размечаем выборы по его сохранению
+	locale := localeFor(w, r, h.GameSvc, playerID, storyID)
+	scene, save, started, err := h.GameSvc.GetSceneForPlayer(r.Context(), playerID, storyID, sceneID, locale)
 	if err != nil {
-		http.Error(w, "invalid user ID", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	// Получаем последнее сохранение
-	save, err := h.GameSvc.GetLatestSave(r.Context(), playerID)
-	// Если сохранения нет или ошибка, просто не добавляем stats
-
-	// Формируем ответ
+	// Формируем ответ; если игра не начата, stats и flags не добавляем
 	resp := map[string]interface{}{"scene": scene}
-	if err == nil {
-		resp["stats"] = map[string]int{
-			"honor": save.Honor,
-			"rage":  save.Rage,
-			"karma": save.Karma,
-		}
+	if started {
+		resp["stats"] = h.GameSvc.VisibleStats(save)
+		resp["flags"] = flagsOf(save)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-// ChooseRequest описывает входной JSON для POST /scenes/{id}/choose.
+// ChooseRequest описывает входной JSON для POST /stories/{story}/scenes/{id}/choose.
 type ChooseRequest struct {
 	ChoiceID string `json:"choice_id"`
 }
 
 // ChooseResponse описывает выходной JSON после применения выбора.
 type ChooseResponse struct {
-	NextSceneID string         `json:"next_scene_id"`
-	Stats       map[string]int `json:"stats"`
+	NextSceneID string                 `json:"next_scene_id"`
+	Stats       map[string]int         `json:"stats"`
+	Flags       []string               `json:"flags"`
+	Finished    bool                   `json:"finished"`
+	Ending      *domain.Ending         `json:"ending,omitempty"`
+	Roll        *domain.Roll           `json:"roll,omitempty"`
+	Effects     []domain.AppliedEffect `json:"effects"`
 }
 
-// Choose обрабатывает POST /scenes/{id}/choose.
+// Choose обрабатывает POST /stories/{story}/scenes/{id}/choose.
 // Принимает выбор игрока, сохраняет новое состояние и возвращает:
 //
 //	{
 //	  "next_scene_id": "...",
-//	  "stats": { "honor": X, "rage": Y, "karma": Z }
+//	  "stats": { "honor": X, "rage": Y, "karma": Z },
+//	  "flags": ["spared_monk"],
+//	  "finished": false
 //	}
+//
+// Если выбор привёл к концовке, finished равно true, а в поле ending
+// приходит её описание. Если исход выбора определён броском (проверка навыка
+// или случайный исход), в поле roll приходит бросок:
+//
+//	"roll": { "kind": "check", "stat": "rage", "dice": 20, "value": 14,
+//	          "modifier": 2, "total": 16, "difficulty": 12, "success": true, "outcome": "success" }
+//
+// Бросок определяется зерном прохождения, поэтому повтор запроса его не меняет.
+// В поле effects по порядку перечислены изменения видимых характеристик — эффекты
+// выбора и on_enter сцен по пути, — каждое со значением до и после:
+//
+//	"effects": [ { "stat": "rage", "op": "set", "before": 4, "after": 0, "delta": -4 } ]
+//
+// Если {id} не совпадает с текущей сценой игрока,
+// отвечает 409 с кодом wrong_scene и текущей сценой в поле current_scene_id;
+// после концовки любой выбор отклоняется с 409 и кодом run_finished.
 func (h *SceneHandler) Choose(w http.ResponseWriter, r *http.Request) {
-	sceneID := chi.URLParam(r, "id")
+	storyID, ok := storyIDFromRequest(w, r, h.GameSvc)
+	if !ok {
+		return
+	}
+	sceneID, ok := sceneIDParam(w, r)
+	if !ok {
+		return
+	}
 
 	// Парсим тело запроса
 	var req ChooseRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		badRequest(w, "invalid_json", "request body is not valid JSON")
 		return
 	}
 	defer r.Body.Close()
 
 	// Получаем playerID из контекста
-	playerIDstr, ok := r.Context().Value(middleware.ContextUserID).(string)
+	playerID, ok := playerIDFromRequest(w, r)
 	if !ok {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	playerID, err := uuid.Parse(playerIDstr)
+
+	// Применяем выбор и сохраняем новое состояние
+	nextID, save, err := h.GameSvc.ChooseForPlayer(r.Context(), playerID, storyID, sceneID, req.ChoiceID)
 	if err != nil {
-		http.Error(w, "invalid user ID", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	// Применяем выбор и сохраняем новое состояние
-	nextID, save, err := h.GameSvc.ChooseForPlayer(r.Context(), playerID, sceneID, req.ChoiceID)
+	ending, err := h.GameSvc.EndingOf(save, localeFor(w, r, h.GameSvc, playerID, storyID))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
 	// Формируем ответ
 	resp := ChooseResponse{
 		NextSceneID: nextID,
-		Stats: map[string]int{
-			"honor": save.Honor,
-			"rage":  save.Rage,
-			"karma": save.Karma,
-		},
+		Stats:       h.GameSvc.VisibleStats(save),
+		Flags:       flagsOf(save),
+		Finished:    save.Finished(),
+		Ending:      ending,
+		Roll:        save.Roll,
+		Effects:     h.GameSvc.VisibleEffects(save),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// GameResponse описывает состояние партии для POST /stories/{story}/games
+// и GET /stories/{story}/games/current.
+type GameResponse struct {
+	StoryID  string         `json:"story_id"`
+	Slot     string         `json:"slot"`
+	SceneID  string         `json:"scene_id"`
+	Stats    map[string]int `json:"stats"`
+	Flags    []string       `json:"flags"`
+	Finished bool           `json:"finished"`
+	Ending   *domain.Ending `json:"ending,omitempty"`
+}
+
+// NewGameRequest описывает необязательный входной JSON для POST /stories/{story}/games.
+type NewGameRequest struct {
+	Slot string `json:"slot"` // пусто — текущий активный слот
+}
+
+// NewGame обрабатывает POST /stories/{story}/games.
+// Создаёт стартовое сохранение игрока в истории; повторный вызов начинает её заново.
+// Прогресс в других историях не затрагивается.
+// Если передан slot, игра начинается в этом слоте и он становится активным.
+// Возвращает JSON вида:
+//
+//	{
+//	  "story_id": "blood-on-maple-leaves",
+//	  "slot": "main",
+//	  "scene_id": "intro",
+//	  "stats": { "honor": 0, "rage": 0, "karma": 0 },
+//	  "flags": [],
+//	  "finished": false
+//	}
+func (h *SceneHandler) NewGame(w http.ResponseWriter, r *http.Request) {
+	storyID, ok := storyIDFromRequest(w, r, h.GameSvc)
+	if !ok {
+		return
+	}
+	playerID, ok := playerIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Тело необязательно: {"slot": "..."} начинает игру в указанном слоте
+	var req NewGameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		badRequest(w, "invalid_json", "request body is not valid JSON")
+		return
+	}
+
+	_, save, err := h.GameSvc.StartNewGame(r.Context(), playerID, storyID, req.Slot)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeGame(w, h.GameSvc, save, localeFor(w, r, h.GameSvc, playerID, storyID), http.StatusCreated)
+}
+
+// CurrentGame обрабатывает GET /stories/{story}/games/current.
+// Возвращает сцену истории, на которой находится игрок, и его характеристики,
+// либо 404 с кодом game_not_started, если игра ещё не начата. Для завершённого прохождения
+// finished равно true, а ending описывает концовку.
+func (h *SceneHandler) CurrentGame(w http.ResponseWriter, r *http.Request) {
+	storyID, ok := storyIDFromRequest(w, r, h.GameSvc)
+	if !ok {
+		return
+	}
+	playerID, ok := playerIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	save, err := h.GameSvc.GetLatestSave(r.Context(), playerID, storyID)
+	if errors.Is(err, service.ErrGameNotStarted) {
+		// Отсутствие игры здесь — не конфликт, а отсутствующий ресурс
+		problem.Error(w, http.StatusNotFound, "game_not_started", err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeGame(w, h.GameSvc, save, localeFor(w, r, h.GameSvc, playerID, storyID), http.StatusOK)
+}
+
+// CompletedRuns обрабатывает GET /runs.
+// Возвращает завершённые прохождения игрока во всех историях, новые первыми;
+// название концовки — на языке, выбранном для игрока в её истории:
+//
+//	[
+//	  { "id": "...", "story_id": "...", "scene_id": "...", "ending": {...}, "stats": {...}, "flags": [...], "finished_at": "..." }
+//	]
+func (h *SceneHandler) CompletedRuns(w http.ResponseWriter, r *http.Request) {
+	playerID, ok := playerIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	runs, err := h.GameSvc.CompletedRuns(r.Context(), playerID, r.Header.Get("Accept-Language"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+// writeGame отвечает состоянием прохождения в формате GameResponse;
+// название концовки — на языке locale.
+func writeGame(w http.ResponseWriter, gs *service.GameService, save domain.Save, locale string, status int) {
+	ending, err := gs.EndingOf(save, locale)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(GameResponse{
+		StoryID:  save.StoryID,
+		Slot:     save.Slot,
+		SceneID:  save.SceneID,
+		Stats:    gs.VisibleStats(save),
+		Flags:    flagsOf(save),
+		Finished: save.Finished(),
+		Ending:   ending,
+	})
+}
+
+// storyIDFromRequest достаёт {story} из пути. Маршруты без {story} (прежние /scenes, /games, /saves)
+// работают с историей по умолчанию. Недопустимый идентификатор — несуществующая история, ответ 404.
+func storyIDFromRequest(w http.ResponseWriter, r *http.Request, gs *service.GameService) (string, bool) {
+	storyID := chi.URLParam(r, "story")
+	if storyID == "" {
+		storyID = gs.DefaultStoryID
+	}
+	if !domain.ValidStoryID(storyID) {
+		problem.Error(w, http.StatusNotFound, "story_not_found", service.ErrStoryNotFound.Error())
+		return "", false
+	}
+	return storyID, true
+}
+
+// localeFor выбирает язык ответа для игрока (настройки игрока, затем Accept-Language)
+// и проставляет заголовки Content-Language и Vary.
+func localeFor(w http.ResponseWriter, r *http.Request, gs *service.GameService, playerID uuid.UUID, storyID string) string {
+	locale := gs.ResolveLocale(r.Context(), playerID, storyID, r.Header.Get("Accept-Language"))
+	w.Header().Set("Content-Language", locale)
+	w.Header().Add("Vary", "Accept-Language")
+	return locale
+}
+
+// sceneIDParam достаёт {id} из пути и проверяет его по грамматике сцен.
+// Недопустимый идентификатор — это несуществующая сцена, поэтому ответ 404.
+func sceneIDParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	sceneID := chi.URLParam(r, "id")
+	if !domain.ValidSceneID(sceneID) {
+		problem.Error(w, http.StatusNotFound, "scene_not_found", service.ErrSceneNotFound.Error())
+		return "", false
+	}
+	return sceneID, true
+}
+
+// playerIDFromRequest достаёт playerID, положенный в контекст AuthMiddleware.
+// При ошибке сам пишет ответ клиенту и возвращает ok == false.
+func playerIDFromRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	playerIDstr, ok := r.Context().Value(middleware.ContextUserID).(string)
+	if !ok {
+		problem.Error(w, http.StatusUnauthorized, "unauthorized", "")
+		return uuid.Nil, false
+	}
+	playerID, err := uuid.Parse(playerIDstr)
+	if err != nil {
+		badRequest(w, "invalid_user_id", "user ID in token is not a UUID")
+		return uuid.Nil, false
+	}
+	return playerID, true
+}
+
+// flagsOf возвращает флаги сохранения; пустой набор кодируется как [], а не null.
+func flagsOf(save domain.Save) []string {
+	if save.Flags == nil {
+		return []string{}
+	}
+	return save.Flags
+}
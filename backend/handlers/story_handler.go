@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"blood-on-maple-leaves/backend/service"
+
+	"github.com/google/uuid"
+)
+
+// StoryHandler отвечает за HTTP-эндпоинты каталога историй.
+type StoryHandler struct {
+	GameSvc *service.GameService
+}
+
+// NewStoryHandler создаёт StoryHandler с внедрённым GameService.
+func NewStoryHandler(gs *service.GameService) *StoryHandler {
+	return &StoryHandler{GameSvc: gs}
+}
+
+// ListStories обрабатывает GET /stories.
+// Возвращает манифесты всех историй каталога, упорядоченные по id:
+//
+//	[
+//	  { "id": "blood-on-maple-leaves", "title": "...", "description": "...",
+//	    "version": 1, "start": "intro", "stats": [...], "allow_rewind": true,
+//	    "locale": "ru", "locales": ["ru", "en"] }
+//	]
+//
+// Название и описание каждой истории — на языке, подобранном по Accept-Language.
+// Скрытые характеристики в stats не попадают.
+func (h *StoryHandler) ListStories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept-Language")
+	json.NewEncoder(w).Encode(h.GameSvc.ListStories(r.Header.Get("Accept-Language")))
+}
+
+// GetStory обрабатывает GET /stories/{story}.
+// Возвращает манифест истории на языке из Accept-Language или 404 с кодом story_not_found.
+func (h *StoryHandler) GetStory(w http.ResponseWriter, r *http.Request) {
+	storyID, ok := storyIDFromRequest(w, r, h.GameSvc)
+	if !ok {
+		return
+	}
+
+	story, err := h.GameSvc.GetStory(storyID, localeFor(w, r, h.GameSvc, uuid.Nil, storyID))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(story)
+}
@@ -0,0 +1,187 @@
+// Package po читает и пишет файлы переводов в формате gettext PO.
+//
+// Поддерживается подмножество, нужное для текстов историй: одиночные строки
+// с msgctxt (ключ строки), комментарии-ссылки "#:", переводческие комментарии "# ",
+// извлечённые комментарии "#." и флаг fuzzy. Множественные формы не используются.
+package po
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Entry — одна запись PO-файла.
+type Entry struct {
+	Context    string   // msgctxt — ключ строки истории (например, intro.choice.attack)
+	ID         string   // msgid — исходный текст
+	Str        string   // msgstr — перевод; пусто — не переведено
+	Fuzzy      bool     // перевод требует проверки и не применяется
+	References []string // "#:" — файлы, откуда взята строка
+	Comments   []string // "#." — пояснения для переводчика
+}
+
+// File — PO-файл: язык из заголовка и записи.
+type File struct {
+	Language string
+	Entries  []Entry
+}
+
+// Write пишет f в формате PO. Заголовок (запись с пустым msgid) формируется из Language.
+func Write(w io.Writer, f File) error {
+	bw := bufio.NewWriter(w)
+
+	// 1. Заголовок
+	fmt.Fprintln(bw, `msgid ""`)
+	fmt.Fprintln(bw, `msgstr ""`)
+	fmt.Fprintln(bw, quote("Language: "+f.Language+"\n"))
+	fmt.Fprintln(bw, quote("MIME-Version: 1.0\n"))
+	fmt.Fprintln(bw, quote("Content-Type: text/plain; charset=UTF-8\n"))
+	fmt.Fprintln(bw, quote("Content-Transfer-Encoding: 8bit\n"))
+
+	// 2. Записи
+	for _, e := range f.Entries {
+		fmt.Fprintln(bw)
+		for _, c := range e.Comments {
+			fmt.Fprintf(bw, "#. %s\n", c)
+		}
+		for _, ref := range e.References {
+			fmt.Fprintf(bw, "#: %s\n", ref)
+		}
+		if e.Fuzzy {
+			fmt.Fprintln(bw, "#, fuzzy")
+		}
+		fmt.Fprintf(bw, "msgctxt %s\n", quote(e.Context))
+		fmt.Fprintf(bw, "msgid %s\n", quote(e.ID))
+		fmt.Fprintf(bw, "msgstr %s\n", quote(e.Str))
+	}
+	return bw.Flush()
+}
+
+// quote кодирует строку как строковый литерал PO.
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// Read разбирает PO-файл. Устаревшие записи ("#~") пропускаются,
+// множественные формы считаются ошибкой.
+func Read(r io.Reader) (File, error) {
+	var (
+		f     File
+		cur   Entry
+		field *string // поле, к которому относятся строки-продолжения
+		seen  bool    // в cur уже есть msgid
+		line  int
+	)
+
+	flush := func() {
+		if !seen {
+			return
+		}
+		if cur.ID == "" && cur.Context == "" {
+			f.Language = headerField(cur.Str, "Language")
+		} else {
+			f.Entries = append(f.Entries, cur)
+		}
+		cur, field, seen = Entry{}, nil, false
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+
+		switch {
+		case text == "" || strings.HasPrefix(text, "#~"):
+			continue
+		case strings.HasPrefix(text, "#"):
+			// Комментарий начинает новую запись, если предыдущая уже закончена
+			if seen && field == &cur.Str {
+				flush()
+			}
+			switch {
+			case strings.HasPrefix(text, "#:"):
+				cur.References = append(cur.References, strings.Fields(text[2:])...)
+			case strings.HasPrefix(text, "#,"):
+				for _, flag := range strings.Split(text[2:], ",") {
+					if strings.TrimSpace(flag) == "fuzzy" {
+						cur.Fuzzy = true
+					}
+				}
+			case strings.HasPrefix(text, "#."):
+				cur.Comments = append(cur.Comments, strings.TrimSpace(text[2:]))
+			}
+			continue
+		case strings.HasPrefix(text, `"`):
+			if field == nil {
+				return File{}, fmt.Errorf("line %d: string without keyword", line)
+			}
+			s, err := unquote(text)
+			if err != nil {
+				return File{}, fmt.Errorf("line %d: %w", line, err)
+			}
+			*field += s
+			continue
+		}
+
+		keyword, rest, _ := strings.Cut(text, " ")
+		s, err := unquote(strings.TrimSpace(rest))
+		if err != nil {
+			return File{}, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch keyword {
+		case "msgctxt":
+			if seen {
+				flush()
+			}
+			cur.Context, field = s, &cur.Context
+		case "msgid":
+			if seen {
+				flush()
+			}
+			cur.ID, field, seen = s, &cur.ID, true
+		case "msgstr":
+			if !seen {
+				return File{}, fmt.Errorf("line %d: msgstr without msgid", line)
+			}
+			cur.Str, field = s, &cur.Str
+		case "msgid_plural":
+			return File{}, fmt.Errorf("line %d: plural forms are not supported", line)
+		default:
+			return File{}, fmt.Errorf("line %d: unknown keyword %q", line, keyword)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return File{}, err
+	}
+	flush()
+	return f, nil
+}
+
+// unquote декодирует строковый литерал PO.
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", errors.New("expected quoted string")
+	}
+	// Экранирование PO совпадает с Go для \n, \t, \", \\
+	out, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %s: %w", s, err)
+	}
+	return out, nil
+}
+
+// headerField достаёт поле заголовка PO ("Language: en").
+func headerField(header, name string) string {
+	for _, l := range strings.Split(header, "\n") {
+		if k, v, ok := strings.Cut(l, ":"); ok && strings.TrimSpace(k) == name {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+package po
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	in := File{Language: "en", Entries: []Entry{
+		{Context: "intro.text", ID: "Строка \"в кавычках\"\nи вторая", Str: "A \"quoted\" line\nand a second", References: []string{"intro.yaml"}},
+		{Context: "intro.choice.go", ID: "Идти", Fuzzy: true, Comments: []string{"глагол"}, References: []string{"intro.yaml"}},
+	}}
+	var buf bytes.Buffer
+	if err := Write(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+	out, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip:\n got %+v\nwant %+v", out, in)
+	}
+}
+
+func TestReadContinuationLines(t *testing.T) {
+	src := `msgid ""
+msgstr ""
+"Language: pt-BR\n"
+
+#, fuzzy, python-format
+msgctxt "intro.text"
+msgid ""
+"Первая "
+"строка"
+msgstr "Primeira "
+"linha"
+
+#~ msgctxt "old.text"
+#~ msgid "удалено"
+#~ msgstr "removed"
+msgctxt "hall.text"
+msgid "Зал"
+msgstr ""
+`
+	f, err := Read(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if f.Language != "pt-BR" || len(f.Entries) != 2 {
+		t.Fatalf("file = %+v", f)
+	}
+	if e := f.Entries[0]; e.ID != "Первая строка" || e.Str != "Primeira linha" || !e.Fuzzy {
+		t.Errorf("entry 0 = %+v", e)
+	}
+	if e := f.Entries[1]; e.Context != "hall.text" || e.Str != "" {
+		t.Errorf("entry 1 = %+v", e)
+	}
+
+	if _, err := Read(strings.NewReader("msgid \"a\"\nmsgid_plural \"b\"\n")); err == nil {
+		t.Error("plural forms should be rejected")
+	}
+}
@@ -0,0 +1,61 @@
+// Package problem пишет ошибки HTTP API в формате RFC 7807 (application/problem+json).
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ContentType — медиатип ответов с описанием проблемы.
+const ContentType = "application/problem+json"
+
+// Problem — тело ответа об ошибке по RFC 7807.
+// Code — стабильный машиночитаемый код, по которому клиент различает ошибки
+// (вместо сравнения текста); Extra — дополнительные поля конкретной проблемы.
+type Problem struct {
+	Type   string         `json:"type"`
+	Title  string         `json:"title"`
+	Status int            `json:"status"`
+	Detail string         `json:"detail,omitempty"`
+	Code   string         `json:"code"`
+	Extra  map[string]any `json:"-"`
+}
+
+// MarshalJSON встраивает Extra на верхний уровень объекта, как требует RFC 7807.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, len(p.Extra)+5)
+	for k, v := range p.Extra {
+		out[k] = v
+	}
+	out["type"] = p.Type
+	out["title"] = p.Title
+	out["status"] = p.Status
+	out["code"] = p.Code
+	if p.Detail != "" {
+		out["detail"] = p.Detail
+	}
+	return json.Marshal(out)
+}
+
+// New собирает Problem; type формируется из кода, title — из HTTP-статуса.
+func New(status int, code, detail string) Problem {
+	return Problem{
+		Type:   "/problems/" + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write отправляет проблему клиенту.
+func Write(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Error — короткая запись для проблем без дополнительных полей.
+func Error(w http.ResponseWriter, status int, code, detail string) {
+	Write(w, New(status, code, detail))
+}
@@ -0,0 +1,654 @@
+package storyimport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"blood-on-maple-leaves/backend/domain"
+)
+
+// Ink читает историю, скомпилированную inklecate или Inky в JSON.
+//
+// Переносится:
+//
+//	=== узел ===                       — сцена
+//	* [Текст] -> узел, + Текст -> узел — выбор, в том числе с условием {honor >= 3}
+//	-> узел без выборов                — выбор с текстом ContinueText
+//	~ honor = honor + 1, ~ rage -= 2   — эффекты
+//	~ spared = true / false            — флаги
+//	VAR honor = 0                      — стартовое значение характеристики
+//	{honor}                            — вставка {{honor}}
+//	# good, # bad, # secret            — категория концовки
+//
+// Стежки, сборки (gather) после выборов, вложенные выборы, функции, туннели,
+// последовательности, списки и условный текст не переносятся и попадают в Issues.
+// Узел без выборов и переходов становится концовкой.
+func Ink(r io.Reader) (Result, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	v, err := decodeInk(dec)
+	if err != nil {
+		return Result{}, fmt.Errorf("ink json: %w", err)
+	}
+	doc, ok := v.(*inkObj)
+	if !ok {
+		return Result{}, errors.New("ink json: expected an object")
+	}
+	rawRoot, ok := doc.vals["root"].([]any)
+	if !ok {
+		return Result{}, errors.New("ink json: no root container; is this compiled ink?")
+	}
+	root := newInkContainer(rawRoot, nil)
+
+	w := &inkWalker{root: root, flags: map[string]bool{}}
+
+	// 1. Глобальные переменные: числа — характеристики, true/false — флаги
+	stats := map[string]int{}
+	if decl := root.named["global decl"]; decl != nil {
+		w.name, w.decl, w.visited = "VAR", stats, map[*inkContainer]bool{}
+		w.walk(decl, &inkFlow{})
+		w.decl = nil
+	}
+
+	// 2. Узлы в порядке исходника
+	knots := map[string]bool{}
+	for _, name := range root.order {
+		if name != "global decl" {
+			knots[name] = true
+		}
+	}
+	w.knots = knots
+	var passages []*passage
+	for _, name := range root.order {
+		if knots[name] {
+			passages = append(passages, w.passage(name, root.named[name]))
+		}
+	}
+
+	// 3. Начало истории: переход из корня или текст корня как отдельная сцена
+	start := ""
+	if len(root.content) > 0 {
+		p := w.passage("(root)", root)
+		if p.text == "" && len(p.links) == 1 && p.links[0].text == ContinueText && p.entry.empty() {
+			start = p.links[0].target
+			w.issues = w.issues[:len(w.issues)-1] // переход из корня — не выбор
+		} else if p.text != "" || len(p.links) > 0 {
+			start = p.name
+			passages = append([]*passage{p}, passages...)
+		}
+	}
+	if len(passages) == 0 {
+		return Result{}, errors.New("ink json: no knots")
+	}
+	if start == "" {
+		start = passages[0].name
+	}
+
+	return build("", start, passages, stats, w.issues), nil
+}
+
+// inkObj — объект JSON с сохранённым порядком ключей: порядок узлов в корне
+// задаёт порядок сцен.
+type inkObj struct {
+	keys []string
+	vals map[string]any
+}
+
+func (o *inkObj) has(key string) bool {
+	_, ok := o.vals[key]
+	return ok
+}
+
+// decodeInk читает значение JSON: массивы — []any, объекты — *inkObj,
+// числа — json.Number.
+func decodeInk(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			v, err := decodeInk(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		_, err := dec.Token()
+		return arr, err
+	case json.Delim('{'):
+		obj := &inkObj{vals: map[string]any{}}
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeInk(dec)
+			if err != nil {
+				return nil, err
+			}
+			key := k.(string)
+			obj.keys = append(obj.keys, key)
+			obj.vals[key] = v
+		}
+		_, err := dec.Token()
+		return obj, err
+	}
+	return tok, nil
+}
+
+// inkContainer — контейнер скомпилированного Ink: последовательность команд
+// и именованные вложенные контейнеры (узлы, выборы c-N, сборки g-N).
+type inkContainer struct {
+	name    string
+	parent  *inkContainer
+	content []any // команды; вложенные контейнеры — *inkContainer
+	named   map[string]*inkContainer
+	order   []string // имена контейнеров, которых нет в content, в порядке исходника
+}
+
+// newInkContainer строит контейнер из массива JSON. Последний элемент массива —
+// null или объект с именованными контейнерами и служебными ключами "#f", "#n".
+func newInkContainer(raw []any, parent *inkContainer) *inkContainer {
+	c := &inkContainer{parent: parent, named: map[string]*inkContainer{}}
+	var meta *inkObj
+	if n := len(raw); n > 0 {
+		switch last := raw[n-1].(type) {
+		case nil:
+			raw = raw[:n-1]
+		case *inkObj:
+			if !last.has("->") && !last.has("*") && !last.has("VAR=") && !last.has("VAR?") && !last.has("^->") && !last.has("temp=") && !last.has("#") {
+				meta, raw = last, raw[:n-1]
+			}
+		}
+	}
+	for _, item := range raw {
+		if arr, ok := item.([]any); ok {
+			sub := newInkContainer(arr, c)
+			if sub.name != "" {
+				c.named[sub.name] = sub
+			}
+			item = sub
+		}
+		c.content = append(c.content, item)
+	}
+	if meta != nil {
+		for _, k := range meta.keys {
+			switch v := meta.vals[k].(type) {
+			case string:
+				if k == "#n" {
+					c.name = v
+				}
+			case []any:
+				sub := newInkContainer(v, c)
+				sub.name = k
+				c.named[k] = sub
+				c.order = append(c.order, k)
+			}
+		}
+	}
+	return c
+}
+
+// resolve находит контейнер по пути Ink относительно команды, лежащей в c.
+// Абсолютный путь ("hallway", "intro.0.c-0") отсчитывается от корня,
+// относительный (".^.c-0") — от c; первый "^" относительного пути ведёт
+// от команды к её контейнеру, то есть к c.
+func (c *inkContainer) resolve(path string) *inkContainer {
+	cur := c
+	comps := strings.Split(path, ".")
+	if strings.HasPrefix(path, ".") {
+		comps = comps[1:]
+		if len(comps) > 0 && comps[0] == "^" {
+			comps = comps[1:]
+		}
+	} else {
+		for cur.parent != nil {
+			cur = cur.parent
+		}
+	}
+	for _, comp := range comps {
+		if cur == nil {
+			return nil
+		}
+		if comp == "^" {
+			cur = cur.parent
+		} else if i, err := strconv.Atoi(comp); err == nil {
+			if i < 0 || i >= len(cur.content) {
+				return nil
+			}
+			cur, _ = cur.content[i].(*inkContainer)
+		} else {
+			cur = cur.named[comp]
+		}
+	}
+	return cur
+}
+
+// inkFlow — то, что собрано при обходе узла или тела выбора.
+type inkFlow struct {
+	text    strings.Builder
+	assign  assignments
+	divert  string // узел, куда ведёт безусловный переход
+	choices []link
+	tags    []string
+}
+
+// inkVar — значение переменной на стеке вычислений.
+type inkVar struct{ name string }
+
+// inkDelta — переменная плюс число: правая часть "~ honor = honor + 1".
+type inkDelta struct {
+	name  string
+	delta int
+}
+
+// inkUnknown — выражение, которое не переносится.
+type inkUnknown struct{}
+
+// internalName — имена служебных контейнеров Ink внутри узла: выборы, сборки,
+// начальный текст выбора и точки возврата.
+var internalName = regexp.MustCompile(`^(c-\d+|g-\d+|s|\$r\d*)$`)
+
+// knotBody — продолжение пути после имени узла, ведущее в его основной текст, а не в стежок.
+var knotBody = regexp.MustCompile(`^\d+(\.|$)`)
+
+// inkWalker обходит команды контейнеров, вычисляя выражения на стеке.
+type inkWalker struct {
+	root     *inkContainer
+	knots    map[string]bool
+	flags    map[string]bool // переменные, объявленные как true/false
+	decl     map[string]int  // не nil — обход объявлений VAR
+	name     string          // текущий узел для сообщений
+	inChoice bool            // обход тела выбора
+	stack    []any
+	str      *strings.Builder // не nil — между "str" и "/str"
+	tag      *strings.Builder // не nil — между "#" и "/#"
+	visited  map[*inkContainer]bool
+	issues   []Issue
+}
+
+func (w *inkWalker) issue(format string, args ...any) {
+	w.issues = append(w.issues, Issue{Passage: w.name, Message: fmt.Sprintf(format, args...)})
+}
+
+func (w *inkWalker) push(v any) { w.stack = append(w.stack, v) }
+
+func (w *inkWalker) pop() any {
+	if len(w.stack) == 0 {
+		return inkUnknown{}
+	}
+	v := w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+	return v
+}
+
+// passage превращает узел в отрывок.
+func (w *inkWalker) passage(name string, c *inkContainer) *passage {
+	w.name, w.stack, w.visited = name, nil, map[*inkContainer]bool{c: true}
+	if c != w.root {
+		for _, sub := range c.order {
+			if !internalName.MatchString(sub) {
+				w.issue("stitch %s is not imported", sub)
+			}
+		}
+	}
+
+	f := &inkFlow{}
+	w.walk(c, f)
+	p := &passage{name: name, text: tidy(f.text.String()), tags: f.tags, links: f.choices, entry: f.assign}
+	if f.divert != "" && len(f.choices) == 0 {
+		p.links = []link{{text: ContinueText, target: f.divert}}
+		w.issue("divert -> %s is imported as a choice %q", f.divert, ContinueText)
+	}
+	return p
+}
+
+// walk выполняет команды контейнера c, накапливая результат в f.
+func (w *inkWalker) walk(c *inkContainer, f *inkFlow) {
+	for _, item := range c.content {
+		switch v := item.(type) {
+		case *inkContainer:
+			if !w.visited[v] {
+				w.visited[v] = true
+				w.walk(v, f)
+			}
+		case json.Number:
+			n, err := strconv.Atoi(v.String())
+			if err != nil {
+				w.issue("non-integer value %s is not imported", v)
+				w.push(inkUnknown{})
+				continue
+			}
+			w.push(n)
+		case bool:
+			w.push(v)
+		case string:
+			w.command(v, f)
+		case *inkObj:
+			w.object(c, v, f)
+		}
+	}
+}
+
+// command выполняет строковую команду: текст "^...", перевод строки или инструкцию.
+func (w *inkWalker) command(cmd string, f *inkFlow) {
+	switch {
+	case strings.HasPrefix(cmd, "^"):
+		switch {
+		case w.str != nil:
+			w.str.WriteString(cmd[1:])
+		case w.tag != nil:
+			w.tag.WriteString(cmd[1:])
+		default:
+			f.text.WriteString(cmd[1:])
+		}
+		return
+	case cmd == "\n":
+		if w.str == nil {
+			f.text.WriteString("\n")
+		}
+		return
+	}
+
+	switch cmd {
+	case "ev", "/ev", "<>", "nop", "done", "end":
+	case "str":
+		w.str = &strings.Builder{}
+	case "/str":
+		if w.str != nil {
+			w.push(w.str.String())
+			w.str = nil
+		}
+	case "#":
+		w.tag = &strings.Builder{}
+	case "/#":
+		if w.tag != nil {
+			f.tags = append(f.tags, strings.TrimSpace(w.tag.String()))
+			w.tag = nil
+		}
+	case "out":
+		switch v := w.pop().(type) {
+		case inkVar:
+			f.text.WriteString("{{" + v.name + "}}")
+		case int:
+			f.text.WriteString(strconv.Itoa(v))
+		case string:
+			f.text.WriteString(v)
+		default:
+			w.issue("printed expression is not imported")
+		}
+	case "pop":
+		w.pop()
+	case "+", "-", "==", "!=", ">", ">=", "<", "<=", "!", "&&", "||":
+		w.op(cmd)
+	default:
+		w.issue("unsupported ink instruction %q", cmd)
+		w.stack = nil
+	}
+}
+
+// object выполняет команду-объект: переход, выбор, переменную или тег.
+func (w *inkWalker) object(c *inkContainer, obj *inkObj, f *inkFlow) {
+	switch {
+	case obj.has("->"):
+		w.divert(c, obj, f)
+	case obj.has("*"):
+		w.choice(c, obj, f)
+	case obj.has("VAR?"):
+		name, _ := obj.vals["VAR?"].(string)
+		w.push(inkVar{name: name})
+	case obj.has("VAR="):
+		name, _ := obj.vals["VAR="].(string)
+		w.assign(name, f)
+	case obj.has("^->"):
+		// адрес возврата для начального текста выбора
+	case obj.has("temp="):
+		w.pop()
+		if name, _ := obj.vals["temp="].(string); !strings.HasPrefix(name, "$r") {
+			w.issue("temporary variable %s is not imported", name)
+		}
+	case obj.has("#"):
+		tag, _ := obj.vals["#"].(string)
+		f.tags = append(f.tags, strings.TrimSpace(tag))
+	case obj.has("CNT?"):
+		w.issue("visit counts are not imported")
+		w.push(inkUnknown{})
+	default:
+		w.issue("unsupported ink construct %s", obj.keys)
+		w.stack = nil
+	}
+}
+
+// divert обрабатывает переход "->".
+func (w *inkWalker) divert(c *inkContainer, obj *inkObj, f *inkFlow) {
+	path, _ := obj.vals["->"].(string)
+	switch {
+	case obj.has("var"):
+		return // возврат из начального текста выбора
+	case obj.has("c"):
+		w.issue("conditional divert -> %s is not imported", path)
+		w.pop()
+		return
+	}
+
+	// 1. Начальный текст выбора: переход внутри "str"
+	if w.str != nil {
+		if target := c.resolve(path); target != nil {
+			str := w.str
+			sub := &inkFlow{}
+			w.str = nil
+			w.walk(target, sub)
+			w.str = str
+			str.WriteString(sub.text.String())
+		}
+		return
+	}
+
+	// 2. Переход в другой узел
+	knot, rest, _ := strings.Cut(path, ".")
+	if w.knots[knot] {
+		if rest != "" && !knotBody.MatchString(rest) {
+			w.issue("divert into stitch %s is imported as a divert to knot %s", path, knot)
+		}
+		if f.divert == "" {
+			f.divert = knot
+		}
+		return
+	}
+
+	// 3. Переход внутри узла: в теле выбора — к сборке, которой в сценах нет
+	target := c.resolve(path)
+	switch {
+	case target == nil:
+		if !w.inChoice {
+			w.issue("cannot resolve divert -> %s", path)
+		}
+	case w.inChoice && strings.HasPrefix(target.name, "g-"):
+		w.issue("gather after a choice is not imported")
+	case !w.visited[target]:
+		w.visited[target] = true
+		w.walk(target, f)
+	}
+}
+
+// choice переносит точку выбора "*": текст и условие берутся со стека,
+// переход и присваивания — из тела выбора.
+func (w *inkWalker) choice(c *inkContainer, obj *inkObj, f *inkFlow) {
+	path, _ := obj.vals["*"].(string)
+	flg := 0
+	if n, ok := obj.vals["flg"].(json.Number); ok {
+		flg, _ = strconv.Atoi(n.String())
+	}
+
+	// 1. Текст и условие: на стеке условие, начальный текст, текст только для выбора
+	var only, start string
+	if flg&0x4 != 0 {
+		only, _ = w.pop().(string)
+	}
+	if flg&0x2 != 0 {
+		start, _ = w.pop().(string)
+	}
+	l := link{text: strings.TrimSpace(start + only)}
+	if flg&0x1 != 0 {
+		if cond, ok := w.cond(w.pop()); ok {
+			l.requires = []domain.Condition{cond}
+		} else {
+			w.issue("condition of choice %q is not imported", l.text)
+		}
+	}
+	if flg&0x8 != 0 {
+		w.issue("invisible default choice is imported as a regular choice")
+	}
+	w.stack = nil
+
+	// 2. Тело выбора
+	body := c.resolve(path)
+	if body == nil {
+		w.issue("cannot resolve choice %q", l.text)
+		return
+	}
+	sub := &inkFlow{}
+	inChoice := w.inChoice
+	w.inChoice = true
+	w.walk(body, sub)
+	w.inChoice = inChoice
+
+	l.target, l.assignments = sub.divert, sub.assign
+	if after := tidy(sub.text.String()); after != "" && after != strings.TrimSpace(start) {
+		w.issue("text shown after choice %q is not imported", l.text)
+	}
+	if len(sub.choices) > 0 {
+		w.issue("choices nested in choice %q are not imported", l.text)
+	}
+	if l.target == "" {
+		w.issue("choice %q does not divert to a knot; skipped", l.text)
+		return
+	}
+	f.choices = append(f.choices, l)
+}
+
+// assign переносит присваивание переменной name значения со стека.
+func (w *inkWalker) assign(name string, f *inkFlow) {
+	v := w.pop()
+	if w.decl != nil {
+		switch v := v.(type) {
+		case int:
+			w.decl[name] = v
+		case bool:
+			w.flags[name] = true
+			if v {
+				w.issue("flag %s starts raised; flags always start lowered", name)
+			}
+		default:
+			w.issue("variable %s is not a number or true/false; not imported", name)
+		}
+		return
+	}
+
+	switch v := v.(type) {
+	case inkDelta:
+		if v.name == name {
+			f.assign.addEffect(name, v.delta)
+			return
+		}
+	case bool:
+		f.assign.setFlag(name, v)
+		return
+	case int:
+		w.issue("~ %s = %d assigns an absolute value; only increments are imported", name, v)
+		return
+	}
+	w.issue("assignment to %s is not imported", name)
+}
+
+// op применяет операцию к значениям на стеке.
+func (w *inkWalker) op(op string) {
+	if op == "!" {
+		c, ok := w.cond(w.pop())
+		if ok {
+			if neg, ok := negate(c); ok {
+				w.push(neg)
+				return
+			}
+		}
+		w.push(inkUnknown{})
+		return
+	}
+
+	b, a := w.pop(), w.pop()
+	bi, bInt := b.(int)
+	switch op {
+	case "+", "-":
+		if op == "-" {
+			bi = -bi
+		}
+		switch a := a.(type) {
+		case int:
+			if bInt {
+				w.push(a + bi)
+				return
+			}
+		case inkVar:
+			if bInt {
+				w.push(inkDelta{name: a.name, delta: bi})
+				return
+			}
+		case inkDelta:
+			if bInt {
+				w.push(inkDelta{name: a.name, delta: a.delta + bi})
+				return
+			}
+		}
+	case "&&", "||":
+		ca, okA := w.cond(a)
+		cb, okB := w.cond(b)
+		if okA && okB {
+			if op == "&&" {
+				w.push(domain.Condition{All: []domain.Condition{ca, cb}})
+			} else {
+				w.push(domain.Condition{Any: []domain.Condition{ca, cb}})
+			}
+			return
+		}
+	default:
+		v, isVar := a.(inkVar)
+		switch {
+		case isVar && bInt:
+			w.push(domain.Condition{Stat: v.name, Op: op, Value: bi})
+			return
+		case isVar && (op == "==" || op == "!="):
+			if raised, ok := b.(bool); ok {
+				if raised == (op == "==") {
+					w.push(domain.Condition{Flag: v.name})
+				} else {
+					w.push(domain.Condition{NotFlag: v.name})
+				}
+				return
+			}
+		}
+	}
+	w.push(inkUnknown{})
+}
+
+// cond превращает значение со стека в условие: переменная-флаг проверяется
+// как флаг, числовая — как "не равна нулю".
+func (w *inkWalker) cond(v any) (domain.Condition, bool) {
+	switch v := v.(type) {
+	case domain.Condition:
+		return v, true
+	case inkVar:
+		if w.flags[v.name] {
+			return domain.Condition{Flag: v.name}, true
+		}
+		return domain.Condition{Stat: v.name, Op: "!=", Value: 0}, true
+	}
+	return domain.Condition{}, false
+}
@@ -0,0 +1,270 @@
+// Package storyimport переводит черновики историй из Twine (Twee 3 с разметкой Harlowe)
+// и скомпилированного Ink JSON в сцены и манифест проекта.
+//
+// Отрывки Twine и узлы Ink становятся сценами, ссылки и выборы — выборами,
+// простые присваивания переменных — эффектами и флагами. Всё, что перенести
+// нельзя или можно только приблизительно, попадает в Result.Issues: импорт
+// не останавливается на первой проблеме, чтобы автор увидел их все сразу.
+package storyimport
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"blood-on-maple-leaves/backend/domain"
+)
+
+// ContinueText — текст выбора, которым заменяется безусловный переход
+// ((goto:) в Harlowe, "-> узел" в Ink): в сценах проекта переход всегда делает игрок.
+const ContinueText = "Далее"
+
+// Issue — конструкция исходника, которую не удалось перенести точно.
+type Issue struct {
+	Passage string // имя отрывка Twine или узла Ink; пусто — история целиком
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Passage == "" {
+		return i.Message
+	}
+	return fmt.Sprintf("%s: %s", i.Passage, i.Message)
+}
+
+// Result — итог импорта: манифест, сцены в порядке исходника и проблемы.
+type Result struct {
+	Story  domain.Story
+	Scenes []domain.Scene
+	Issues []Issue
+}
+
+// assignments — присваивания, которые переносятся в эффекты и флаги.
+type assignments struct {
+	effects    map[string]int
+	setFlags   []string
+	clearFlags []string
+}
+
+func (a *assignments) addEffect(stat string, delta int) {
+	if a.effects == nil {
+		a.effects = map[string]int{}
+	}
+	a.effects[stat] += delta
+}
+
+func (a *assignments) setFlag(flag string, raised bool) {
+	if raised {
+		a.setFlags = append(a.setFlags, flag)
+	} else {
+		a.clearFlags = append(a.clearFlags, flag)
+	}
+}
+
+func (a assignments) empty() bool {
+	return len(a.effects) == 0 && len(a.setFlags) == 0 && len(a.clearFlags) == 0
+}
+
+// passage — отрывок Twine или узел Ink до превращения в сцену.
+type passage struct {
+	name  string
+	text  string
+	tags  []string
+	links []link
+	entry assignments // присваивания при входе в отрывок
+}
+
+// link — ссылка или выбор, ведущие в другой отрывок по его имени.
+type link struct {
+	text     string
+	target   string
+	requires []domain.Condition
+	assignments
+}
+
+// build собирает сцены из отрывков. start — имя стартового отрывка,
+// stats — стартовые значения характеристик из исходника.
+// Присваивания при входе в отрывок становятся действиями при входе в сцену (on_enter).
+func build(title, start string, passages []*passage, stats map[string]int, issues []Issue) Result {
+	// 1. Идентификаторы сцен из имён отрывков
+	sceneIDs := idSet{}
+	ids := make(map[string]string, len(passages))
+	for i, p := range passages {
+		ids[p.name] = sceneIDs.unique(slug(p.name), fmt.Sprintf("scene_%d", i+1))
+	}
+	if _, ok := ids[start]; !ok {
+		issues = append(issues, Issue{Message: fmt.Sprintf("start passage %q not found; using %q", start, passages[0].name)})
+		start = passages[0].name
+	}
+
+	// 2. Сцены
+	res := Result{Scenes: make([]domain.Scene, 0, len(passages))}
+	declared := map[string]bool{}
+	for name := range stats {
+		declared[name] = true
+	}
+	for _, p := range passages {
+		scene := domain.Scene{ID: ids[p.name], Text: p.text}
+		if !p.entry.empty() {
+			scene.OnEnter = &domain.SceneEntry{
+				Effects:    p.entry.effects,
+				SetFlags:   p.entry.setFlags,
+				ClearFlags: p.entry.clearFlags,
+			}
+			for stat := range p.entry.effects {
+				declared[stat] = true
+			}
+		}
+		choiceIDs := idSet{}
+		for i, l := range p.links {
+			next, ok := ids[l.target]
+			if !ok {
+				issues = append(issues, Issue{Passage: p.name, Message: fmt.Sprintf("link %q leads to missing passage %q; skipped", l.text, l.target)})
+				continue
+			}
+			a := l.assignments
+			for stat := range a.effects {
+				declared[stat] = true
+			}
+			base := slug(l.text)
+			if base == "" {
+				base = next
+			}
+			scene.Choices = append(scene.Choices, domain.Choice{
+				ID:         choiceIDs.unique(base, fmt.Sprintf("choice_%d", i+1)),
+				Text:       l.text,
+				Next:       next,
+				Effects:    a.effects,
+				Requires:   l.requires,
+				SetFlags:   a.setFlags,
+				ClearFlags: a.clearFlags,
+			})
+		}
+		if len(scene.Choices) == 0 {
+			scene.Ending = &domain.Ending{ID: scene.ID, Title: p.name, Category: endingCategory(p.tags)}
+		}
+		res.Scenes = append(res.Scenes, scene)
+	}
+
+	// 3. Манифест: характеристики — все переменные со стартовым значением или эффектом
+	res.Story = domain.Story{Title: title, Version: 1, Start: ids[start]}
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		res.Story.Stats = append(res.Story.Stats, domain.StatDef{Name: name, Default: stats[name]})
+	}
+	res.Issues = issues
+	return res
+}
+
+// endingCategory берёт категорию концовки из тегов отрывка: good, bad или secret.
+func endingCategory(tags []string) string {
+	for _, t := range tags {
+		switch t = strings.ToLower(t); t {
+		case domain.EndingGood, domain.EndingBad, domain.EndingSecret:
+			return t
+		}
+	}
+	return ""
+}
+
+// negate возвращает отрицание простого условия: флаг или сравнение характеристики.
+// Составные условия отрицать нельзя — в грамматике условий нет "not (...)".
+func negate(c domain.Condition) (domain.Condition, bool) {
+	inverse := map[string]string{">=": "<", ">": "<=", "<=": ">", "<": ">=", "==": "!=", "!=": "=="}
+	switch {
+	case len(c.All) > 0 || len(c.Any) > 0:
+		return domain.Condition{}, false
+	case c.Stat != "" && c.Flag == "" && c.NotFlag == "":
+		return domain.Condition{Stat: c.Stat, Op: inverse[c.Op], Value: c.Value}, true
+	case c.Flag != "" && c.Stat == "" && c.NotFlag == "":
+		return domain.Condition{NotFlag: c.Flag}, true
+	case c.NotFlag != "" && c.Stat == "" && c.Flag == "":
+		return domain.Condition{Flag: c.NotFlag}, true
+	}
+	return domain.Condition{}, false
+}
+
+// tidy убирает пробелы в концах строк и лишние пустые строки, оставшиеся
+// на месте ссылок и макросов.
+func tidy(text string) string {
+	lines := strings.Split(text, "\n")
+	out := lines[:0]
+	blank := 0
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(line) == "" {
+			blank++
+			if blank > 1 {
+				continue
+			}
+			line = ""
+		} else {
+			blank = 0
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// idSet выдаёт уникальные идентификаторы, добавляя к повторам суффикс _2, _3...
+type idSet map[string]bool
+
+func (s idSet) unique(base, fallback string) string {
+	if base == "" {
+		base = fallback
+	}
+	id := base
+	for n := 2; s[id]; n++ {
+		suffix := fmt.Sprintf("_%d", n)
+		id = base[:min(len(base), 64-len(suffix))] + suffix
+	}
+	s[id] = true
+	return id
+}
+
+// translit — транслитерация русских букв для идентификаторов сцен.
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "h", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya",
+}
+
+// slug превращает имя отрывка или текст выбора в идентификатор по грамматике сцен:
+// "У ворот храма" → "u_vorot_hrama". Пустая строка — из имени ничего не получилось.
+func slug(name string) string {
+	var b strings.Builder
+	sep := false
+	for _, r := range strings.ToLower(name) {
+		s, ok := translit[r]
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-':
+			s = string(r)
+		case ok:
+		default:
+			sep = b.Len() > 0
+			continue
+		}
+		if s == "" {
+			continue
+		}
+		if sep {
+			b.WriteByte('_')
+			sep = false
+		}
+		b.WriteString(s)
+	}
+	id := b.String()
+	if len(id) > 64 {
+		id = strings.TrimRight(id[:64], "_-")
+	}
+	if !domain.ValidSceneID(id) {
+		return ""
+	}
+	return id
+}
@@ -0,0 +1,209 @@
+package storyimport
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/service"
+)
+
+const sampleTwee = `:: StoryTitle
+Ронин
+
+:: StoryData
+{"ifid": "D674C58C-DEFA-4F70-B7A2-27742230C0FC", "format": "Harlowe", "format-version": "3.3.8", "start": "У ворот"}
+
+:: Стили [stylesheet]
+body { color: red; }
+
+:: У ворот {"position":"100,100","size":"100,100"}
+(set: $honor to 0)(set: $rage to 2)(set: $armed to true)
+Ты стоишь у ворот. Ярость: $rage.
+
+[[Атаковать->Коридор]]
+[[Прокрасться|Чёрный ход]]
+(if: $honor >= 1)[[[Поклониться->Коридор]]]
+
+:: Коридор
+(set: $rage to it + 1, $spared to true)
+(if: $spared is true)[Монах жив.](else:)[Тишина.] Пощада: $spared.
+(dropdown: bind $weapon, "меч", "лук")
+(link-goto: "Вернуться", "У ворот")
+
+:: Чёрный ход [good]
+Ты ушёл, не оглядываясь.
+`
+
+func TestTwee(t *testing.T) {
+	res, err := Twee(strings.NewReader(sampleTwee))
+	if err != nil {
+		t.Fatalf("Twee: %v", err)
+	}
+
+	want := domain.Story{Title: "Ронин", Version: 1, Start: "u_vorot", Stats: []domain.StatDef{
+		{Name: "honor", Default: 0}, {Name: "rage", Default: 2},
+	}}
+	if !reflect.DeepEqual(res.Story, want) {
+		t.Errorf("story = %+v; want %+v", res.Story, want)
+	}
+	if len(res.Scenes) != 3 {
+		t.Fatalf("scenes = %+v; want 3", res.Scenes)
+	}
+
+	gate := res.Scenes[0]
+	if gate.Text != "Ты стоишь у ворот. Ярость: {{rage}}." {
+		t.Errorf("gate text = %q", gate.Text)
+	}
+	if want := (&domain.SceneEntry{SetFlags: []string{"armed"}}); !reflect.DeepEqual(gate.OnEnter, want) {
+		t.Errorf("gate on_enter = %+v; want %+v", gate.OnEnter, want)
+	}
+	wantChoices := []domain.Choice{
+		{ID: "atakovat", Text: "Атаковать", Next: "koridor"},
+		{ID: "prokrastsya", Text: "Прокрасться", Next: "chernyy_hod"},
+		{ID: "poklonitsya", Text: "Поклониться", Next: "koridor",
+			Requires: []domain.Condition{{Stat: "honor", Op: ">=", Value: 1}}},
+	}
+	if !reflect.DeepEqual(gate.Choices, wantChoices) {
+		t.Errorf("gate choices = %+v; want %+v", gate.Choices, wantChoices)
+	}
+
+	hall := res.Scenes[1]
+	if !strings.Contains(hall.Text, "{{if spared}}Монах жив.{{else}}Тишина.{{end}} Пощада: {{if spared}}true{{else}}false{{end}}.") {
+		t.Errorf("hall text = %q", hall.Text)
+	}
+	if want := (&domain.SceneEntry{Effects: map[string]int{"rage": 1}, SetFlags: []string{"spared"}}); !reflect.DeepEqual(hall.OnEnter, want) {
+		t.Errorf("hall on_enter = %+v; want %+v", hall.OnEnter, want)
+	}
+	if len(hall.Choices) != 1 || hall.Choices[0].Next != "u_vorot" {
+		t.Errorf("hall choices = %+v", hall.Choices)
+	}
+
+	end := res.Scenes[2]
+	if end.Ending == nil || end.Ending.Category != domain.EndingGood {
+		t.Errorf("ending = %+v; want good ending", end.Ending)
+	}
+
+	if len(res.Issues) != 2 || !strings.Contains(res.Issues[0].Message, "flag $spared") || !strings.Contains(res.Issues[1].Message, "(dropdown:)") {
+		t.Errorf("issues = %v; want the printed flag and the unsupported dropdown", res.Issues)
+	}
+	assertValid(t, res)
+}
+
+// sampleInk — результат компиляции:
+//
+//	VAR honor = 0
+//	VAR rage = 2
+//	VAR spared = false
+//	-> intro
+//	=== intro ===
+//	Ты стоишь у ворот. Ярость: {rage}.
+//	* [Атаковать] ~ rage = rage + 1
+//	  ~ spared = true
+//	  -> hallway
+//	* {honor >= 1} [Поклониться] -> hallway
+//	* Уйти -> gate
+//	=== hallway ===
+//	Монах ждёт.
+//	-> gate
+//	= side
+//	Боковой проход.
+//	=== gate ===
+//	# good
+//	Конец.
+//	-> END
+const sampleInk = `{"inkVersion":21,"root":[[{"->":"intro"},["done",{"#n":"g-0"}],null],"done",{
+"intro":[["^Ты стоишь у ворот. Ярость: ","ev",{"VAR?":"rage"},"out","/ev","^.","\n",
+  "ev","str","^Атаковать","/str","/ev",{"*":".^.c-0","flg":20},
+  "ev",{"VAR?":"honor"},1,">=","/ev","ev","str","^Поклониться","/str","/ev",{"*":".^.c-1","flg":21},
+  ["ev",{"^->":"intro.0.16.$r1"},{"temp=":"$r"},"str",{"->":".^.s"},[{"#n":"$r1"}],"/str","/ev",{"*":".^.^.c-2","flg":18},{"s":["^Уйти",{"->":"$r","var":true},null]}],
+  {"c-0":["\n","ev",{"VAR?":"rage"},1,"+","/ev",{"VAR=":"rage","re":true},"ev",true,"/ev",{"VAR=":"spared","re":true},{"->":"hallway"},{"#f":5}],
+   "c-1":["\n",{"->":"hallway"},{"#f":5}],
+   "c-2":["ev",{"^->":"intro.0.c-2.$r2"},"/ev",{"temp=":"$r"},{"->":".^.^.16.s"},[{"#n":"$r2"}],"\n",{"->":"gate"},{"#f":5}]}],null],
+"hallway":[["^Монах ждёт.","\n",{"->":"gate"},null],{"side":[["^Боковой проход.","\n",null],null]}],
+"gate":[["#","^good","/#","^Конец.","\n","end",null],null],
+"global decl":["ev",0,{"VAR=":"honor"},2,{"VAR=":"rage"},false,{"VAR=":"spared"},"/ev","end",null]}],"listDefs":{}}`
+
+func TestInk(t *testing.T) {
+	res, err := Ink(strings.NewReader(sampleInk))
+	if err != nil {
+		t.Fatalf("Ink: %v", err)
+	}
+
+	if res.Story.Start != "intro" {
+		t.Errorf("start = %q; want intro", res.Story.Start)
+	}
+	wantStats := []domain.StatDef{{Name: "honor", Default: 0}, {Name: "rage", Default: 2}}
+	if !reflect.DeepEqual(res.Story.Stats, wantStats) {
+		t.Errorf("stats = %+v; want %+v", res.Story.Stats, wantStats)
+	}
+	if len(res.Scenes) != 3 {
+		t.Fatalf("scenes = %+v; want 3", res.Scenes)
+	}
+
+	intro := res.Scenes[0]
+	if intro.Text != "Ты стоишь у ворот. Ярость: {{rage}}." {
+		t.Errorf("intro text = %q", intro.Text)
+	}
+	wantChoices := []domain.Choice{
+		{ID: "atakovat", Text: "Атаковать", Next: "hallway", Effects: map[string]int{"rage": 1}, SetFlags: []string{"spared"}},
+		{ID: "poklonitsya", Text: "Поклониться", Next: "hallway", Requires: []domain.Condition{{Stat: "honor", Op: ">=", Value: 1}}},
+		{ID: "uyti", Text: "Уйти", Next: "gate"},
+	}
+	if !reflect.DeepEqual(intro.Choices, wantChoices) {
+		t.Errorf("intro choices = %+v; want %+v", intro.Choices, wantChoices)
+	}
+
+	hall := res.Scenes[1]
+	if len(hall.Choices) != 1 || hall.Choices[0].Text != ContinueText || hall.Choices[0].Next != "gate" {
+		t.Errorf("hallway choices = %+v; want a single continue choice", hall.Choices)
+	}
+	if gate := res.Scenes[2]; gate.Ending == nil || gate.Ending.Category != domain.EndingGood || gate.Text != "Конец." {
+		t.Errorf("gate = %+v; want good ending", gate)
+	}
+
+	var msgs []string
+	for _, i := range res.Issues {
+		msgs = append(msgs, i.String())
+	}
+	got := strings.Join(msgs, "\n")
+	for _, want := range []string{"hallway: stitch side is not imported", "hallway: divert -> gate is imported as a choice"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("issues = %q; want %q", got, want)
+		}
+	}
+	if len(res.Issues) != 2 {
+		t.Errorf("issues = %q; want exactly 2", got)
+	}
+	assertValid(t, res)
+}
+
+func TestSlug(t *testing.T) {
+	cases := map[string]string{
+		"Start":              "start",
+		"У ворот храма":      "u_vorot_hrama",
+		"  The Hall (east) ": "the_hall_east",
+		"???":                "",
+	}
+	for in, want := range cases {
+		if got := slug(in); got != want {
+			t.Errorf("slug(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+// assertValid проверяет, что импортированная история проходит storycheck без ошибок.
+func assertValid(t *testing.T, res Result) {
+	t.Helper()
+	scenes := map[string]domain.Scene{}
+	for _, sc := range res.Scenes {
+		scenes[sc.ID] = sc
+	}
+	report := service.ValidateScenes(scenes, res.Story)
+	for _, issue := range report.Issues {
+		if issue.Severity == service.SeverityError {
+			t.Errorf("storycheck: %s", issue)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package storyimport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"blood-on-maple-leaves/backend/domain"
+)
+
+// Twee читает историю Twine в формате Twee 3 с разметкой Harlowe.
+//
+// Переносится:
+//
+//	[[Текст->Отрывок]], [[Отрывок<-Текст]], [[Текст|Отрывок]], [[Отрывок]] — выборы
+//	(link-goto: "Текст", "Отрывок")                        — выбор
+//	(goto: "Отрывок")                                      — выбор с текстом ContinueText
+//	(set: $honor to it + 1), (set: $honor to $honor - 2)   — эффекты
+//	(set: $spared to true), (set: $spared to false)        — флаги
+//	(set: $honor to 3) в стартовом отрывке и отрывках с тегом startup — стартовые значения
+//	(if: условие)[...](else:)[...], (unless: ...)[...]     — {{if}} в тексте, условия выборов
+//	$honor, (print: $honor)                                — вставка {{honor}}
+//	$spared, (print: $spared) для флага                    — {{if spared}}true{{else}}false{{end}}
+//
+// Остальные макросы остаются в тексте как есть и попадают в Issues.
+// Отрывок без выборов становится концовкой; теги good, bad, secret задают её категорию.
+func Twee(r io.Reader) (Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// 1. Служебные отрывки: название, метаданные, скрипты и стили
+	title, start := "", "Start"
+	var issues []Issue
+	var story []tweePassage
+	for _, tp := range splitTwee(string(data)) {
+		switch {
+		case tp.name == "StoryTitle":
+			title = strings.TrimSpace(tp.body)
+		case tp.name == "StoryData":
+			var meta struct {
+				Start  string `json:"start"`
+				Format string `json:"format"`
+			}
+			if err := json.Unmarshal([]byte(tp.body), &meta); err != nil {
+				return Result{}, fmt.Errorf("StoryData: %w", err)
+			}
+			if meta.Start != "" {
+				start = meta.Start
+			}
+			if meta.Format != "" && !strings.EqualFold(meta.Format, "Harlowe") {
+				issues = append(issues, Issue{Message: fmt.Sprintf("story format %s: only Harlowe markup is understood", meta.Format)})
+			}
+		case slices.Contains(tp.tags, "stylesheet"), slices.Contains(tp.tags, "Twine.private"):
+		case slices.Contains(tp.tags, "script"):
+			issues = append(issues, Issue{Passage: tp.name, Message: "script passage is not imported"})
+		default:
+			story = append(story, tp)
+		}
+	}
+
+	// 2. Флаги — переменные, которым где-либо присваивают true/false или которые
+	// так сравнивают; вставка в текст у них иная, чем у характеристик
+	flags := map[string]bool{}
+	for _, tp := range story {
+		for _, m := range harloweFlag.FindAllStringSubmatch(tp.body, -1) {
+			flags[m[1]] = true
+		}
+	}
+
+	// 3. Разметка отрывков
+	stats := map[string]int{}
+	var passages []*passage
+	for _, tp := range story {
+		h := harlowe{name: tp.name, p: &passage{name: tp.name, tags: tp.tags}, flags: flags}
+		if tp.name == start || slices.Contains(tp.tags, "startup") {
+			h.stats = stats
+		}
+		text := h.markup(tp.body, nil, false)
+		issues = append(issues, h.issues...)
+		if slices.Contains(tp.tags, "startup") {
+			if strings.TrimSpace(text) != "" || len(h.p.links) > 0 {
+				issues = append(issues, Issue{Passage: tp.name, Message: "only (set:) is imported from startup passages"})
+			}
+			continue
+		}
+		h.p.text = tidy(text)
+		passages = append(passages, h.p)
+	}
+	if len(passages) == 0 {
+		return Result{}, errors.New("no story passages")
+	}
+
+	return build(title, start, passages, stats, issues), nil
+}
+
+// tweePassage — отрывок Twee до разбора разметки.
+type tweePassage struct {
+	name string
+	tags []string
+	body string
+}
+
+// splitTwee делит исходник на отрывки по заголовкам ":: Имя [теги] {метаданные}".
+func splitTwee(src string) []tweePassage {
+	var out []tweePassage
+	var body []string
+	flush := func() {
+		if len(out) > 0 {
+			out[len(out)-1].body = strings.TrimRight(strings.Join(body, "\n"), " \t\r\n")
+		}
+		body = nil
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		if !strings.HasPrefix(line, "::") {
+			body = append(body, line)
+			continue
+		}
+		flush()
+		name, tags := parseTweeHeader(line[2:])
+		out = append(out, tweePassage{name: name, tags: tags})
+	}
+	flush()
+	return out
+}
+
+// parseTweeHeader разбирает заголовок отрывка без "::". Метаданные (позиция на схеме) отбрасываются.
+func parseTweeHeader(h string) (string, []string) {
+	h = strings.TrimSpace(h)
+	if strings.HasSuffix(h, "}") {
+		if i := unescapedIndex(h, '{'); i >= 0 {
+			h = strings.TrimSpace(h[:i])
+		}
+	}
+	var tags []string
+	if strings.HasSuffix(h, "]") {
+		if i := unescapedIndex(h, '['); i >= 0 {
+			tags = strings.Fields(h[i+1 : len(h)-1])
+			h = strings.TrimSpace(h[:i])
+		}
+	}
+	name := strings.NewReplacer(`\\`, `\`, `\[`, `[`, `\]`, `]`, `\{`, `{`, `\}`, `}`).Replace(h)
+	return name, tags
+}
+
+// unescapedIndex возвращает позицию последнего неэкранированного символа c.
+func unescapedIndex(s string, c byte) int {
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] == c && (i == 0 || s[i-1] != '\\') {
+			return i
+		}
+	}
+	return -1
+}
+
+// harlowe — разбор разметки одного отрывка.
+type harlowe struct {
+	name   string
+	p      *passage
+	stats  map[string]int  // стартовые значения; nil — абсолютные присваивания не переносятся
+	flags  map[string]bool // переменные-флаги всей истории
+	issues []Issue
+}
+
+func (h *harlowe) issue(format string, args ...any) {
+	h.issues = append(h.issues, Issue{Passage: h.name, Message: fmt.Sprintf(format, args...)})
+}
+
+// markup переводит разметку src в текст сцены, а ссылки и присваивания — в отрывок.
+// requires — условия объемлющих (if:); nested — src находится внутри хука.
+func (h *harlowe) markup(src string, requires []domain.Condition, nested bool) string {
+	var b strings.Builder
+	for i := 0; i < len(src); {
+		switch {
+		case strings.HasPrefix(src[i:], "[["):
+			j := strings.Index(src[i+2:], "]]")
+			if j < 0 {
+				b.WriteString(src[i:])
+				i = len(src)
+				continue
+			}
+			h.link(src[i+2:i+2+j], requires)
+			i += j + 4
+		case src[i] == '(':
+			name, args, end, ok := macroAt(src, i)
+			if !ok {
+				b.WriteByte(src[i])
+				i++
+				continue
+			}
+			i = h.macro(&b, src, i, end, name, args, requires, nested)
+		case src[i] == '$' && identEnd(src, i+1) > i+1:
+			j := identEnd(src, i+1)
+			h.insert(&b, src[i+1:j])
+			i = j
+		default:
+			b.WriteByte(src[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// link добавляет выбор из ссылки [[...]].
+func (h *harlowe) link(inner string, requires []domain.Condition) {
+	text, target := inner, inner
+	if i := strings.LastIndex(inner, "->"); i >= 0 {
+		text, target = inner[:i], inner[i+2:]
+	} else if i := strings.Index(inner, "<-"); i >= 0 {
+		target, text = inner[:i], inner[i+2:]
+	} else if i := strings.Index(inner, "|"); i >= 0 {
+		text, target = inner[:i], inner[i+1:]
+	}
+	h.p.links = append(h.p.links, link{
+		text:     strings.TrimSpace(text),
+		target:   strings.TrimSpace(target),
+		requires: slices.Clone(requires),
+	})
+}
+
+// macro обрабатывает макрос src[start:end] и возвращает позицию, с которой продолжать разбор.
+func (h *harlowe) macro(b *strings.Builder, src string, start, end int, name, args string, requires []domain.Condition, nested bool) int {
+	switch name {
+	case "set":
+		for _, a := range strings.Split(args, ",") {
+			h.set(strings.TrimSpace(a), nested)
+		}
+	case "linkgoto", "goto":
+		strs := stringArgs(args)
+		if len(strs) == 0 {
+			h.issue("(%s:) without a passage name is not imported", name)
+			break
+		}
+		text, target := strs[0], strs[len(strs)-1]
+		if name == "goto" {
+			text = ContinueText
+			h.issue("(goto: %q) is imported as a choice %q", target, text)
+		}
+		h.p.links = append(h.p.links, link{text: text, target: target, requires: slices.Clone(requires)})
+	case "print":
+		v := strings.TrimSpace(args)
+		if strings.HasPrefix(v, "$") && identEnd(v, 1) == len(v) && len(v) > 1 {
+			h.insert(b, v[1:])
+		} else {
+			h.issue("(print: %s) is not imported", v)
+			b.WriteString(src[start:end])
+		}
+	case "if", "unless":
+		return h.conditional(b, src, end, name, args, requires)
+	default:
+		h.issue("unsupported macro (%s:) is left in the text", name)
+		b.WriteString(src[start:end])
+	}
+	return end
+}
+
+// conditional переводит (if:)[...] и (unless:)[...] с необязательным (else:)[...]
+// в {{if}}...{{else}}...{{end}}; ссылки внутри хуков получают условие выбора.
+func (h *harlowe) conditional(b *strings.Builder, src string, pos int, name, args string, requires []domain.Condition) int {
+	then, pos, ok := hookAt(src, pos)
+	if !ok {
+		h.issue("(%s: %s) without a hook is not imported", name, args)
+		return pos
+	}
+	cond, err := harloweCondition(args)
+	if err == nil && name == "unless" {
+		var ok bool
+		if cond, ok = negate(cond); !ok {
+			err = errors.New("compound condition")
+		}
+	}
+	if err != nil {
+		h.issue("condition (%s: %s) is not imported (%v); its hook is shown unconditionally", name, args, err)
+		b.WriteString(h.markup(then, requires, true))
+		return pos
+	}
+
+	then = h.markup(then, append(slices.Clone(requires), cond), true)
+
+	// (else:) сразу за хуком
+	els, hasElse := "", false
+	if elseName, _, elseEnd, ok := macroAt(src, pos); ok && elseName == "else" {
+		if hook, next, ok := hookAt(src, elseEnd); ok {
+			before := len(h.p.links)
+			elseRequires := requires
+			if neg, ok := negate(cond); ok {
+				elseRequires = append(slices.Clone(requires), neg)
+			}
+			els, hasElse = h.markup(hook, elseRequires, true), true
+			if len(h.p.links) > before && len(elseRequires) == len(requires) {
+				h.issue("links in (else:) of compound condition %q are imported without a condition", cond.String())
+			}
+			pos = next
+		}
+	}
+
+	// Хуки, в которых были только ссылки, в тексте не нужны
+	if strings.TrimSpace(then+els) == "" {
+		return pos
+	}
+	b.WriteString("{{if " + cond.String() + "}}" + then)
+	if hasElse {
+		b.WriteString("{{else}}" + els)
+	}
+	b.WriteString("{{end}}")
+	return pos
+}
+
+// insert выводит значение переменной name так, как его напечатал бы Harlowe:
+// характеристику — вставкой, флаг — словом true или false.
+func (h *harlowe) insert(b *strings.Builder, name string) {
+	if !h.flags[name] {
+		b.WriteString("{{" + name + "}}")
+		return
+	}
+	h.issue("flag $%s is printed as true/false", name)
+	b.WriteString("{{if " + name + "}}true{{else}}false{{end}}")
+}
+
+var (
+	setDelta    = regexp.MustCompile(`^\$(\w+)\s+to\s+(?:it|\$(\w+))\s*([+-])\s*(\d+)$`)
+	setValue    = regexp.MustCompile(`^\$(\w+)\s+to\s+(-?\d+|true|false)$`)
+	harloweBool = regexp.MustCompile(`\$(\w+)\s+is\s+(true|false)\b`)
+	harloweFlag = regexp.MustCompile(`\$(\w+)\s+(?:is|to)\s+(?:true|false)\b`)
+)
+
+// set переносит одно присваивание из (set:).
+func (h *harlowe) set(expr string, nested bool) {
+	if m := setDelta.FindStringSubmatch(expr); m != nil && (m[2] == "" || m[2] == m[1]) {
+		if nested {
+			h.issue("conditional (set: %s) is not imported", expr)
+			return
+		}
+		delta, _ := strconv.Atoi(m[4])
+		if m[3] == "-" {
+			delta = -delta
+		}
+		h.p.entry.addEffect(m[1], delta)
+		return
+	}
+	m := setValue.FindStringSubmatch(expr)
+	switch {
+	case m == nil:
+		h.issue("(set: %s) is not imported: only increments, numbers and true/false are supported", expr)
+	case nested:
+		h.issue("conditional (set: %s) is not imported", expr)
+	case m[2] == "true" || m[2] == "false":
+		if h.stats != nil && m[2] == "false" {
+			return // флаги и так изначально сняты
+		}
+		h.p.entry.setFlag(m[1], m[2] == "true")
+	case h.stats != nil:
+		h.stats[m[1]], _ = strconv.Atoi(m[2])
+	default:
+		h.issue("(set: %s) assigns an absolute value; only increments are imported", expr)
+	}
+}
+
+// harloweCondition переводит условие Harlowe в грамматику условий проекта:
+// "$honor >= 3 and $spared is true" → "honor >= 3 and spared".
+func harloweCondition(s string) (domain.Condition, error) {
+	s = harloweBool.ReplaceAllStringFunc(s, func(m string) string {
+		sub := harloweBool.FindStringSubmatch(m)
+		if sub[2] == "false" {
+			return "not $" + sub[1]
+		}
+		return "$" + sub[1]
+	})
+	s = strings.ReplaceAll(s, " is not ", " != ")
+	s = strings.ReplaceAll(s, " is ", " == ")
+	s = strings.ReplaceAll(s, "$", "")
+	return domain.ParseCondition(s)
+}
+
+// macroAt разбирает макрос "(имя: аргументы)" в позиции i. Имя приводится
+// к виду без регистра, "-" и "_", как его сравнивает Harlowe: link-goto → linkgoto.
+func macroAt(src string, i int) (name, args string, end int, ok bool) {
+	if i >= len(src) || src[i] != '(' {
+		return "", "", i, false
+	}
+	j := i + 1
+	for j < len(src) && (isASCIILetter(src[j]) || j > i+1 && (src[j] == '-' || src[j] == '_' || src[j] >= '0' && src[j] <= '9')) {
+		j++
+	}
+	if j == i+1 || j >= len(src) || src[j] != ':' {
+		return "", "", i, false
+	}
+	name = strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(src[i+1 : j]))
+
+	depth, quote := 1, byte(0)
+	for k := j + 1; k < len(src); k++ {
+		c := src[k]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				k++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth--; depth == 0 {
+				return name, strings.TrimSpace(src[j+1 : k]), k + 1, true
+			}
+		}
+	}
+	return "", "", i, false
+}
+
+// hookAt возвращает содержимое хука "[...]" в позиции i с учётом вложенных скобок.
+func hookAt(src string, i int) (hook string, end int, ok bool) {
+	if i >= len(src) || src[i] != '[' {
+		return "", i, false
+	}
+	depth := 0
+	for k := i; k < len(src); k++ {
+		switch src[k] {
+		case '[':
+			depth++
+		case ']':
+			if depth--; depth == 0 {
+				return src[i+1 : k], k + 1, true
+			}
+		}
+	}
+	return "", i, false
+}
+
+// stringArgs возвращает строковые литералы из аргументов макроса.
+func stringArgs(args string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		q := args[i]
+		if q != '"' && q != '\'' {
+			continue
+		}
+		var b strings.Builder
+		for i++; i < len(args) && args[i] != q; i++ {
+			if args[i] == '\\' && i+1 < len(args) {
+				i++
+			}
+			b.WriteByte(args[i])
+		}
+		out = append(out, b.String())
+	}
+	return out
+}
+
+// identEnd возвращает конец имени переменной, начинающегося в позиции i.
+func identEnd(s string, i int) int {
+	j := i
+	for j < len(s) && (isASCIILetter(s[j]) || s[j] == '_' || j > i && s[j] >= '0' && s[j] <= '9') {
+		j++
+	}
+	return j
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"blood-on-maple-leaves/backend/internal/problem"
+)
+
+// AdminChecker сообщает, является ли игрок администратором.
+type AdminChecker interface {
+	IsAdmin(ctx context.Context, id string) (bool, error)
+}
+
+// AdminMiddleware пропускает только администраторов. Ставится после
+// AuthMiddleware: userID берётся из контекста запроса.
+func AdminMiddleware(admins AdminChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 1. Игрок из access-токена
+			userID, ok := r.Context().Value(ContextUserID).(string)
+			if !ok || userID == "" {
+				problem.Error(w, http.StatusUnauthorized, "unauthorized", "missing user")
+				return
+			}
+
+			// 2. Права администратора проверяются на каждом запросе,
+			// чтобы снятие прав действовало сразу, а не после истечения токена
+			admin, err := admins.IsAdmin(r.Context(), userID)
+			if err != nil {
+				log.Printf("admin check %s: %v", userID, err)
+				admin = false
+			}
+			if !admin {
+				problem.Error(w, http.StatusForbidden, "forbidden", "admin rights required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"blood-on-maple-leaves/backend/internal/problem"
 	"blood-on-maple-leaves/backend/internal/token"
 )
 
@@ -19,13 +20,13 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// 1. Извлекаем заголовок Authorization
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "missing Authorization header", http.StatusUnauthorized)
+			problem.Error(w, http.StatusUnauthorized, "unauthorized", "missing Authorization header")
 			return
 		}
 
 		// 2. Проверяем формат "Bearer <token>"
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			http.Error(w, "invalid Authorization format", http.StatusUnauthorized)
+			problem.Error(w, http.StatusUnauthorized, "unauthorized", "invalid Authorization format")
 			return
 		}
 
@@ -35,14 +36,14 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// 4. Проверяем токен через JWT-модуль
 		claims, err := token.VerifyAccessToken(tokenStr)
 		if err != nil {
-			http.Error(w, "unauthorized: invalid token", http.StatusUnauthorized)
+			problem.Error(w, http.StatusUnauthorized, "unauthorized", "invalid token")
 			return
 		}
 
 		// 5. Получаем userID из токена
 		userID, ok := claims["sub"].(string)
 		if !ok {
-			http.Error(w, "invalid token claims", http.StatusUnauthorized)
+			problem.Error(w, http.StatusUnauthorized, "unauthorized", "invalid token claims")
 			return
 		}
 
@@ -0,0 +1,11 @@
+ALTER TABLE saves
+    ADD COLUMN rage INT NOT NULL DEFAULT 0,
+    ADD COLUMN honor INT NOT NULL DEFAULT 0,
+    ADD COLUMN karma INT NOT NULL DEFAULT 0;
+
+UPDATE saves
+SET honor = COALESCE((stats->>'honor')::int, 0),
+    rage  = COALESCE((stats->>'rage')::int, 0),
+    karma = COALESCE((stats->>'karma')::int, 0);
+
+ALTER TABLE saves DROP COLUMN stats;
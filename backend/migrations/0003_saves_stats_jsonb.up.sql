@@ -0,0 +1,9 @@
+ALTER TABLE saves ADD COLUMN stats JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+UPDATE saves
+SET stats = jsonb_build_object('honor', honor, 'rage', rage, 'karma', karma);
+
+ALTER TABLE saves
+    DROP COLUMN honor,
+    DROP COLUMN rage,
+    DROP COLUMN karma;
@@ -0,0 +1 @@
+ALTER TABLE saves DROP COLUMN flags;
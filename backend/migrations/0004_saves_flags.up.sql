@@ -0,0 +1 @@
+ALTER TABLE saves ADD COLUMN flags TEXT[] NOT NULL DEFAULT '{}';
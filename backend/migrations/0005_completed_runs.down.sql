@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS completed_runs;
+
+ALTER TABLE saves DROP COLUMN ending_id;
@@ -0,0 +1,15 @@
+ALTER TABLE saves ADD COLUMN ending_id TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE completed_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    player_id UUID NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+    scene_id TEXT NOT NULL,
+    ending_id TEXT NOT NULL,
+    ending_title TEXT NOT NULL DEFAULT '',
+    ending_category TEXT NOT NULL DEFAULT '',
+    stats JSONB NOT NULL DEFAULT '{}'::jsonb,
+    flags TEXT[] NOT NULL DEFAULT '{}',
+    finished_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX completed_runs_player_idx ON completed_runs (player_id, finished_at DESC);
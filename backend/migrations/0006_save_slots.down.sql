@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS save_slots;
+
+DROP INDEX IF EXISTS saves_player_slot_idx;
+
+ALTER TABLE saves DROP COLUMN slot;
@@ -0,0 +1,21 @@
+ALTER TABLE saves ADD COLUMN slot TEXT NOT NULL DEFAULT 'main';
+
+CREATE INDEX saves_player_slot_idx ON saves (player_id, slot, created_at DESC);
+
+CREATE TABLE save_slots (
+    player_id UUID NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+    slot TEXT NOT NULL,
+    name TEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (player_id, slot)
+);
+
+-- не больше одного активного слота на игрока
+CREATE UNIQUE INDEX save_slots_active_idx ON save_slots (player_id) WHERE active;
+
+INSERT INTO save_slots (player_id, slot, name, active, created_at, updated_at)
+SELECT player_id, 'main', 'main', true, min(created_at), max(created_at)
+FROM saves
+GROUP BY player_id;
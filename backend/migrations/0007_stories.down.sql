@@ -0,0 +1,16 @@
+-- прогресс в остальных историях без колонки story_id не различить
+DELETE FROM completed_runs WHERE story_id <> 'blood-on-maple-leaves';
+DELETE FROM save_slots WHERE story_id <> 'blood-on-maple-leaves';
+DELETE FROM saves WHERE story_id <> 'blood-on-maple-leaves';
+
+ALTER TABLE completed_runs DROP COLUMN story_id;
+
+DROP INDEX IF EXISTS save_slots_active_idx;
+ALTER TABLE save_slots DROP CONSTRAINT save_slots_pkey;
+ALTER TABLE save_slots ADD PRIMARY KEY (player_id, slot);
+ALTER TABLE save_slots DROP COLUMN story_id;
+CREATE UNIQUE INDEX save_slots_active_idx ON save_slots (player_id) WHERE active;
+
+DROP INDEX IF EXISTS saves_player_story_slot_idx;
+ALTER TABLE saves DROP COLUMN story_id;
+CREATE INDEX saves_player_slot_idx ON saves (player_id, slot, created_at DESC);
@@ -0,0 +1,19 @@
+-- существующий прогресс относится к первой истории каталога
+ALTER TABLE saves ADD COLUMN story_id TEXT NOT NULL DEFAULT 'blood-on-maple-leaves';
+ALTER TABLE saves ALTER COLUMN story_id DROP DEFAULT;
+
+DROP INDEX IF EXISTS saves_player_slot_idx;
+CREATE INDEX saves_player_story_slot_idx ON saves (player_id, story_id, slot, created_at DESC);
+
+ALTER TABLE save_slots ADD COLUMN story_id TEXT NOT NULL DEFAULT 'blood-on-maple-leaves';
+ALTER TABLE save_slots ALTER COLUMN story_id DROP DEFAULT;
+
+ALTER TABLE save_slots DROP CONSTRAINT save_slots_pkey;
+ALTER TABLE save_slots ADD PRIMARY KEY (player_id, story_id, slot);
+
+-- не больше одного активного слота на игрока в каждой истории
+DROP INDEX IF EXISTS save_slots_active_idx;
+CREATE UNIQUE INDEX save_slots_active_idx ON save_slots (player_id, story_id) WHERE active;
+
+ALTER TABLE completed_runs ADD COLUMN story_id TEXT NOT NULL DEFAULT 'blood-on-maple-leaves';
+ALTER TABLE completed_runs ALTER COLUMN story_id DROP DEFAULT;
@@ -0,0 +1 @@
+ALTER TABLE players ADD COLUMN locale TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+ALTER TABLE saves DROP COLUMN roll;
+ALTER TABLE saves DROP COLUMN rng_rolls;
+ALTER TABLE saves DROP COLUMN rng_seed;
@@ -0,0 +1,15 @@
+ALTER TABLE saves ADD COLUMN rng_seed BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE saves ADD COLUMN rng_rolls INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE saves ADD COLUMN roll JSONB;
+
+-- у каждого начатого прохождения своё зерно: иначе все старые слоты
+-- бросали бы одну и ту же предсказуемую последовательность;
+-- все снимки слота получают одно зерно, чтобы откат не менял броски
+UPDATE saves s
+SET rng_seed = x.seed
+FROM (
+    SELECT player_id, story_id, slot, (random() * 9e18)::bigint AS seed
+    FROM saves
+    GROUP BY player_id, story_id, slot
+) x
+WHERE s.player_id = x.player_id AND s.story_id = x.story_id AND s.slot = x.slot;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS published_scenes;
+DROP TABLE IF EXISTS story_versions;
+DROP TABLE IF EXISTS draft_scenes;
+DROP TABLE IF EXISTS story_drafts;
+
+ALTER TABLE players DROP COLUMN is_admin;
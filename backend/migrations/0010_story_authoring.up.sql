@@ -0,0 +1,37 @@
+-- администраторы редактируют и публикуют истории; назначаются вручную:
+-- UPDATE players SET is_admin = true WHERE username = '...';
+ALTER TABLE players ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+
+-- черновики: манифест и сцены в исходном YAML, как в файлах каталога
+CREATE TABLE story_drafts (
+    story_id TEXT PRIMARY KEY,
+    manifest TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE draft_scenes (
+    story_id TEXT NOT NULL REFERENCES story_drafts(story_id) ON DELETE CASCADE,
+    scene_id TEXT NOT NULL,
+    source TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (story_id, scene_id)
+);
+
+-- опубликованные версии неизменяемы; игра читает последнюю версию каждой истории
+CREATE TABLE story_versions (
+    story_id TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    manifest TEXT NOT NULL,
+    published_by UUID REFERENCES players(id) ON DELETE SET NULL,
+    published_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (story_id, version)
+);
+
+CREATE TABLE published_scenes (
+    story_id TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    scene_id TEXT NOT NULL,
+    source TEXT NOT NULL,
+    PRIMARY KEY (story_id, version, scene_id),
+    FOREIGN KEY (story_id, version) REFERENCES story_versions (story_id, version) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+-- версия истории, на которой сделано сохранение; 0 — сохранения до учёта версий,
+-- к ним применяются все миграции из манифеста
+ALTER TABLE saves ADD COLUMN story_version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,256 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"blood-on-maple-leaves/backend/domain"
+)
+
+// ErrStoryNotFound — в каталоге нет истории с таким идентификатором.
+var ErrStoryNotFound = errors.New("story not found")
+
+// StoryScenes — сцены одной истории вместе с её актуальным манифестом.
+type StoryScenes interface {
+	SceneRepo
+	StoryProvider
+}
+
+// StoryCatalog — набор историй, которые обслуживает бэкенд.
+type StoryCatalog interface {
+	// Stories возвращает манифесты всех историй, упорядоченные по ID.
+	Stories() []domain.Story
+	// Scenes возвращает сцены истории; если истории нет, возвращает ErrStoryNotFound.
+	Scenes(storyID string) (StoryScenes, error)
+}
+
+// StoryStore — хранилище историй, из которого Catalog собирает каталог:
+// папка с подпапками историй (FSStore), опубликованные версии в Postgres
+// (StoryRepoPG) или несколько хранилищ сразу (LayeredStore).
+type StoryStore interface {
+	// StoryIDs возвращает идентификаторы историй, упорядоченные по возрастанию.
+	StoryIDs() ([]string, error)
+	// Source возвращает источник истории; для одного и того же id — один и тот же,
+	// пока история не переехала в другое хранилище.
+	Source(storyID string) (StorySource, error)
+	Fingerprinter
+}
+
+// Catalog — StoryCatalog поверх хранилища историй.
+// Каждая история живёт в собственном SceneIndex, поэтому ошибка в одной
+// истории не мешает перезагрузке и работе остальных.
+type Catalog struct {
+	Store    StoryStore
+	Validate ValidateFunc // проверка каждой истории перед публикацией; nil — без проверки
+
+	indexes  atomic.Pointer[map[string]*SceneIndex]
+	reloadMu sync.Mutex
+}
+
+// NewCatalog — конструктор каталога поверх папки историй (см. FSStore);
+// истории не загружены, пока не вызван Reload.
+func NewCatalog(root fs.FS, validate ValidateFunc) *Catalog {
+	return NewCatalogFromStore(NewFSStore(root), validate)
+}
+
+// NewCatalogFromStore — конструктор поверх произвольного хранилища историй.
+func NewCatalogFromStore(store StoryStore, validate ValidateFunc) *Catalog {
+	return &Catalog{Store: store, Validate: validate}
+}
+
+// FSStore — StoryStore поверх папки, в которой каждая подпапка с манифестом
+// story.yaml — отдельная история:
+//
+//	stories/
+//	  blood-on-maple-leaves/
+//	    story.yaml
+//	    intro.yaml
+//	  second-campaign/
+//	    story.yaml
+//	    ...
+type FSStore struct {
+	Root fs.FS
+
+	mu      sync.Mutex
+	sources map[string]*storyDir
+}
+
+// NewFSStore — конструктор, принимает корень каталога.
+func NewFSStore(root fs.FS) *FSStore {
+	return &FSStore{Root: root, sources: map[string]*storyDir{}}
+}
+
+// storyDir — источник одной истории каталога: сцены из подпапки, ID — имя подпапки.
+type storyDir struct {
+	*SceneRepoFS
+	id string
+}
+
+// LoadStory читает манифест и проставляет ID истории по имени папки.
+func (d storyDir) LoadStory() (domain.Story, error) {
+	story, err := d.SceneRepoFS.LoadStory()
+	if err != nil {
+		return story, err
+	}
+	if story.ID != "" && story.ID != d.id {
+		return story, fmt.Errorf("manifest id %q does not match directory %q", story.ID, d.id)
+	}
+	story.ID = d.id
+	return story, nil
+}
+
+// StoryIDs возвращает имена подпапок Root, в которых есть манифест истории.
+func (s *FSStore) StoryIDs() ([]string, error) {
+	entries, err := fs.ReadDir(s.Root, ".")
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := fs.Stat(s.Root, path.Join(e.Name(), StoryManifestFile)); err != nil {
+			continue
+		}
+		ids = append(ids, e.Name())
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Source возвращает источник подпапки истории.
+func (s *FSStore) Source(storyID string) (StorySource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d, ok := s.sources[storyID]; ok {
+		return d, nil
+	}
+	sub, err := fs.Sub(s.Root, storyID)
+	if err != nil {
+		return nil, err
+	}
+	d := &storyDir{SceneRepoFS: NewSceneRepoFromFS(sub), id: storyID}
+	s.sources[storyID] = d
+	return d, nil
+}
+
+// Fingerprint возвращает общий отпечаток папки: набор историй и отпечатки их подпапок.
+func (s *FSStore) Fingerprint() (string, error) {
+	ids, err := s.StoryIDs()
+	if err != nil {
+		return "", err
+	}
+
+	h := fnv.New64a()
+	for _, id := range ids {
+		sub, err := fs.Sub(s.Root, id)
+		if err != nil {
+			return "", err
+		}
+		fp, err := NewSceneRepoFromFS(sub).Fingerprint()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s|%s\n", id, fp)
+	}
+	return fmt.Sprintf("%x", h.Sum64()), nil
+}
+
+// Reload заново находит истории в хранилище и перезагружает каждую.
+// Если история не прошла проверку, продолжает работать её прежняя версия,
+// а новая история без прежней версии в каталог не попадает.
+// Ошибки всех историй возвращаются вместе.
+func (c *Catalog) Reload() error {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	// 1. Истории хранилища
+	ids, err := c.Store.StoryIDs()
+	if err != nil {
+		return fmt.Errorf("list stories: %w", err)
+	}
+
+	// 2. Каждая история перезагружается в своём индексе
+	var (
+		prev = c.current()
+		next = make(map[string]*SceneIndex, len(ids))
+		errs []error
+	)
+	for _, id := range ids {
+		if !domain.ValidStoryID(id) {
+			errs = append(errs, fmt.Errorf("story directory %q: invalid story id", id))
+			continue
+		}
+		src, err := c.Store.Source(id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("story %q: %w", id, err))
+			continue
+		}
+		ix, ok := prev[id]
+		if !ok || ix.Source != src {
+			ix = NewSceneIndex(src, c.Validate)
+		}
+		if err := ix.Reload(); err != nil {
+			errs = append(errs, fmt.Errorf("story %q: %w", id, err))
+			// история переехала в другое хранилище, но новая версия не прошла проверку
+			if old, ok := prev[id]; ok && old != ix {
+				ix = old
+			}
+		}
+		if !ix.LoadedAt().IsZero() {
+			next[id] = ix
+		}
+	}
+
+	// 3. Публикуем новый набор; удалённые истории исчезают из каталога
+	c.indexes.Store(&next)
+	return errors.Join(errs...)
+}
+
+// current возвращает опубликованный набор индексов.
+func (c *Catalog) current() map[string]*SceneIndex {
+	if m := c.indexes.Load(); m != nil {
+		return *m
+	}
+	return nil
+}
+
+// Stories возвращает манифесты всех загруженных историй, упорядоченные по ID.
+func (c *Catalog) Stories() []domain.Story {
+	indexes := c.current()
+	stories := make([]domain.Story, 0, len(indexes))
+	for _, ix := range indexes {
+		stories = append(stories, ix.Story())
+	}
+	sort.Slice(stories, func(i, j int) bool { return stories[i].ID < stories[j].ID })
+	return stories
+}
+
+// Scenes возвращает индекс сцен истории.
+func (c *Catalog) Scenes(storyID string) (StoryScenes, error) {
+	ix, ok := c.current()[storyID]
+	if !ok {
+		return nil, ErrStoryNotFound
+	}
+	return ix, nil
+}
+
+// Fingerprint возвращает отпечаток хранилища историй.
+func (c *Catalog) Fingerprint() (string, error) {
+	return c.Store.Fingerprint()
+}
+
+// Watch раз в interval сверяет отпечаток каталога и перезагружает его при изменениях.
+// Результат каждой перезагрузки передаётся в onReload. Работает, пока не отменён ctx.
+func (c *Catalog) Watch(ctx context.Context, interval time.Duration, onReload func(error)) {
+	watchFingerprint(ctx, interval, c, c.Reload, onReload)
+}
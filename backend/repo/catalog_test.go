@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCatalogReload(t *testing.T) {
+	root := t.TempDir()
+	for _, id := range []string{"ronin", "monk", "drafts"} {
+		if err := os.Mkdir(filepath.Join(root, id), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(root, "ronin"), StoryManifestFile, "title: Ронин\nversion: 2\n")
+	writeFile(t, filepath.Join(root, "ronin"), "intro.yaml", "id: intro\ntext: ronin\n")
+	writeFile(t, filepath.Join(root, "monk"), StoryManifestFile, "title: Монах\nstart: gate\n")
+	writeFile(t, filepath.Join(root, "monk"), "gate.yaml", "id: gate\ntext: monk\nchoices:\n  - id: go\n    next: nowhere\n")
+	writeFile(t, filepath.Join(root, "drafts"), "notes.yaml", "id: notes\n") // без манифеста — не история
+
+	c := NewCatalog(os.DirFS(root), requireNextExists)
+
+	// Сломанная история не мешает остальным
+	if err := c.Reload(); err == nil {
+		t.Fatal("Reload with a broken story: expected error")
+	}
+	stories := c.Stories()
+	if len(stories) != 1 || stories[0].ID != "ronin" || stories[0].Version != 2 {
+		t.Fatalf("stories = %+v", stories)
+	}
+	scenes, err := c.Scenes("ronin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scene, err := scenes.Load("intro"); err != nil || scene.Text != "ronin" {
+		t.Errorf("ronin intro = %+v, %v", scene, err)
+	}
+	if _, err := c.Scenes("monk"); !errors.Is(err, ErrStoryNotFound) {
+		t.Errorf("Scenes(monk) = %v; want ErrStoryNotFound", err)
+	}
+
+	// После исправления история появляется в каталоге
+	before, _ := c.Fingerprint()
+	writeFile(t, filepath.Join(root, "monk"), "gate.yaml", "id: gate\ntext: monk, fixed\nchoices:\n  - id: go\n    next: gate\n")
+	if after, _ := c.Fingerprint(); after == before {
+		t.Error("fingerprint did not change after edit")
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if stories := c.Stories(); len(stories) != 2 || stories[0].ID != "monk" {
+		t.Errorf("stories after fix = %+v", stories)
+	}
+}
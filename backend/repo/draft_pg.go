@@ -0,0 +1,338 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"blood-on-maple-leaves/backend/domain"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrDraftNotFound — у истории нет черновика.
+var ErrDraftNotFound = errors.New("draft not found")
+
+// ErrSceneExists — в черновике уже есть сцена с таким идентификатором.
+var ErrSceneExists = errors.New("scene already exists")
+
+// PublishCheck проверяет черновик, который будет опубликован как version;
+// ошибка отменяет публикацию. Вызывается внутри транзакции публикации,
+// поэтому проверяется ровно то, что будет опубликовано.
+type PublishCheck func(version int, manifest string, scenes []domain.DraftScene) error
+
+// DraftRepo — черновики историй и их публикация. Манифест и сцены хранятся
+// в исходном YAML, как в файлах каталога, вместе с комментариями автора.
+type DraftRepo interface {
+	// GetManifest возвращает YAML манифеста черновика; если черновика нет — ErrDraftNotFound.
+	GetManifest(ctx context.Context, storyID string) (string, error)
+	// PutManifest создаёт черновик или заменяет его манифест.
+	PutManifest(ctx context.Context, storyID, manifest string) error
+	// ListScenes возвращает сцены черновика без исходного текста, упорядоченные по ID.
+	ListScenes(ctx context.Context, storyID string) ([]domain.DraftScene, error)
+	// GetScene возвращает сцену черновика; если её нет — ErrSceneNotFound.
+	GetScene(ctx context.Context, storyID, sceneID string) (domain.DraftScene, error)
+	// CreateScene добавляет сцену: ErrSceneExists, если она уже есть, ErrDraftNotFound, если нет черновика.
+	CreateScene(ctx context.Context, storyID string, scene domain.DraftScene) (domain.DraftScene, error)
+	// UpdateScene заменяет сцену; если её нет — ErrSceneNotFound.
+	UpdateScene(ctx context.Context, storyID string, scene domain.DraftScene) (domain.DraftScene, error)
+	// DeleteScene удаляет сцену; если её нет — ErrSceneNotFound.
+	DeleteScene(ctx context.Context, storyID, sceneID string) error
+	// LoadDraft возвращает манифест и все сцены черновика.
+	LoadDraft(ctx context.Context, storyID string) (string, []domain.DraftScene, error)
+	// ReplaceDraft заменяет черновик целиком: манифест и все сцены.
+	ReplaceDraft(ctx context.Context, storyID, manifest string, scenes []domain.DraftScene) error
+	// Publish публикует черновик как новую версию: следующую за последней
+	// опубликованной, но не меньше minVersion. Возвращает номер версии.
+	Publish(ctx context.Context, storyID string, publishedBy uuid.UUID, minVersion int, check PublishCheck) (int, error)
+	// Versions возвращает опубликованные версии истории, новые первыми.
+	Versions(ctx context.Context, storyID string) ([]domain.StoryVersion, error)
+}
+
+// DraftRepoPG — реализация DraftRepo через pgxpool.Pool.
+type DraftRepoPG struct {
+	DB *pgxpool.Pool
+}
+
+// NewDraftRepoPG — конструктор, принимает пул Postgres.
+func NewDraftRepoPG(db *pgxpool.Pool) *DraftRepoPG {
+	return &DraftRepoPG{DB: db}
+}
+
+func (r *DraftRepoPG) GetManifest(ctx context.Context, storyID string) (string, error) {
+	var manifest string
+	err := r.DB.QueryRow(ctx,
+		`SELECT manifest FROM story_drafts WHERE story_id = $1`,
+		storyID,
+	).Scan(&manifest)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrDraftNotFound
+	}
+	return manifest, err
+}
+
+func (r *DraftRepoPG) PutManifest(ctx context.Context, storyID, manifest string) error {
+	_, err := r.DB.Exec(ctx,
+		`INSERT INTO story_drafts (story_id, manifest, updated_at) VALUES ($1, $2, now())
+		 ON CONFLICT (story_id) DO UPDATE SET manifest = EXCLUDED.manifest, updated_at = EXCLUDED.updated_at`,
+		storyID, manifest,
+	)
+	return err
+}
+
+func (r *DraftRepoPG) ListScenes(ctx context.Context, storyID string) ([]domain.DraftScene, error) {
+	if _, err := r.GetManifest(ctx, storyID); err != nil {
+		return nil, err
+	}
+	rows, err := r.DB.Query(ctx,
+		`SELECT scene_id, updated_at FROM draft_scenes WHERE story_id = $1 ORDER BY scene_id`,
+		storyID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scenes := []domain.DraftScene{}
+	for rows.Next() {
+		var sc domain.DraftScene
+		if err := rows.Scan(&sc.ID, &sc.UpdatedAt); err != nil {
+			return nil, err
+		}
+		scenes = append(scenes, sc)
+	}
+	return scenes, rows.Err()
+}
+
+func (r *DraftRepoPG) GetScene(ctx context.Context, storyID, sceneID string) (domain.DraftScene, error) {
+	sc := domain.DraftScene{ID: sceneID}
+	err := r.DB.QueryRow(ctx,
+		`SELECT source, updated_at FROM draft_scenes WHERE story_id = $1 AND scene_id = $2`,
+		storyID, sceneID,
+	).Scan(&sc.Source, &sc.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sc, ErrSceneNotFound
+	}
+	return sc, err
+}
+
+func (r *DraftRepoPG) CreateScene(ctx context.Context, storyID string, scene domain.DraftScene) (domain.DraftScene, error) {
+	return scene, r.writeScene(ctx, storyID, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx,
+			`INSERT INTO draft_scenes (story_id, scene_id, source, updated_at) VALUES ($1, $2, $3, now())
+			 RETURNING updated_at`,
+			storyID, scene.ID, scene.Source,
+		).Scan(&scene.UpdatedAt)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return ErrSceneExists
+		}
+		return err
+	})
+}
+
+func (r *DraftRepoPG) UpdateScene(ctx context.Context, storyID string, scene domain.DraftScene) (domain.DraftScene, error) {
+	return scene, r.writeScene(ctx, storyID, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx,
+			`UPDATE draft_scenes SET source = $3, updated_at = now() WHERE story_id = $1 AND scene_id = $2
+			 RETURNING updated_at`,
+			storyID, scene.ID, scene.Source,
+		).Scan(&scene.UpdatedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrSceneNotFound
+		}
+		return err
+	})
+}
+
+func (r *DraftRepoPG) DeleteScene(ctx context.Context, storyID, sceneID string) error {
+	return r.writeScene(ctx, storyID, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx,
+			`DELETE FROM draft_scenes WHERE story_id = $1 AND scene_id = $2`,
+			storyID, sceneID,
+		)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrSceneNotFound
+		}
+		return nil
+	})
+}
+
+// writeScene меняет сцены черновика в транзакции, которая сначала отмечает
+// изменение черновика. Строка черновика блокируется, поэтому правки сцен
+// не пересекаются с публикацией, читающей черновик под той же блокировкой.
+func (r *DraftRepoPG) writeScene(ctx context.Context, storyID string, write func(pgx.Tx) error) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `UPDATE story_drafts SET updated_at = now() WHERE story_id = $1`, storyID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDraftNotFound
+	}
+	if err := write(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *DraftRepoPG) LoadDraft(ctx context.Context, storyID string) (string, []domain.DraftScene, error) {
+	return loadDraft(ctx, r.DB, storyID, false)
+}
+
+// loadDraft читает черновик через q; lock — заблокировать строку черновика до конца транзакции.
+func loadDraft(ctx context.Context, q interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}, storyID string, lock bool) (string, []domain.DraftScene, error) {
+	query := `SELECT manifest FROM story_drafts WHERE story_id = $1`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	var manifest string
+	err := q.QueryRow(ctx, query, storyID).Scan(&manifest)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, ErrDraftNotFound
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	rows, err := q.Query(ctx,
+		`SELECT scene_id, source, updated_at FROM draft_scenes WHERE story_id = $1 ORDER BY scene_id`,
+		storyID,
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+	var scenes []domain.DraftScene
+	for rows.Next() {
+		var sc domain.DraftScene
+		if err := rows.Scan(&sc.ID, &sc.Source, &sc.UpdatedAt); err != nil {
+			return "", nil, err
+		}
+		scenes = append(scenes, sc)
+	}
+	return manifest, scenes, rows.Err()
+}
+
+func (r *DraftRepoPG) ReplaceDraft(ctx context.Context, storyID, manifest string, scenes []domain.DraftScene) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// 1. Манифест; строка черновика блокируется до конца транзакции
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO story_drafts (story_id, manifest, updated_at) VALUES ($1, $2, now())
+		 ON CONFLICT (story_id) DO UPDATE SET manifest = EXCLUDED.manifest, updated_at = EXCLUDED.updated_at`,
+		storyID, manifest,
+	); err != nil {
+		return err
+	}
+
+	// 2. Сцены целиком
+	if _, err := tx.Exec(ctx, `DELETE FROM draft_scenes WHERE story_id = $1`, storyID); err != nil {
+		return err
+	}
+	for _, sc := range scenes {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO draft_scenes (story_id, scene_id, source, updated_at) VALUES ($1, $2, $3, now())`,
+			storyID, sc.ID, sc.Source,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *DraftRepoPG) Publish(ctx context.Context, storyID string, publishedBy uuid.UUID, minVersion int, check PublishCheck) (int, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// 1. Черновик под блокировкой: правки сцен ждут окончания публикации
+	manifest, scenes, err := loadDraft(ctx, tx, storyID, true)
+	if err != nil {
+		return 0, err
+	}
+
+	// 2. Номер версии
+	var version int
+	if err := tx.QueryRow(ctx,
+		`SELECT coalesce(max(version), 0) + 1 FROM story_versions WHERE story_id = $1`,
+		storyID,
+	).Scan(&version); err != nil {
+		return 0, err
+	}
+	version = max(version, minVersion)
+
+	// 3. Проверка того, что будет опубликовано
+	if err := check(version, manifest, scenes); err != nil {
+		return 0, err
+	}
+
+	// 4. Неизменяемая копия черновика
+	var by *uuid.UUID
+	if publishedBy != uuid.Nil {
+		by = &publishedBy
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO story_versions (story_id, version, manifest, published_by, published_at) VALUES ($1, $2, $3, $4, $5)`,
+		storyID, version, manifest, by, time.Now(),
+	); err != nil {
+		return 0, err
+	}
+	for _, sc := range scenes {
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO published_scenes (story_id, version, scene_id, source) VALUES ($1, $2, $3, $4)`,
+			storyID, version, sc.ID, sc.Source,
+		); err != nil {
+			return 0, err
+		}
+	}
+	return version, tx.Commit(ctx)
+}
+
+func (r *DraftRepoPG) Versions(ctx context.Context, storyID string) ([]domain.StoryVersion, error) {
+	rows, err := r.DB.Query(ctx,
+		`
+		SELECT v.version, v.published_by, v.published_at, count(s.scene_id)
+		FROM story_versions v
+		LEFT JOIN published_scenes s ON s.story_id = v.story_id AND s.version = v.version
+		WHERE v.story_id = $1
+		GROUP BY v.version, v.published_by, v.published_at
+		ORDER BY v.version DESC
+		`,
+		storyID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []domain.StoryVersion{}
+	for rows.Next() {
+		v := domain.StoryVersion{StoryID: storyID}
+		if err := rows.Scan(&v.Version, &v.PublishedBy, &v.PublishedAt, &v.Scenes); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
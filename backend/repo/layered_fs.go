@@ -0,0 +1,123 @@
+package repo
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// LayeredFS — файловая система из нескольких слоёв, где каждый следующий слой
+// перекрывает предыдущие: файл берётся из самого верхнего слоя, в котором он есть,
+// а содержимое папок объединяется. Так поверх базовой истории (встроенной в бинарник
+// или из архива) накладывается папка с заплатками, заменяющая отдельные сцены:
+//
+//	NewLayeredFS(stories.FS, os.DirFS("/srv/patches"))
+//
+// Удалить файл нижнего слоя заплаткой нельзя.
+type LayeredFS struct {
+	Layers []fs.FS // от нижнего к верхнему
+}
+
+// NewLayeredFS — конструктор; layers перечисляются от базового слоя к верхнему.
+func NewLayeredFS(layers ...fs.FS) *LayeredFS {
+	return &LayeredFS{Layers: layers}
+}
+
+// Open открывает файл из самого верхнего слоя, где он есть.
+// Папка открывается с объединённым содержимым всех слоёв.
+func (l *LayeredFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	// 1. Самый верхний слой, в котором есть name
+	for i := len(l.Layers) - 1; i >= 0; i-- {
+		f, err := l.Layers[i].Open(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if !info.IsDir() {
+			return f, nil
+		}
+		f.Close()
+
+		// 2. Папка — объединяем содержимое всех слоёв
+		entries, err := l.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &layeredDir{info: info, entries: entries}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir возвращает объединённое содержимое папки, упорядоченное по имени;
+// при совпадении имён побеждает верхний слой.
+func (l *LayeredFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	merged := map[string]fs.DirEntry{}
+	found := false
+	for _, layer := range l.Layers {
+		entries, err := fs.ReadDir(layer, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, e := range entries {
+			merged[e.Name()] = e
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	out := make([]fs.DirEntry, 0, len(merged))
+	for _, e := range merged {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out, nil
+}
+
+// layeredDir — открытая папка LayeredFS с заранее объединённым содержимым.
+type layeredDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	off     int
+}
+
+func (d *layeredDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *layeredDir) Close() error { return nil }
+
+func (d *layeredDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+// ReadDir выдаёт содержимое папки порциями по правилам fs.ReadDirFile.
+func (d *layeredDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.off:]
+	if n <= 0 {
+		d.off = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.off += n
+	return rest[:n], nil
+}
@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLayeredFS(t *testing.T) {
+	base := fstest.MapFS{
+		"ronin/story.yaml":    {Data: []byte("title: Ронин\nstart: intro\n")},
+		"ronin/intro.yaml":    {Data: []byte("id: intro\ntext: base\nchoices: [{id: go, next: hall}]\n")},
+		"ronin/hall.yaml":     {Data: []byte("id: hall\ntext: hall\nending: {id: end}\n")},
+		"monk/story.yaml":     {Data: []byte("title: Монах\nstart: gate\n")},
+		"monk/gate.yaml":      {Data: []byte("id: gate\ntext: gate\nending: {id: end}\n")},
+		"ronin/locales/.keep": {Data: nil},
+	}
+	patch := fstest.MapFS{
+		"ronin/intro.yaml":      {Data: []byte("id: intro\ntext: patched\nchoices: [{id: go, next: hall}]\n")},
+		"ronin/locales/en.yaml": {Data: []byte("intro.text: patched in English\n")},
+	}
+	layered := NewLayeredFS(base, patch)
+	if err := fstest.TestFS(layered, "ronin/story.yaml", "ronin/intro.yaml", "ronin/hall.yaml", "ronin/locales/en.yaml", "monk/gate.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCatalog(layered, nil)
+	if err := c.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	scenes, err := c.Scenes("ronin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intro, _ := scenes.Load("intro"); intro.Text != "patched" {
+		t.Errorf("intro text = %q; want patched", intro.Text)
+	}
+	if hall, err := scenes.Load("hall"); err != nil || hall.Text != "hall" {
+		t.Errorf("hall = %+v, %v; want base scene", hall, err)
+	}
+	if tr := scenes.(Translator).Translation("en"); tr["intro.text"] != "patched in English" {
+		t.Errorf("en translation = %v", tr)
+	}
+	if len(c.Stories()) != 2 {
+		t.Errorf("stories = %+v", c.Stories())
+	}
+}
+
+func TestCatalogFromZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range map[string]string{
+		"ronin/story.yaml": "title: Ронин\nstart: intro\n",
+		"ronin/intro.yaml": "id: intro\ntext: from zip\nending: {id: end}\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(data))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var root fs.FS = zr
+	c := NewCatalog(root, nil)
+	if err := c.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	scenes, err := c.Scenes("ronin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if intro, err := scenes.Load("intro"); err != nil || intro.Text != "from zip" {
+		t.Errorf("intro = %+v, %v", intro, err)
+	}
+	if _, err := c.Fingerprint(); err != nil {
+		t.Errorf("Fingerprint over zip: %v", err)
+	}
+}
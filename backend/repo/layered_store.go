@@ -0,0 +1,111 @@
+package repo
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+)
+
+// LayeredStore — StoryStore из нескольких хранилищ, где каждое следующее
+// перекрывает предыдущие: история берётся из самого верхнего хранилища, в котором
+// она есть. Так опубликованные в базе версии заменяют одноимённые истории из файлов.
+// Переводы, которых у верхнего хранилища нет, берутся из нижних (см. Source):
+//
+//	NewLayeredStore(NewFSStore(stories.FS), NewStoryRepoPG(db))
+type LayeredStore struct {
+	Stores []StoryStore // от нижнего к верхнему
+}
+
+// NewLayeredStore — конструктор; stores перечисляются от нижнего к верхнему.
+func NewLayeredStore(stores ...StoryStore) *LayeredStore {
+	return &LayeredStore{Stores: stores}
+}
+
+// owners возвращает для каждой истории самое верхнее хранилище, где она есть.
+func (l *LayeredStore) owners() (map[string]StoryStore, error) {
+	owners := map[string]StoryStore{}
+	for _, s := range l.Stores {
+		ids, err := s.StoryIDs()
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			owners[id] = s
+		}
+	}
+	return owners, nil
+}
+
+// StoryIDs возвращает идентификаторы историй всех хранилищ без повторов.
+func (l *LayeredStore) StoryIDs() ([]string, error) {
+	owners, err := l.owners()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(owners))
+	for id := range owners {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Source возвращает источник истории из самого верхнего хранилища, где она есть.
+// Если этот источник не хранит переводов (как опубликованные в базе версии),
+// переводы берутся из ближайшего нижнего хранилища с той же историей:
+// публикация из базы не должна отключать locales/*.yaml из файлов.
+func (l *LayeredStore) Source(storyID string) (StorySource, error) {
+	// 1. Хранилища с этой историей, от верхнего к нижнему
+	var holders []StoryStore
+	for i := len(l.Stores) - 1; i >= 0; i-- {
+		ids, err := l.Stores[i].StoryIDs()
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(ids, storyID) {
+			holders = append(holders, l.Stores[i])
+		}
+	}
+	if len(holders) == 0 {
+		return nil, ErrStoryNotFound
+	}
+
+	// 2. Сцены и манифест — из верхнего, переводы — из первого, где они есть
+	src, err := holders[0].Source(storyID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := src.(TranslationSource); ok {
+		return src, nil
+	}
+	for _, s := range holders[1:] {
+		lower, err := s.Source(storyID)
+		if err != nil {
+			return nil, err
+		}
+		if ts, ok := lower.(TranslationSource); ok {
+			return translatedSource{StorySource: src, TranslationSource: ts}, nil
+		}
+	}
+	return src, nil
+}
+
+// translatedSource — источник истории с переводами из другого источника.
+type translatedSource struct {
+	StorySource
+	TranslationSource
+}
+
+// Fingerprint объединяет отпечатки всех хранилищ.
+func (l *LayeredStore) Fingerprint() (string, error) {
+	h := fnv.New64a()
+	for i, s := range l.Stores {
+		fp, err := s.Fingerprint()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%d|%s\n", i, fp)
+	}
+	return fmt.Sprintf("%x", h.Sum64()), nil
+}
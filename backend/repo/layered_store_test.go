@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLayeredStore(t *testing.T) {
+	files := fstest.MapFS{
+		"ronin/" + StoryManifestFile: {Data: []byte("title: Ронин\nversion: 1\n")},
+		"ronin/intro.yaml":           {Data: []byte("id: intro\ntext: из файлов\n")},
+		"monk/" + StoryManifestFile:  {Data: []byte("title: Монах\n")},
+		"monk/intro.yaml":            {Data: []byte("id: intro\ntext: монах\n")},
+	}
+	published := fstest.MapFS{
+		"ronin/" + StoryManifestFile: {Data: []byte("title: Ронин\nversion: 2\n")},
+		"ronin/intro.yaml":           {Data: []byte("id: intro\ntext: из базы\n")},
+	}
+	c := NewCatalogFromStore(NewLayeredStore(NewFSStore(files), NewFSStore(published)), nil)
+	if err := c.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	// Верхнее хранилище перекрывает историю целиком, остальные истории остаются
+	stories := c.Stories()
+	if len(stories) != 2 || stories[0].ID != "monk" || stories[1].Version != 2 {
+		t.Fatalf("stories = %+v", stories)
+	}
+	scenes, err := c.Scenes("ronin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scene, err := scenes.Load("intro"); err != nil || scene.Text != "из базы" {
+		t.Errorf("ronin intro = %+v, %v; want the upper layer", scene, err)
+	}
+
+	// Отпечаток меняется при изменении любого слоя
+	before, _ := c.Fingerprint()
+	published["ronin/intro.yaml"] = &fstest.MapFile{Data: []byte("id: intro\ntext: новая версия\n")}
+	if after, _ := c.Fingerprint(); after == before {
+		t.Error("fingerprint did not change after the upper layer changed")
+	}
+}
+
+// bareStore отдаёт источники без переводов, как опубликованные в базе версии.
+type bareStore struct{ *FSStore }
+
+func (s bareStore) Source(storyID string) (StorySource, error) {
+	src, err := s.FSStore.Source(storyID)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ StorySource }{src}, nil
+}
+
+func TestLayeredStoreKeepsFileTranslations(t *testing.T) {
+	files := fstest.MapFS{
+		"ronin/" + StoryManifestFile: {Data: []byte("title: Ронин\nversion: 1\n")},
+		"ronin/intro.yaml":           {Data: []byte("id: intro\ntext: из файлов\n")},
+		"ronin/locales/en.yaml":      {Data: []byte("intro.text: From the files\n")},
+	}
+	published := fstest.MapFS{
+		"ronin/" + StoryManifestFile: {Data: []byte("title: Ронин\nversion: 2\n")},
+		"ronin/intro.yaml":           {Data: []byte("id: intro\ntext: из базы\n")},
+	}
+	c := NewCatalogFromStore(NewLayeredStore(NewFSStore(files), bareStore{NewFSStore(published)}), nil)
+	if err := c.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	scenes, err := c.Scenes("ronin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scene, _ := scenes.Load("intro"); scene.Text != "из базы" {
+		t.Errorf("intro text = %q; want the published version", scene.Text)
+	}
+	tr, ok := scenes.(Translator)
+	if !ok {
+		t.Fatal("published story scenes do not implement Translator")
+	}
+	if en := tr.Translation("en"); en["intro.text"] != "From the files" {
+		t.Errorf("en translation = %v; want the file translation", en)
+	}
+	if locales := scenes.Story().Locales; len(locales) != 2 || locales[1] != "en" {
+		t.Errorf("locales = %v; want source locale and en", locales)
+	}
+}
@@ -2,12 +2,37 @@ package repo
 
 import (
 	"context"
+	"errors"
 
 	"blood-on-maple-leaves/backend/domain"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrPlayerNotFound — игрок не найден.
+var ErrPlayerNotFound = errors.New("player not found")
+
+// ErrUsernameTaken — имя пользователя уже занято.
+var ErrUsernameTaken = errors.New("username already exists")
+
+// pgUniqueViolation — код ошибки Postgres при нарушении уникальности.
+const pgUniqueViolation = "23505"
+
+// LocaleRepo — хранилище языковых настроек игроков.
+// Пустой язык означает, что игрок не выбирал язык и его берут из Accept-Language.
+type LocaleRepo interface {
+	GetLocale(ctx context.Context, playerID uuid.UUID) (string, error)
+	SetLocale(ctx context.Context, playerID uuid.UUID, locale string) error
+}
+
+// PlayerFinder — поиск игрока по ID; если игрока нет, возвращает ErrPlayerNotFound.
+type PlayerFinder interface {
+	GetByID(ctx context.Context, id string) (*domain.Player, error)
+}
+
 type PlayerRepo struct {
 	DB *pgxpool.Pool
 }
@@ -22,6 +47,11 @@ func (r *PlayerRepo) Create(ctx context.Context, p *domain.Player) error {
 		 VALUES ($1, $2, $3, $4)`,
 		p.ID, p.Username, p.PasswordHash, p.CreatedAt,
 	)
+	// Параллельная регистрация с тем же именем упирается в UNIQUE(username)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		return ErrUsernameTaken
+	}
 	return err
 }
 
@@ -41,10 +71,13 @@ func (r *PlayerRepo) ExistsByUsername(ctx context.Context, username string) (boo
 func (r *PlayerRepo) GetByUsername(ctx context.Context, username string) (*domain.Player, error) {
 	var p domain.Player
 	err := r.DB.QueryRow(ctx,
-		`SELECT id, username, password_hash, created_at FROM players WHERE username = $1`,
+		`SELECT id, username, password_hash, locale, created_at FROM players WHERE username = $1`,
 		username,
-	).Scan(&p.ID, &p.Username, &p.PasswordHash, &p.CreatedAt)
+	).Scan(&p.ID, &p.Username, &p.PasswordHash, &p.Locale, &p.CreatedAt)
 
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPlayerNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -54,11 +87,58 @@ func (r *PlayerRepo) GetByUsername(ctx context.Context, username string) (*domai
 func (r *PlayerRepo) GetByID(ctx context.Context, id string) (*domain.Player, error) {
 	var p domain.Player
 	err := r.DB.QueryRow(ctx,
-		`SELECT id, username, password_hash, created_at FROM players WHERE id = $1`,
+		`SELECT id, username, password_hash, locale, created_at FROM players WHERE id = $1`,
 		id,
-	).Scan(&p.ID, &p.Username, &p.PasswordHash, &p.CreatedAt)
+	).Scan(&p.ID, &p.Username, &p.PasswordHash, &p.Locale, &p.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPlayerNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &p, nil
 }
+
+// GetLocale возвращает выбранный игроком язык или пустую строку, если язык не выбран.
+func (r *PlayerRepo) GetLocale(ctx context.Context, playerID uuid.UUID) (string, error) {
+	var locale string
+	err := r.DB.QueryRow(ctx,
+		`SELECT locale FROM players WHERE id = $1`,
+		playerID,
+	).Scan(&locale)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrPlayerNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return locale, nil
+}
+
+// SetLocale сохраняет язык игрока; пустая строка сбрасывает выбор.
+func (r *PlayerRepo) SetLocale(ctx context.Context, playerID uuid.UUID, locale string) error {
+	tag, err := r.DB.Exec(ctx,
+		`UPDATE players SET locale = $2 WHERE id = $1`,
+		playerID, locale,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrPlayerNotFound
+	}
+	return nil
+}
+
+// IsAdmin сообщает, может ли игрок редактировать и публиковать истории.
+func (r *PlayerRepo) IsAdmin(ctx context.Context, id string) (bool, error) {
+	var admin bool
+	err := r.DB.QueryRow(ctx,
+		`SELECT is_admin FROM players WHERE id = $1`,
+		id,
+	).Scan(&admin)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, ErrPlayerNotFound
+	}
+	return admin, err
+}
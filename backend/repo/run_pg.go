@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"context"
+
+	"blood-on-maple-leaves/backend/domain"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RunRepo — контракт для работы с завершёнными прохождениями
+type RunRepo interface {
+	// Finish записывает сохранение save, которым закончилось прохождение, и само
+	// прохождение run в одной транзакции: либо оба, либо ничего.
+	Finish(ctx context.Context, save domain.Save, run domain.CompletedRun) error
+	// ListByPlayer возвращает прохождения игрока во всех историях, новые первыми.
+	ListByPlayer(ctx context.Context, playerID uuid.UUID) ([]domain.CompletedRun, error)
+}
+
+// RunRepoPG — реализация RunRepo через pgxpool.Pool
+type RunRepoPG struct {
+	DB *pgxpool.Pool
+}
+
+// NewRunRepoPG — конструктор, принимает пул Postgres.
+func NewRunRepoPG(db *pgxpool.Pool) *RunRepoPG {
+	return &RunRepoPG{DB: db}
+}
+
+func (r *RunRepoPG) Finish(ctx context.Context, save domain.Save, run domain.CompletedRun) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// 1. Сохранение с концовкой, как при SaveRepoPG.Create
+	if err := insertSave(ctx, tx, save); err != nil {
+		return err
+	}
+
+	// 2. Запись в журнале прохождений
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO completed_runs
+		 (id, player_id, story_id, scene_id, ending_id, ending_title, ending_category, stats, flags, finished_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		run.ID, run.PlayerID, run.StoryID, run.SceneID, run.Ending.ID, run.Ending.Title, run.Ending.Category,
+		run.Stats, flagsOrEmpty(run.Flags), run.FinishedAt,
+	); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *RunRepoPG) ListByPlayer(ctx context.Context, playerID uuid.UUID) ([]domain.CompletedRun, error) {
+	rows, err := r.DB.Query(ctx,
+		`SELECT id, player_id, story_id, scene_id, ending_id, ending_title, ending_category, stats, flags, finished_at
+		 FROM completed_runs
+		 WHERE player_id = $1
+		 ORDER BY finished_at DESC`,
+		playerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []domain.CompletedRun{}
+	for rows.Next() {
+		var run domain.CompletedRun
+		if err := rows.Scan(&run.ID, &run.PlayerID, &run.StoryID, &run.SceneID,
+			&run.Ending.ID, &run.Ending.Title, &run.Ending.Category,
+			&run.Stats, &run.Flags, &run.FinishedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
@@ -3,17 +3,41 @@ package repo
 import (
 	"blood-on-maple-leaves/backend/domain"
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// SaveRepo — контракт для работы с saves
+// ErrSaveNotFound — у игрока ещё нет ни одного сохранения.
+var ErrSaveNotFound = errors.New("save not found")
+
+// SaveRepo — контракт для работы с saves.
+// Слоты и активный слот у игрока свои в каждой истории.
 type SaveRepo interface {
-	// Create сохраняет новую запись в таблицу saves.
+	// Create сохраняет новую запись в таблицу saves и делает её слот активным в истории s.StoryID.
 	Create(ctx context.Context, s domain.Save) error
-	// GetLatestByPlayer возвращает последнее сохранение для данного игрока.
-	GetLatestByPlayer(ctx context.Context, playerID uuid.UUID) (domain.Save, error)
+	// GetLatestByPlayer возвращает последнее сохранение активного слота игрока в истории.
+	// Если сохранений нет, возвращает ErrSaveNotFound.
+	GetLatestByPlayer(ctx context.Context, playerID uuid.UUID, storyID string) (domain.Save, error)
+	// ListSlots возвращает слоты игрока в истории, недавно игранные первыми.
+	ListSlots(ctx context.Context, playerID uuid.UUID, storyID string) ([]domain.SaveSlot, error)
+	// ActivateSlot делает слот активным и возвращает его последнее сохранение.
+	// Если слота нет, возвращает ErrSaveNotFound.
+	ActivateSlot(ctx context.Context, playerID uuid.UUID, storyID, slot string) (domain.Save, error)
+	// RenameSlot меняет отображаемое имя слота.
+	RenameSlot(ctx context.Context, playerID uuid.UUID, storyID, slot, name string) error
+	// DeleteSlot удаляет слот вместе со всеми его сохранениями.
+	DeleteSlot(ctx context.Context, playerID uuid.UUID, storyID, slot string) error
+	// ListHistory возвращает снимки слота, новые первыми, начиная с offset.
+	ListHistory(ctx context.Context, playerID uuid.UUID, storyID, slot string, limit, offset int) ([]domain.Save, error)
+	// GetByID возвращает снимок игрока по идентификатору.
+	// Если снимка нет или он чужой, возвращает ErrSaveNotFound.
+	GetByID(ctx context.Context, playerID, saveID uuid.UUID) (domain.Save, error)
+	// ListOutdated возвращает последние сохранения всех слотов истории,
+	// сделанные не на версии version, упорядоченные по игроку и слоту.
+	ListOutdated(ctx context.Context, storyID string, version int) ([]domain.Save, error)
 }
 
 // SaveRepoPG — конкретная реализация SaveRepo через pgxpool.Pool
@@ -28,31 +52,280 @@ func NewSaveRepoPG(db *pgxpool.Pool) *SaveRepoPG {
 	}
 }
 
+// saveColumns — колонки saves в порядке, который ожидает scanSave.
+const saveColumns = `id, player_id, story_id, story_version, slot, scene_id, stats, flags, ending_id, rng_seed, rng_rolls, roll, created_at`
+
+// scanSave читает одну строку saves, выбранную с колонками saveColumns.
+func scanSave(row pgx.Row) (domain.Save, error) {
+	var s domain.Save
+	err := row.Scan(&s.ID, &s.PlayerID, &s.StoryID, &s.StoryVersion, &s.Slot, &s.SceneID, &s.Stats, &s.Flags, &s.EndingID,
+		&s.Seed, &s.Rolls, &s.Roll, &s.CreatedAt)
+	return s, err
+}
+
 // todo: (SaveRepoPG) Create
 func (r *SaveRepoPG) Create(ctx context.Context, s domain.Save) error {
-	_, err := r.DB.Exec(
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertSave(ctx, tx, s); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// insertSave записывает снимок в транзакции tx и делает его слот активным.
+func insertSave(ctx context.Context, tx pgx.Tx, s domain.Save) error {
+	if s.Slot == "" {
+		s.Slot = domain.DefaultSlot
+	}
+
+	// 1. Сам снимок состояния
+	if _, err := tx.Exec(
+		ctx,
+		`INSERT INTO saves (`+saveColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		s.ID, s.PlayerID, s.StoryID, s.StoryVersion, s.Slot, s.SceneID, s.Stats, flagsOrEmpty(s.Flags), s.EndingID,
+		s.Seed, s.Rolls, s.Roll, s.CreatedAt,
+	); err != nil {
+		return err
+	}
+
+	// 2. Слот: создаём при первой записи, обновляем время последней игры
+	if _, err := tx.Exec(
 		ctx,
-		`INSERT INTO saves (id, player_id, scene_id, honor, rage, karma, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		s.ID, s.PlayerID, s.SceneID, s.Honor, s.Rage, s.Karma, s.CreatedAt,
+		`INSERT INTO save_slots (player_id, story_id, slot, name, active, updated_at)
+		 VALUES ($1, $2, $3, $3, false, $4)
+		 ON CONFLICT (player_id, story_id, slot) DO UPDATE SET updated_at = EXCLUDED.updated_at`,
+		s.PlayerID, s.StoryID, s.Slot, s.CreatedAt,
+	); err != nil {
+		return err
+	}
+
+	// 3. Слот, в который пишем, становится активным
+	return activate(ctx, tx, s.PlayerID, s.StoryID, s.Slot)
+}
+
+// activate помечает slot активным, а остальные слоты игрока в той же истории — неактивными.
+// Два запроса вместо одного: уникальный индекс на активный слот проверяется построчно.
+func activate(ctx context.Context, tx pgx.Tx, playerID uuid.UUID, storyID, slot string) error {
+	if _, err := tx.Exec(ctx,
+		`UPDATE save_slots SET active = false WHERE player_id = $1 AND story_id = $2 AND active AND slot <> $3`,
+		playerID, storyID, slot,
+	); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx,
+		`UPDATE save_slots SET active = true WHERE player_id = $1 AND story_id = $2 AND slot = $3`,
+		playerID, storyID, slot,
 	)
 	return err
 }
 
 // todo: (SaveRepoPG) GetLatestByPlayer
-func (r *SaveRepoPG) GetLatestByPlayer(ctx context.Context, playerID uuid.UUID) (domain.Save, error) {
-	var s domain.Save
+func (r *SaveRepoPG) GetLatestByPlayer(ctx context.Context, playerID uuid.UUID, storyID string) (domain.Save, error) {
 	row := r.DB.QueryRow(
 		ctx,
 		`
-		SELECT id, player_id, scene_id, honor, rage, karma, created_at
+		SELECT `+saveColumns+`
 		FROM saves
 		WHERE player_id = $1
+		  AND story_id = $2
+		  AND slot = (SELECT slot FROM save_slots WHERE player_id = $1 AND story_id = $2 AND active)
 		ORDER BY created_at DESC
 		LIMIT 1
 		`,
-		playerID,
+		playerID, storyID,
+	)
+	s, err := scanSave(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, ErrSaveNotFound
+	}
+	return s, err
+}
+
+func (r *SaveRepoPG) ListSlots(ctx context.Context, playerID uuid.UUID, storyID string) ([]domain.SaveSlot, error) {
+	rows, err := r.DB.Query(
+		ctx,
+		`
+		SELECT sl.slot, sl.name, sl.active, sl.updated_at, s.scene_id, s.stats, s.ending_id, s.story_version
+		FROM save_slots sl
+		JOIN LATERAL (
+			SELECT scene_id, stats, ending_id, story_version
+			FROM saves
+			WHERE player_id = sl.player_id AND story_id = sl.story_id AND slot = sl.slot
+			ORDER BY created_at DESC
+			LIMIT 1
+		) s ON true
+		WHERE sl.player_id = $1 AND sl.story_id = $2
+		ORDER BY sl.updated_at DESC
+		`,
+		playerID, storyID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slots := []domain.SaveSlot{}
+	for rows.Next() {
+		var (
+			sl       domain.SaveSlot
+			endingID string
+		)
+		if err := rows.Scan(&sl.Slot, &sl.Name, &sl.Active, &sl.LastPlayed,
+			&sl.SceneID, &sl.Stats, &endingID, &sl.StoryVersion); err != nil {
+			return nil, err
+		}
+		sl.Finished = endingID != ""
+		slots = append(slots, sl)
+	}
+	return slots, rows.Err()
+}
+
+func (r *SaveRepoPG) ActivateSlot(ctx context.Context, playerID uuid.UUID, storyID, slot string) (domain.Save, error) {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return domain.Save{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	s, err := scanSave(tx.QueryRow(
+		ctx,
+		`SELECT `+saveColumns+` FROM saves WHERE player_id = $1 AND story_id = $2 AND slot = $3 ORDER BY created_at DESC LIMIT 1`,
+		playerID, storyID, slot,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Save{}, ErrSaveNotFound
+	}
+	if err != nil {
+		return domain.Save{}, err
+	}
+
+	if err := activate(ctx, tx, playerID, storyID, slot); err != nil {
+		return domain.Save{}, err
+	}
+	return s, tx.Commit(ctx)
+}
+
+func (r *SaveRepoPG) RenameSlot(ctx context.Context, playerID uuid.UUID, storyID, slot, name string) error {
+	tag, err := r.DB.Exec(ctx,
+		`UPDATE save_slots SET name = $4 WHERE player_id = $1 AND story_id = $2 AND slot = $3`,
+		playerID, storyID, slot, name,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSaveNotFound
+	}
+	return nil
+}
+
+func (r *SaveRepoPG) DeleteSlot(ctx context.Context, playerID uuid.UUID, storyID, slot string) error {
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`DELETE FROM save_slots WHERE player_id = $1 AND story_id = $2 AND slot = $3`,
+		playerID, storyID, slot,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSaveNotFound
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM saves WHERE player_id = $1 AND story_id = $2 AND slot = $3`,
+		playerID, storyID, slot,
+	); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (r *SaveRepoPG) ListHistory(ctx context.Context, playerID uuid.UUID, storyID, slot string, limit, offset int) ([]domain.Save, error) {
+	rows, err := r.DB.Query(
+		ctx,
+		`
+		SELECT `+saveColumns+`
+		FROM saves
+		WHERE player_id = $1 AND story_id = $2 AND slot = $3
+		ORDER BY created_at DESC
+		LIMIT $4 OFFSET $5
+		`,
+		playerID, storyID, slot, limit, offset,
 	)
-	err := row.Scan(&s.ID, &s.PlayerID, &s.SceneID,
-		&s.Honor, &s.Rage, &s.Karma, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	saves := []domain.Save{}
+	for rows.Next() {
+		s, err := scanSave(rows)
+		if err != nil {
+			return nil, err
+		}
+		saves = append(saves, s)
+	}
+	return saves, rows.Err()
+}
+
+func (r *SaveRepoPG) GetByID(ctx context.Context, playerID, saveID uuid.UUID) (domain.Save, error) {
+	s, err := scanSave(r.DB.QueryRow(
+		ctx,
+		`SELECT `+saveColumns+` FROM saves WHERE id = $1 AND player_id = $2`,
+		saveID, playerID,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, ErrSaveNotFound
+	}
 	return s, err
 }
+
+func (r *SaveRepoPG) ListOutdated(ctx context.Context, storyID string, version int) ([]domain.Save, error) {
+	rows, err := r.DB.Query(
+		ctx,
+		`
+		SELECT `+saveColumns+`
+		FROM (
+			SELECT DISTINCT ON (player_id, slot) `+saveColumns+`
+			FROM saves
+			WHERE story_id = $1
+			ORDER BY player_id, slot, created_at DESC
+		) latest
+		WHERE story_version <> $2
+		ORDER BY player_id, slot
+		`,
+		storyID, version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	saves := []domain.Save{}
+	for rows.Next() {
+		s, err := scanSave(rows)
+		if err != nil {
+			return nil, err
+		}
+		saves = append(saves, s)
+	}
+	return saves, rows.Err()
+}
+
+// flagsOrEmpty заменяет nil на пустой срез: колонка flags объявлена NOT NULL.
+func flagsOrEmpty(flags []string) []string {
+	if flags == nil {
+		return []string{}
+	}
+	return flags
+}
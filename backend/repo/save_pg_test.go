@@ -48,25 +48,30 @@ func TestSaveRepoPG_CreateAndGetLatest(t *testing.T) {
 
 	repo := NewSaveRepoPG(pool)
 	playerID := uuid.New()
-	save1 := domain.Save{ID: uuid.New(), PlayerID: playerID, SceneID: "intro", Honor: 0, Rage: 0, Karma: 0, CreatedAt: time.Now()}
+	save1 := domain.Save{ID: uuid.New(), PlayerID: playerID, StoryID: "ronin", SceneID: "intro", Stats: map[string]int{"honor": 0, "rage": 0, "karma": 0}, CreatedAt: time.Now()}
 	if err := repo.Create(context.Background(), save1); err != nil {
 		t.Fatalf("Create failed: %v", err)
 	}
 	// wait a bit and insert second
 	time.Sleep(10 * time.Millisecond)
-	save2 := domain.Save{ID: uuid.New(), PlayerID: playerID, SceneID: "hallway", Honor: 0, Rage: 1, Karma: 0, CreatedAt: time.Now()}
+	save2 := domain.Save{ID: uuid.New(), PlayerID: playerID, StoryID: "ronin", SceneID: "hallway", Stats: map[string]int{"honor": 0, "rage": 1, "karma": 0},
+		Seed: 42, Rolls: 1, Roll: &domain.Roll{Kind: domain.RollCheck, Stat: "rage", Dice: 20, Value: 7, Total: 7, Difficulty: 10, Outcome: "failure"},
+		CreatedAt: time.Now()}
 	if err := repo.Create(context.Background(), save2); err != nil {
 		t.Fatalf("Create failed: %v", err)
 	}
 
-	latest, err := repo.GetLatestByPlayer(context.Background(), playerID)
+	latest, err := repo.GetLatestByPlayer(context.Background(), playerID, "ronin")
 	if err != nil {
 		t.Fatalf("GetLatestByPlayer failed: %v", err)
 	}
 	if latest.SceneID != "hallway" {
 		t.Errorf("expected latest scene 'hallway', got '%s'", latest.SceneID)
 	}
-	if latest.Rage != 1 {
-		t.Errorf("expected rage=1, got=%d", latest.Rage)
+	if latest.Stats["rage"] != 1 {
+		t.Errorf("expected rage=1, got=%d", latest.Stats["rage"])
+	}
+	if latest.Seed != 42 || latest.Rolls != 1 || latest.Roll == nil || *latest.Roll != *save2.Roll {
+		t.Errorf("roll state = %d/%d/%+v; want %d/%d/%+v", latest.Seed, latest.Rolls, latest.Roll, save2.Seed, save2.Rolls, save2.Roll)
 	}
 }
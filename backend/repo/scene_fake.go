@@ -1,21 +1,65 @@
 package repo
 
 import (
-	"errors"
+	"sort"
 
 	"blood-on-maple-leaves/backend/domain"
 )
 
 // FakeSceneRepo — фейковая реализация SceneRepo для тестов.
 type FakeSceneRepo struct {
-	Scenes map[string]domain.Scene
+	Scenes   map[string]domain.Scene
+	Manifest domain.Story // манифест, который отдаёт Story
 }
 
 // Load возвращает сцену по ID или ошибку, если нет в карте.
 func (f *FakeSceneRepo) Load(id string) (domain.Scene, error) {
 	scene, ok := f.Scenes[id]
 	if !ok {
-		return domain.Scene{}, errors.New("scene not found")
+		return domain.Scene{}, ErrSceneNotFound
 	}
 	return scene, nil
 }
+
+// List возвращает идентификаторы сцен из карты.
+func (f *FakeSceneRepo) List() ([]string, error) {
+	ids := make([]string, 0, len(f.Scenes))
+	for id := range f.Scenes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Story возвращает манифест истории.
+func (f *FakeSceneRepo) Story() domain.Story {
+	return f.Manifest
+}
+
+// FakeCatalog — фейковая реализация StoryCatalog: истории по ID.
+type FakeCatalog map[string]*FakeSceneRepo
+
+// Stories возвращает манифесты историй, упорядоченные по ID; ID берётся из ключа.
+func (c FakeCatalog) Stories() []domain.Story {
+	ids := make([]string, 0, len(c))
+	for id := range c {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	stories := make([]domain.Story, 0, len(ids))
+	for _, id := range ids {
+		story := c[id].Manifest
+		story.ID = id
+		stories = append(stories, story)
+	}
+	return stories
+}
+
+// Scenes возвращает сцены истории или ErrStoryNotFound.
+func (c FakeCatalog) Scenes(storyID string) (StoryScenes, error) {
+	scenes, ok := c[storyID]
+	if !ok {
+		return nil, ErrStoryNotFound
+	}
+	return scenes, nil
+}
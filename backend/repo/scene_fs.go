@@ -1,47 +1,196 @@
 package repo
 
 import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"path"
+	"sort"
+	"strings"
 
 	"blood-on-maple-leaves/backend/domain"
 
+	"golang.org/x/text/language"
 	"gopkg.in/yaml.v3"
 )
 
-// SceneRepo — интерфейс, описывающий загрузку сцен
+// ErrSceneNotFound — в истории нет сцены с таким идентификатором.
+var ErrSceneNotFound = errors.New("scene not found")
+
+// ErrSceneInvalid — файл сцены есть, но не разбирается.
+var ErrSceneInvalid = errors.New("scene is invalid")
+
+// SceneRepo — интерфейс, описывающий загрузку сцен.
+// Если сцены нет, Load возвращает ErrSceneNotFound.
 type SceneRepo interface {
 	Load(sceneID string) (domain.Scene, error)
+	// List возвращает идентификаторы всех сцен истории в алфавитном порядке.
+	List() ([]string, error)
 }
 
-// SceneRepoFS — файловая реализация, читает YAML-файлы из папки
+// SceneRepoFS — файловая реализация, читает YAML-файлы сцен из fs.FS,
+// корнем которой служит папка истории. Чтение ограничено этой папкой:
+// идентификаторы проверяются по domain.ValidSceneID, а fs.FS не принимает
+// пути с ".." и абсолютные пути.
 type SceneRepoFS struct {
-	BasePath string // путь к папке со сценами (например, "./scenes")
+	FS fs.FS // папка со сценами (например, os.DirFS("./scenes"))
 }
 
 // NewSceneRepoFS — конструктор, принимает путь к папке
 func NewSceneRepoFS(basePath string) *SceneRepoFS {
-	return &SceneRepoFS{BasePath: basePath}
+	return NewSceneRepoFromFS(os.DirFS(basePath))
+}
+
+// NewSceneRepoFromFS — конструктор поверх произвольной fs.FS (встроенной, в памяти и т.п.).
+func NewSceneRepoFromFS(fsys fs.FS) *SceneRepoFS {
+	return &SceneRepoFS{FS: fsys}
+}
+
+// StoryManifestFile — имя файла манифеста истории в папке со сценами.
+const StoryManifestFile = "story.yaml"
+
+// ParseStory разбирает YAML манифеста истории.
+func ParseStory(data []byte) (domain.Story, error) {
+	var story domain.Story
+	err := yaml.Unmarshal(data, &story)
+	return story, err
+}
+
+// ParseScene разбирает YAML сцены sceneID; ошибка разбора оборачивает ErrSceneInvalid.
+func ParseScene(sceneID string, data []byte) (domain.Scene, error) {
+	var scene domain.Scene
+	if err := yaml.Unmarshal(data, &scene); err != nil {
+		return scene, fmt.Errorf("%w: %s: %v", ErrSceneInvalid, sceneID, err)
+	}
+	return scene, nil
 }
 
-// Load загружает YAML-файл и возвращает сцену
+// LoadStory читает манифест истории (story.yaml) из папки со сценами.
+func (r *SceneRepoFS) LoadStory() (domain.Story, error) {
+	data, err := fs.ReadFile(r.FS, StoryManifestFile)
+	if err != nil {
+		return domain.Story{}, err
+	}
+	return ParseStory(data)
+}
+
+// Load загружает YAML-файл и возвращает сцену.
+// Для идентификаторов вне грамматики сцен сразу возвращает ErrSceneNotFound.
 func (r *SceneRepoFS) Load(sceneID string) (domain.Scene, error) {
 	var scene domain.Scene
 
-	// 1. Формируем путь к файлу безопасно
-	path := filepath.Join(r.BasePath, sceneID+".yaml")
+	// 1. Проверяем идентификатор: он становится именем файла
+	if !domain.ValidSceneID(sceneID) {
+		return scene, ErrSceneNotFound
+	}
 
-	// 2. Читаем YAML-файл
-	data, err := os.ReadFile(path)
+	// 2. Читаем YAML-файл; отсутствие файла — это отсутствие сцены
+	data, err := fs.ReadFile(r.FS, sceneID+".yaml")
+	if errors.Is(err, fs.ErrNotExist) {
+		return scene, ErrSceneNotFound
+	}
 	if err != nil {
-		return scene, err
+		return scene, fmt.Errorf("read scene %q: %w", sceneID, err)
 	}
 
 	// 3. Парсим YAML в структуру
-	err = yaml.Unmarshal(data, &scene)
+	return ParseScene(sceneID, data)
+}
+
+// List перечисляет YAML-файлы сцен в папке (кроме манифеста) и возвращает их идентификаторы.
+// Файлы с именами вне грамматики сцен тоже попадают в список, чтобы проверка истории
+// могла о них сообщить.
+func (r *SceneRepoFS) List() ([]string, error) {
+	entries, err := fs.ReadDir(r.FS, ".")
 	if err != nil {
-		return scene, err
+		return nil, err
 	}
 
-	return scene, nil
+	var ids []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || name == StoryManifestFile || !strings.HasSuffix(name, ".yaml") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, ".yaml"))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// LocalesDir — папка переводов внутри папки истории: locales/<язык>.yaml.
+// Файл перевода — плоский YAML «ключ строки: текст», например:
+//
+//	intro.text: "The rain has not stopped for three days..."
+//	intro.choice.fight: "Draw the sword"
+const LocalesDir = "locales"
+
+// LoadTranslations читает переводы истории из LocalesDir. Имя файла — тег языка BCP 47
+// (en, en-GB, pt-BR); ключи карты — канонические теги. Если папки нет, переводов нет.
+func (r *SceneRepoFS) LoadTranslations() (map[string]domain.Translation, error) {
+	entries, err := fs.ReadDir(r.FS, LocalesDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	translations := make(map[string]domain.Translation, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".yaml") {
+			continue
+		}
+
+		// 1. Имя файла — тег языка
+		tag, err := language.Parse(strings.TrimSuffix(name, ".yaml"))
+		if err != nil {
+			return nil, fmt.Errorf("translation %q: invalid locale: %w", name, err)
+		}
+		locale := tag.String()
+		if _, dup := translations[locale]; dup {
+			return nil, fmt.Errorf("translation %q: duplicate locale %s", name, locale)
+		}
+
+		// 2. Плоская карта строк
+		data, err := fs.ReadFile(r.FS, path.Join(LocalesDir, name))
+		if err != nil {
+			return nil, fmt.Errorf("read translation %q: %w", name, err)
+		}
+		var t domain.Translation
+		if err := yaml.Unmarshal(data, &t); err != nil {
+			return nil, fmt.Errorf("translation %q: %w", name, err)
+		}
+		if t == nil {
+			t = domain.Translation{}
+		}
+		translations[locale] = t
+	}
+	return translations, nil
+}
+
+// Fingerprint возвращает отпечаток папки со сценами и папки переводов
+// по именам, размерам и времени изменения файлов.
+func (r *SceneRepoFS) Fingerprint() (string, error) {
+	h := fnv.New64a()
+	for _, dir := range []string{".", LocalesDir} {
+		entries, err := fs.ReadDir(r.FS, dir)
+		if dir == LocalesDir && errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "%s|%d|%d\n", path.Join(dir, e.Name()), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum64()), nil
 }
@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSceneRepoFSLoadStaysInsideStory(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "secret.yaml", "id: secret\ntext: outside the story\n")
+	dir := filepath.Join(root, "scenes")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "intro.yaml", "id: intro\ntext: hello\n")
+
+	r := NewSceneRepoFS(dir)
+	if scene, err := r.Load("intro"); err != nil || scene.Text != "hello" {
+		t.Fatalf("Load(intro) = %+v, %v", scene, err)
+	}
+	for _, id := range []string{"../secret", "..", "/etc/passwd", "Intro", "intro.yaml", "a/b", "", "intro\x00"} {
+		if _, err := r.Load(id); !errors.Is(err, ErrSceneNotFound) {
+			t.Errorf("Load(%q) error = %v, want ErrSceneNotFound", id, err)
+		}
+	}
+}
+
+func TestSceneIndexLoadsTranslations(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, StoryManifestFile, "title: Ронин\nstart: intro\n")
+	writeFile(t, dir, "intro.yaml", "id: intro\ntext: Дождь\n")
+	if err := os.Mkdir(filepath.Join(dir, LocalesDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, LocalesDir), "en.yaml", "story.title: Ronin\nintro.text: Rain\n")
+	writeFile(t, filepath.Join(dir, LocalesDir), "pt-br.yaml", "intro.text: Chuva\n")
+
+	ix := NewSceneIndex(NewSceneRepoFS(dir), nil)
+	if err := ix.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := ix.Story().Locales; len(got) != 3 || got[0] != "ru" || got[1] != "en" || got[2] != "pt-BR" {
+		t.Errorf("Locales = %v; want [ru en pt-BR]", got)
+	}
+	if got := ix.Translation("en")["intro.text"]; got != "Rain" {
+		t.Errorf("en intro.text = %q; want Rain", got)
+	}
+	if ix.Translation("de") != nil {
+		t.Error("Translation(de) should be nil")
+	}
+
+	// Файл с неверным тегом языка — ошибка перезагрузки, прежняя версия остаётся
+	writeFile(t, filepath.Join(dir, LocalesDir), "english!.yaml", "intro.text: Rain\n")
+	if err := ix.Reload(); err == nil {
+		t.Error("Reload with invalid locale file should fail")
+	}
+	if ix.Translation("en") == nil {
+		t.Error("previous translations should stay published")
+	}
+}
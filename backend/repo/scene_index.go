@@ -0,0 +1,228 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"blood-on-maple-leaves/backend/domain"
+)
+
+// StorySource — источник истории для SceneIndex: сцены и манифест.
+type StorySource interface {
+	SceneRepo
+	LoadStory() (domain.Story, error)
+}
+
+// StoryProvider — репозиторий сцен, который знает и манифест своей истории,
+// чтобы манифест обновлялся вместе со сценами.
+type StoryProvider interface {
+	Story() domain.Story
+}
+
+// Fingerprinter — источник, умеющий дёшево сообщить, изменилось ли содержимое.
+type Fingerprinter interface {
+	Fingerprint() (string, error)
+}
+
+// TranslationSource — источник, у которого есть переводы истории: язык → перевод.
+type TranslationSource interface {
+	LoadTranslations() (map[string]domain.Translation, error)
+}
+
+// Translator — репозиторий сцен, который хранит переводы своей истории.
+// Translation возвращает nil, если перевода на язык нет.
+type Translator interface {
+	Translation(locale string) domain.Translation
+}
+
+// ValidateFunc проверяет собранную историю перед публикацией; ошибка отменяет публикацию.
+type ValidateFunc func(story domain.Story, scenes map[string]domain.Scene) error
+
+// storySnapshot — неизменяемая скомпилированная версия истории.
+type storySnapshot struct {
+	story        domain.Story
+	scenes       map[string]domain.Scene
+	ids          []string
+	translations map[string]domain.Translation
+	loadedAt     time.Time
+}
+
+// SceneIndex — SceneRepo, держащий всю историю в памяти.
+// Чтение идёт без блокировок из текущего снимка; Reload собирает новый снимок
+// из Source, проверяет его и атомарно подменяет. Если новая версия не прошла
+// проверку, продолжает работать старая.
+//
+// Сцены, которые возвращает Load, разделяют срезы и карты со снимком,
+// поэтому вызывающий код не должен их изменять.
+type SceneIndex struct {
+	Source   StorySource
+	Validate ValidateFunc // nil — без проверки
+
+	current  atomic.Pointer[storySnapshot]
+	reloadMu sync.Mutex // сериализует Reload, чтение от него не зависит
+}
+
+// NewSceneIndex — конструктор; история не загружена, пока не вызван Reload.
+func NewSceneIndex(source StorySource, validate ValidateFunc) *SceneIndex {
+	return &SceneIndex{Source: source, Validate: validate}
+}
+
+// Reload читает все сцены и манифест из Source, проверяет их и публикует новый снимок.
+func (ix *SceneIndex) Reload() error {
+	ix.reloadMu.Lock()
+	defer ix.reloadMu.Unlock()
+
+	// 1. Манифест и список сцен
+	story, err := ix.Source.LoadStory()
+	if err != nil {
+		return fmt.Errorf("load story manifest: %w", err)
+	}
+	ids, err := ix.Source.List()
+	if err != nil {
+		return fmt.Errorf("list scenes: %w", err)
+	}
+
+	// 2. Все сцены целиком. Файлы с именами вне грамматики сцен пропускаются
+	// с записью в лог: играть в них нельзя, но один лишний файл не должен
+	// снимать историю с публикации
+	scenes := make(map[string]domain.Scene, len(ids))
+	valid := ids[:0:0]
+	for _, id := range ids {
+		if !domain.ValidSceneID(id) {
+			log.Printf("story %q: scene %q skipped: invalid scene id", story.ID, id)
+			continue
+		}
+		valid = append(valid, id)
+		scene, err := ix.Source.Load(id)
+		if err != nil {
+			return fmt.Errorf("load scene %q: %w", id, err)
+		}
+		scenes[id] = scene
+	}
+
+	// 3. Переводы, если источник их хранит; вставки в них должны разбираться
+	var translations map[string]domain.Translation
+	if ts, ok := ix.Source.(TranslationSource); ok {
+		if translations, err = ts.LoadTranslations(); err != nil {
+			return fmt.Errorf("load translations: %w", err)
+		}
+	}
+	for locale, t := range translations {
+		for key, text := range t {
+			if _, err := domain.ParseTemplate(text); err != nil {
+				return fmt.Errorf("translation %s: %s: %w", locale, key, err)
+			}
+		}
+	}
+	story.Locales = []string{story.SourceLocale()}
+	for locale := range translations {
+		if locale != story.SourceLocale() {
+			story.Locales = append(story.Locales, locale)
+		}
+	}
+	sort.Strings(story.Locales[1:])
+
+	// 4. Проверка перед публикацией
+	if ix.Validate != nil {
+		if err := ix.Validate(story, scenes); err != nil {
+			return err
+		}
+	}
+
+	sort.Strings(valid)
+	ix.current.Store(&storySnapshot{
+		story:        story,
+		scenes:       scenes,
+		ids:          valid,
+		translations: translations,
+		loadedAt:     time.Now(),
+	})
+	return nil
+}
+
+// Load возвращает сцену из текущего снимка.
+func (ix *SceneIndex) Load(sceneID string) (domain.Scene, error) {
+	snap := ix.current.Load()
+	if snap == nil {
+		return domain.Scene{}, ErrSceneNotFound
+	}
+	scene, ok := snap.scenes[sceneID]
+	if !ok {
+		return domain.Scene{}, ErrSceneNotFound
+	}
+	return scene, nil
+}
+
+// List возвращает идентификаторы сцен текущего снимка.
+func (ix *SceneIndex) List() ([]string, error) {
+	snap := ix.current.Load()
+	if snap == nil {
+		return nil, nil
+	}
+	return append([]string(nil), snap.ids...), nil
+}
+
+// Story возвращает манифест текущего снимка.
+func (ix *SceneIndex) Story() domain.Story {
+	snap := ix.current.Load()
+	if snap == nil {
+		return domain.Story{}
+	}
+	return snap.story
+}
+
+// Translation возвращает перевод истории на язык locale из текущего снимка или nil.
+func (ix *SceneIndex) Translation(locale string) domain.Translation {
+	snap := ix.current.Load()
+	if snap == nil {
+		return nil
+	}
+	return snap.translations[locale]
+}
+
+// LoadedAt возвращает время публикации текущего снимка.
+func (ix *SceneIndex) LoadedAt() time.Time {
+	snap := ix.current.Load()
+	if snap == nil {
+		return time.Time{}
+	}
+	return snap.loadedAt
+}
+
+// Watch раз в interval сверяет отпечаток Source и перезагружает историю при изменениях.
+// Результат каждой перезагрузки передаётся в onReload. Работает, пока не отменён ctx;
+// если Source не умеет Fingerprinter, сразу возвращается.
+func (ix *SceneIndex) Watch(ctx context.Context, interval time.Duration, onReload func(error)) {
+	fp, ok := ix.Source.(Fingerprinter)
+	if !ok {
+		return
+	}
+	watchFingerprint(ctx, interval, fp, ix.Reload, onReload)
+}
+
+// watchFingerprint раз в interval сверяет отпечаток fp и при изменении вызывает reload,
+// передавая результат в onReload. Работает, пока не отменён ctx.
+func watchFingerprint(ctx context.Context, interval time.Duration, fp Fingerprinter, reload func() error, onReload func(error)) {
+	last, _ := fp.Fingerprint()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cur, err := fp.Fingerprint()
+			if err != nil || cur == last {
+				continue
+			}
+			last = cur
+			onReload(reload())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"blood-on-maple-leaves/backend/domain"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// requireNextExists — простая проверка для тестов: все переходы ведут в существующие сцены.
+func requireNextExists(_ domain.Story, scenes map[string]domain.Scene) error {
+	for _, scene := range scenes {
+		for _, c := range scene.Choices {
+			if _, ok := scenes[c.Next]; !ok {
+				return errors.New("dangling next: " + c.Next)
+			}
+		}
+	}
+	return nil
+}
+
+func TestSceneIndexReload(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, StoryManifestFile, "start: intro\n")
+	writeFile(t, dir, "intro.yaml", "id: intro\ntext: v1\nchoices:\n  - id: go\n    next: end\n")
+	writeFile(t, dir, "end.yaml", "id: end\ntext: fin\n")
+
+	ix := NewSceneIndex(NewSceneRepoFS(dir), requireNextExists)
+	if _, err := ix.Load("intro"); !errors.Is(err, ErrSceneNotFound) {
+		t.Fatalf("Load before Reload = %v; want ErrSceneNotFound", err)
+	}
+	if err := ix.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if ix.Story().Start != "intro" {
+		t.Errorf("story start = %q", ix.Story().Start)
+	}
+	if ids, _ := ix.List(); len(ids) != 2 {
+		t.Errorf("List = %v; want 2 scenes", ids)
+	}
+
+	// Битая версия не публикуется, продолжает работать старая
+	writeFile(t, dir, "intro.yaml", "id: intro\ntext: v2\nchoices:\n  - id: go\n    next: missing\n")
+	if err := ix.Reload(); err == nil {
+		t.Fatal("Reload of broken story expected error")
+	}
+	if scene, _ := ix.Load("intro"); scene.Text != "v1" {
+		t.Errorf("text after failed reload = %q; want v1", scene.Text)
+	}
+
+	// Изменения подхватываются наблюдателем
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloaded := make(chan error, 1)
+	go ix.Watch(ctx, 10*time.Millisecond, func(err error) { reloaded <- err })
+
+	time.Sleep(20 * time.Millisecond)
+	writeFile(t, dir, "intro.yaml", "id: intro\ntext: v3 fixed\nchoices:\n  - id: go\n    next: end\n")
+	select {
+	case err := <-reloaded:
+		if err != nil {
+			t.Fatalf("watch reload failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher did not reload")
+	}
+	if scene, _ := ix.Load("intro"); scene.Text != "v3 fixed" {
+		t.Errorf("text after watch reload = %q", scene.Text)
+	}
+}
+
+func TestSceneIndexSkipsInvalidFileNames(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, StoryManifestFile, "id: demo\nstart: intro\n")
+	writeFile(t, dir, "intro.yaml", "id: intro\ntext: v1\n")
+	writeFile(t, dir, "Draft Copy.yaml", "id: intro\ntext: copy\n")
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	ix := NewSceneIndex(NewSceneRepoFS(dir), nil)
+	if err := ix.Reload(); err != nil {
+		t.Fatalf("Reload with a stray file failed: %v", err)
+	}
+	if ids, _ := ix.List(); len(ids) != 1 || ids[0] != "intro" {
+		t.Errorf("List = %v; want only intro", ids)
+	}
+	if got := logged.String(); !strings.Contains(got, `story "demo": scene "Draft Copy" skipped`) {
+		t.Errorf("log = %q; want the skipped file reported with the story id", got)
+	}
+}
@@ -0,0 +1,170 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"blood-on-maple-leaves/backend/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// storyQueryTimeout ограничивает запросы StoryRepoPG: интерфейсы каталога
+// не принимают context, а перезагрузка не должна зависать на базе.
+const storyQueryTimeout = 10 * time.Second
+
+// StoryRepoPG — StoryStore поверх опубликованных версий в Postgres:
+// каждая история, у которой есть хотя бы одна версия, отдаётся в последней версии.
+type StoryRepoPG struct {
+	DB *pgxpool.Pool
+
+	mu      sync.Mutex
+	sources map[string]*publishedStory
+}
+
+// NewStoryRepoPG — конструктор, принимает пул Postgres.
+func NewStoryRepoPG(db *pgxpool.Pool) *StoryRepoPG {
+	return &StoryRepoPG{DB: db, sources: map[string]*publishedStory{}}
+}
+
+// StoryIDs возвращает истории, у которых есть опубликованные версии.
+func (r *StoryRepoPG) StoryIDs() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storyQueryTimeout)
+	defer cancel()
+
+	rows, err := r.DB.Query(ctx, `SELECT DISTINCT story_id FROM story_versions ORDER BY story_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Source возвращает источник последней опубликованной версии истории.
+func (r *StoryRepoPG) Source(storyID string) (StorySource, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.sources[storyID]; ok {
+		return s, nil
+	}
+	s := &publishedStory{db: r.DB, id: storyID}
+	r.sources[storyID] = s
+	return s, nil
+}
+
+// Fingerprint меняется с каждой публикацией: это номера последних версий всех историй.
+func (r *StoryRepoPG) Fingerprint() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storyQueryTimeout)
+	defer cancel()
+
+	var fp string
+	err := r.DB.QueryRow(ctx, `
+		SELECT coalesce(string_agg(story_id || ':' || version, ',' ORDER BY story_id), '')
+		FROM (SELECT story_id, max(version) AS version FROM story_versions GROUP BY story_id) v
+	`).Scan(&fp)
+	return fp, err
+}
+
+// publishedStory — источник одной истории из Postgres. LoadStory читает последнюю
+// версию целиком, а List и Load отдают сцены этой же версии, поэтому
+// публикация посреди перезагрузки не смешивает сцены разных версий.
+type publishedStory struct {
+	db *pgxpool.Pool
+	id string
+
+	mu     sync.Mutex
+	scenes map[string]string // ID сцены → исходный YAML
+}
+
+// LoadStory читает последнюю версию и возвращает её манифест с номером версии.
+func (p *publishedStory) LoadStory() (domain.Story, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storyQueryTimeout)
+	defer cancel()
+
+	// 1. Манифест последней версии
+	var (
+		version  int
+		manifest string
+	)
+	err := p.db.QueryRow(ctx,
+		`SELECT version, manifest FROM story_versions WHERE story_id = $1 ORDER BY version DESC LIMIT 1`,
+		p.id,
+	).Scan(&version, &manifest)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Story{}, ErrStoryNotFound
+	}
+	if err != nil {
+		return domain.Story{}, err
+	}
+	story, err := ParseStory([]byte(manifest))
+	if err != nil {
+		return story, err
+	}
+	if story.ID != "" && story.ID != p.id {
+		return story, fmt.Errorf("manifest id %q does not match story %q", story.ID, p.id)
+	}
+	story.ID = p.id
+	story.Version = version
+
+	// 2. Сцены этой же версии
+	rows, err := p.db.Query(ctx,
+		`SELECT scene_id, source FROM published_scenes WHERE story_id = $1 AND version = $2`,
+		p.id, version,
+	)
+	if err != nil {
+		return story, err
+	}
+	defer rows.Close()
+	scenes := map[string]string{}
+	for rows.Next() {
+		var id, src string
+		if err := rows.Scan(&id, &src); err != nil {
+			return story, err
+		}
+		scenes[id] = src
+	}
+	if err := rows.Err(); err != nil {
+		return story, err
+	}
+
+	p.mu.Lock()
+	p.scenes = scenes
+	p.mu.Unlock()
+	return story, nil
+}
+
+// List возвращает сцены версии, прочитанной последним LoadStory.
+func (p *publishedStory) List() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make([]string, 0, len(p.scenes))
+	for id := range p.scenes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Load разбирает сцену версии, прочитанной последним LoadStory.
+func (p *publishedStory) Load(sceneID string) (domain.Scene, error) {
+	p.mu.Lock()
+	src, ok := p.scenes[sceneID]
+	p.mu.Unlock()
+	if !ok {
+		return domain.Scene{}, ErrSceneNotFound
+	}
+	return ParseScene(sceneID, []byte(src))
+}
@@ -2,13 +2,38 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
-// TokenRepo — структура для работы с Redis
+// RefreshTTL — срок жизни refresh-токена; при ротации новый токен получает полный срок.
+const RefreshTTL = 30 * 24 * time.Hour
+
+// ErrRefreshTokenNotFound — refresh-токена нет: он не выдавался, истёк или отозван.
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+// ErrRefreshTokenReused — предъявлен уже обменянный refresh-токен; всё семейство отозвано.
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+
+// TokenRepo — структура для работы с Redis.
+//
+// Токены одного входа образуют семейство: при каждой ротации старый токен
+// помечается использованным, а новый продолжает то же семейство. Ключи:
+//
+//	refresh:<token>          — хеш { user, family, used }, TTL RefreshTTL
+//	refresh_family:<family>  — текущий живой токен семейства, TTL RefreshTTL
+//	refresh_user:<user>      — множество семейств игрока; TTL продлевается до RefreshTTL
+//	                           при каждой выдаче токена, поэтому индекс живёт не меньше
+//	                           любого живого токена игрока
+//
+// Использованный токен хранится до своего истечения, чтобы его повторное
+// предъявление распознавалось как кража и отзывало семейство. Истёкшие семейства
+// остаются в индексе до его собственного истечения или до выхода со всех устройств.
 type TokenRepo struct {
 	RDB *redis.Client // клиент Redis
 }
@@ -18,20 +43,209 @@ func NewTokenRepo(rdb *redis.Client) *TokenRepo {
 	return &TokenRepo{RDB: rdb}
 }
 
-// SaveRefreshToken сохраняет refresh-токен в Redis с TTL 30 дней
+func refreshKey(token string) string { return fmt.Sprintf("refresh:%s", token) }
+
+func familyKey(family string) string { return fmt.Sprintf("refresh_family:%s", family) }
+
+func userKey(userID string) string { return fmt.Sprintf("refresh_user:%s", userID) }
+
+// SaveRefreshToken сохраняет refresh-токен нового семейства (новый вход) с TTL 30 дней
 func (r *TokenRepo) SaveRefreshToken(ctx context.Context, token, userID string) error {
-	key := fmt.Sprintf("refresh:%s", token) // ключ в Redis
-	return r.RDB.Set(ctx, key, userID, 30*24*time.Hour).Err()
+	family := uuid.NewString()
+	_, err := r.RDB.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, refreshKey(token), "user", userID, "family", family)
+		p.Expire(ctx, refreshKey(token), RefreshTTL)
+		p.Set(ctx, familyKey(family), token, RefreshTTL)
+		p.SAdd(ctx, userKey(userID), family)
+		p.Expire(ctx, userKey(userID), RefreshTTL)
+		return nil
+	})
+	return err
 }
 
-// GetUserIDByRefresh возвращает userID по refresh-токену
+// GetUserIDByRefresh возвращает userID по действующему refresh-токену.
+// Использованный или неизвестный токен — ErrRefreshTokenNotFound.
 func (r *TokenRepo) GetUserIDByRefresh(ctx context.Context, token string) (string, error) {
-	key := fmt.Sprintf("refresh:%s", token)
-	return r.RDB.Get(ctx, key).Result()
+	vals, err := r.RDB.HMGet(ctx, refreshKey(token), "user", "used").Result()
+	if isWrongType(err) {
+		// токен, выданный до появления семейств, хранится строкой с userID
+		userID, err := r.RDB.Get(ctx, refreshKey(token)).Result()
+		if errors.Is(err, redis.Nil) {
+			return "", ErrRefreshTokenNotFound
+		}
+		return userID, err
+	}
+	if err != nil {
+		return "", err
+	}
+	userID, _ := vals[0].(string)
+	if userID == "" || vals[1] != nil {
+		return "", ErrRefreshTokenNotFound
+	}
+	return userID, nil
+}
+
+// rotateScript атомарно обменивает KEYS[1] (старый токен) на KEYS[2] (новый).
+// ARGV: 1 — TTL нового токена в секундах, 2 — новый токен, 3 — семейство для
+// токена старого формата (строка с userID). Ключи семейства и индекса игрока
+// вычисляются внутри скриптов, поэтому они рассчитаны на один узел Redis, а не на кластер.
+// Возвращает {status, userID}, где status — ok, reused или missing.
+var rotateScript = redis.NewScript(`
+local user, family
+local kind = redis.call('TYPE', KEYS[1]).ok
+if kind == 'string' then
+	user = redis.call('GET', KEYS[1])
+	family = ARGV[3]
+	local ttl = redis.call('PTTL', KEYS[1])
+	redis.call('DEL', KEYS[1])
+	redis.call('HSET', KEYS[1], 'user', user, 'family', family, 'used', '1')
+	if ttl > 0 then
+		redis.call('PEXPIRE', KEYS[1], ttl)
+	end
+elseif kind == 'hash' then
+	local f = redis.call('HMGET', KEYS[1], 'user', 'family', 'used')
+	user, family = f[1], f[2]
+	if f[3] then
+		local live = redis.call('GET', 'refresh_family:' .. family)
+		if live then
+			redis.call('DEL', 'refresh:' .. live)
+		end
+		redis.call('DEL', 'refresh_family:' .. family)
+		redis.call('SREM', 'refresh_user:' .. user, family)
+		return {'reused', user}
+	end
+	redis.call('HSET', KEYS[1], 'used', '1')
+else
+	return {'missing', ''}
+end
+redis.call('HSET', KEYS[2], 'user', user, 'family', family)
+redis.call('EXPIRE', KEYS[2], ARGV[1])
+redis.call('SET', 'refresh_family:' .. family, ARGV[2], 'EX', ARGV[1])
+redis.call('SADD', 'refresh_user:' .. user, family)
+redis.call('EXPIRE', 'refresh_user:' .. user, ARGV[1])
+return {'ok', user}
+`)
+
+// RotateRefreshToken обменивает refresh-токен oldToken на newToken и возвращает userID.
+// Старый токен становится использованным в той же атомарной операции, поэтому
+// из двух одновременных обменов одного токена успешен только один.
+// Если oldToken уже был обменян, семейство отзывается вместе с его живым токеном
+// и возвращается ErrRefreshTokenReused; неизвестный токен — ErrRefreshTokenNotFound.
+func (r *TokenRepo) RotateRefreshToken(ctx context.Context, oldToken, newToken string) (string, error) {
+	res, err := rotateScript.Run(ctx, r.RDB,
+		[]string{refreshKey(oldToken), refreshKey(newToken)},
+		int(RefreshTTL/time.Second), newToken, uuid.NewString(),
+	).StringSlice()
+	if err != nil {
+		return "", err
+	}
+	switch res[0] {
+	case "ok":
+		return res[1], nil
+	case "reused":
+		return "", ErrRefreshTokenReused
+	}
+	return "", ErrRefreshTokenNotFound
 }
 
-// DeleteRefreshToken — удаляет refresh-токен (logout)
+// logoutScript отзывает семейство токена KEYS[1]: его живой токен, сам токен
+// и запись в индексе игрока. Токен старого формата просто удаляется.
+var logoutScript = redis.NewScript(`
+local kind = redis.call('TYPE', KEYS[1]).ok
+if kind == 'string' then
+	return redis.call('DEL', KEYS[1])
+elseif kind ~= 'hash' then
+	return 0
+end
+local f = redis.call('HMGET', KEYS[1], 'user', 'family')
+local live = redis.call('GET', 'refresh_family:' .. f[2])
+if live then
+	redis.call('DEL', 'refresh:' .. live)
+end
+redis.call('DEL', 'refresh_family:' .. f[2], KEYS[1])
+redis.call('SREM', 'refresh_user:' .. f[1], f[2])
+return 1
+`)
+
+// DeleteRefreshToken — отзывает refresh-токен вместе с его семейством (logout).
+// Работает и для уже обменянного токена того же входа; неизвестный токен не ошибка.
 func (r *TokenRepo) DeleteRefreshToken(ctx context.Context, token string) error {
-	key := fmt.Sprintf("refresh:%s", token)
-	return r.RDB.Del(ctx, key).Err()
+	return logoutScript.Run(ctx, r.RDB, []string{refreshKey(token)}).Err()
+}
+
+// revokeAllScript отзывает все семейства из индекса игрока KEYS[1] и сам индекс.
+// Возвращает число семейств в индексе.
+var revokeAllScript = redis.NewScript(`
+local families = redis.call('SMEMBERS', KEYS[1])
+for _, family in ipairs(families) do
+	local live = redis.call('GET', 'refresh_family:' .. family)
+	if live then
+		redis.call('DEL', 'refresh:' .. live)
+	end
+	redis.call('DEL', 'refresh_family:' .. family)
+end
+redis.call('DEL', KEYS[1])
+return #families
+`)
+
+// DeleteUserRefreshTokens отзывает все refresh-токены игрока (выход со всех устройств).
+// Токены старого формата попадают в индекс при первом обмене или при
+// IndexLegacyRefreshTokens на старте, поэтому хватает одного индекса игрока.
+func (r *TokenRepo) DeleteUserRefreshTokens(ctx context.Context, userID string) error {
+	return revokeAllScript.Run(ctx, r.RDB, []string{userKey(userID)}).Err()
+}
+
+// indexLegacyScript переводит токен старого формата KEYS[1] (строка с userID)
+// в хеш нового семейства ARGV[1] и вносит семейство в индекс игрока.
+// ARGV[2] — сам токен. Срок жизни токена сохраняется, индекс живёт не меньше него.
+// Токен, уже переведённый или обменянный, не трогается. Возвращает 1, если токен переведён.
+var indexLegacyScript = redis.NewScript(`
+if redis.call('TYPE', KEYS[1]).ok ~= 'string' then
+	return 0
+end
+local user = redis.call('GET', KEYS[1])
+local ttl = redis.call('PTTL', KEYS[1])
+local family = 'refresh_family:' .. ARGV[1]
+local index = 'refresh_user:' .. user
+redis.call('DEL', KEYS[1])
+redis.call('HSET', KEYS[1], 'user', user, 'family', ARGV[1])
+redis.call('SET', family, ARGV[2])
+redis.call('SADD', index, ARGV[1])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+	redis.call('PEXPIRE', family, ttl)
+	local left = redis.call('PTTL', index)
+	if left >= 0 and left < ttl then
+		redis.call('PEXPIRE', index, ttl)
+	end
+else
+	redis.call('PERSIST', index)
+end
+return 1
+`)
+
+// IndexLegacyRefreshTokens переводит все токены, выданные до появления семейств,
+// в новый формат и вносит их в индексы игроков. Вызывается один раз на старте:
+// перебирает всё пространство ключей refresh:*, поэтому для запросов не годится.
+// Повторный вызов безопасен. Возвращает число переведённых токенов.
+func (r *TokenRepo) IndexLegacyRefreshTokens(ctx context.Context) (int, error) {
+	n := 0
+	prefix := refreshKey("")
+	iter := r.RDB.ScanType(ctx, 0, refreshKey("*"), 1000, "string").Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		done, err := indexLegacyScript.Run(ctx, r.RDB, []string{key},
+			uuid.NewString(), strings.TrimPrefix(key, prefix),
+		).Int()
+		if err != nil {
+			return n, err
+		}
+		n += done
+	}
+	return n, iter.Err()
+}
+
+// isWrongType сообщает, что ключ хранит значение другого типа.
+func isWrongType(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE")
 }
@@ -0,0 +1,136 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+func setupRedis(t *testing.T) (*redis.Client, func()) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx := context.Background()
+	req := testcontainers.ContainerRequest{
+		Image:        "redis:7",
+		ExposedPorts: []string{"6379/tcp"},
+		WaitingFor:   wait.ForListeningPort("6379/tcp"),
+	}
+	rC, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req, Started: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, _ := rC.Host(ctx)
+	port, _ := rC.MappedPort(ctx, "6379")
+	rdb := redis.NewClient(&redis.Options{Addr: host + ":" + port.Port()})
+	return rdb, func() {
+		rdb.Close()
+		rC.Terminate(ctx)
+	}
+}
+
+func TestTokenRepoRotation(t *testing.T) {
+	rdb, teardown := setupRedis(t)
+	defer teardown()
+	ctx := context.Background()
+	tokens := NewTokenRepo(rdb)
+
+	// 1. Обмен выдаёт токен того же игрока, старый перестаёт действовать
+	if err := tokens.SaveRefreshToken(ctx, "t1", "player-1"); err != nil {
+		t.Fatalf("SaveRefreshToken: %v", err)
+	}
+	if userID, err := tokens.RotateRefreshToken(ctx, "t1", "t2"); err != nil || userID != "player-1" {
+		t.Fatalf("rotate t1 = %q, %v; want player-1", userID, err)
+	}
+	if _, err := tokens.GetUserIDByRefresh(ctx, "t1"); !errors.Is(err, ErrRefreshTokenNotFound) {
+		t.Errorf("used token lookup: err = %v; want ErrRefreshTokenNotFound", err)
+	}
+	if ttl := rdb.TTL(ctx, "refresh:t2").Val(); ttl < RefreshTTL-time.Minute {
+		t.Errorf("new token TTL = %v; want about %v", ttl, RefreshTTL)
+	}
+
+	// 2. Повторный обмен использованного токена отзывает всё семейство
+	if _, err := tokens.RotateRefreshToken(ctx, "t1", "t3"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reuse of t1: err = %v; want ErrRefreshTokenReused", err)
+	}
+	if _, err := tokens.RotateRefreshToken(ctx, "t2", "t4"); !errors.Is(err, ErrRefreshTokenNotFound) {
+		t.Errorf("rotate t2 after reuse: err = %v; want ErrRefreshTokenNotFound", err)
+	}
+
+	// 3. Токен старого формата (строка с userID) обменивается и начинает семейство
+	rdb.Set(ctx, "refresh:legacy", "player-2", RefreshTTL)
+	if userID, err := tokens.RotateRefreshToken(ctx, "legacy", "t5"); err != nil || userID != "player-2" {
+		t.Fatalf("rotate legacy = %q, %v; want player-2", userID, err)
+	}
+	if _, err := tokens.RotateRefreshToken(ctx, "legacy", "t6"); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("reuse of legacy: err = %v; want ErrRefreshTokenReused", err)
+	}
+	if _, err := tokens.GetUserIDByRefresh(ctx, "t5"); !errors.Is(err, ErrRefreshTokenNotFound) {
+		t.Errorf("t5 after legacy reuse: err = %v; want ErrRefreshTokenNotFound", err)
+	}
+}
+
+func TestTokenRepoLogout(t *testing.T) {
+	rdb, teardown := setupRedis(t)
+	defer teardown()
+	ctx := context.Background()
+	tokens := NewTokenRepo(rdb)
+
+	// Два входа одного игрока и вход другого, у каждого ещё по токену старого формата
+	tokens.SaveRefreshToken(ctx, "phone", "player-1")
+	tokens.SaveRefreshToken(ctx, "laptop", "player-1")
+	tokens.SaveRefreshToken(ctx, "other", "player-2")
+	rdb.Set(ctx, "refresh:legacy-1", "player-1", RefreshTTL)
+	rdb.Set(ctx, "refresh:legacy-2", "player-2", RefreshTTL)
+	if _, err := tokens.RotateRefreshToken(ctx, "laptop", "laptop-2"); err != nil {
+		t.Fatalf("rotate laptop: %v", err)
+	}
+	if ttl := rdb.TTL(ctx, "refresh_user:player-1").Val(); ttl < RefreshTTL-time.Minute {
+		t.Errorf("user index TTL = %v; want about %v", ttl, RefreshTTL)
+	}
+
+	// 1. Выход по обменянному токену отзывает живой токен того же входа
+	if err := tokens.DeleteRefreshToken(ctx, "laptop"); err != nil {
+		t.Fatalf("DeleteRefreshToken: %v", err)
+	}
+	if _, err := tokens.GetUserIDByRefresh(ctx, "laptop-2"); !errors.Is(err, ErrRefreshTokenNotFound) {
+		t.Errorf("laptop-2 after logout: err = %v; want ErrRefreshTokenNotFound", err)
+	}
+	if n := rdb.SCard(ctx, "refresh_user:player-1").Val(); n != 1 {
+		t.Errorf("user index has %d families after logout; want 1", n)
+	}
+
+	// 2. Токены старого формата попадают в индекс на старте, выход со всех
+	// устройств отзывает их и не трогает других игроков
+	if n, err := tokens.IndexLegacyRefreshTokens(ctx); err != nil || n != 2 {
+		t.Fatalf("IndexLegacyRefreshTokens = %d, %v; want 2", n, err)
+	}
+	if n, err := tokens.IndexLegacyRefreshTokens(ctx); err != nil || n != 0 {
+		t.Errorf("second IndexLegacyRefreshTokens = %d, %v; want 0", n, err)
+	}
+	if userID, err := tokens.GetUserIDByRefresh(ctx, "legacy-1"); err != nil || userID != "player-1" {
+		t.Errorf("indexed legacy token = %q, %v; want player-1", userID, err)
+	}
+	if err := tokens.DeleteUserRefreshTokens(ctx, "player-1"); err != nil {
+		t.Fatalf("DeleteUserRefreshTokens: %v", err)
+	}
+	if _, err := tokens.GetUserIDByRefresh(ctx, "phone"); !errors.Is(err, ErrRefreshTokenNotFound) {
+		t.Errorf("phone after logout all: err = %v; want ErrRefreshTokenNotFound", err)
+	}
+	if _, err := tokens.RotateRefreshToken(ctx, "legacy-1", "legacy-1b"); !errors.Is(err, ErrRefreshTokenNotFound) {
+		t.Errorf("legacy token after logout all: err = %v; want ErrRefreshTokenNotFound", err)
+	}
+	for _, token := range []string{"other", "legacy-2"} {
+		if userID, err := tokens.GetUserIDByRefresh(ctx, token); err != nil || userID != "player-2" {
+			t.Errorf("other player's token %s = %q, %v; want player-2", token, userID, err)
+		}
+	}
+	if rdb.Exists(ctx, "refresh_user:player-1").Val() != 0 {
+		t.Errorf("user index survived logout all")
+	}
+}
@@ -10,6 +10,9 @@ import (
 	"blood-on-maple-leaves/backend/repo"
 )
 
+// accessTTL — срок жизни access-токена; по его истечении клиент обменивает refresh-токен.
+const accessTTL = 15 * time.Minute
+
 // Tokens — структура с двумя токенами, которую вернём клиенту
 type Tokens struct {
 	AccessToken  string `json:"access_token"`
@@ -40,7 +43,7 @@ func (s *AuthService) Signup(ctx context.Context, username, password string) (*T
 		return nil, err
 	}
 	if exists {
-		return nil, errors.New("username already exists")
+		return nil, ErrUsernameTaken
 	}
 
 	// 2. Создание игрока (ID + хеш пароля)
@@ -55,7 +58,7 @@ func (s *AuthService) Signup(ctx context.Context, username, password string) (*T
 	}
 
 	// 4. Генерация access-токена (TTL = 15 минут)
-	accessToken, err := token.GenerateAccessToken(player.ID.String(), 15*time.Minute)
+	accessToken, err := token.GenerateAccessToken(player.ID.String(), accessTTL)
 	if err != nil {
 		return nil, err
 	}
@@ -82,17 +85,20 @@ func (s *AuthService) Signup(ctx context.Context, username, password string) (*T
 func (s *AuthService) Login(ctx context.Context, username, password string) (*Tokens, error) {
 	// 1. Получаем игрока по username
 	player, err := s.PlayerRepo.GetByUsername(ctx, username)
+	if errors.Is(err, repo.ErrPlayerNotFound) {
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, err
 	}
 
 	// 2. Проверяем пароль
 	if !player.CheckPassword(password) {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
 	}
 
 	// 3. Генерация токенов
-	accessToken, err := token.GenerateAccessToken(player.ID.String(), 15*time.Minute)
+	accessToken, err := token.GenerateAccessToken(player.ID.String(), accessTTL)
 	if err != nil {
 		return nil, err
 	}
@@ -112,3 +118,52 @@ func (s *AuthService) Login(ctx context.Context, username, password string) (*To
 		RefreshToken: refreshToken,
 	}, nil
 }
+
+// Refresh обменивает refresh-токен на новую пару токенов (ротация).
+// Старый refresh-токен перестаёт действовать в той же операции. Если он уже
+// был обменян раньше, это признак кражи: отзываются все токены этого входа,
+// и возвращается ErrRefreshTokenReused. Неизвестный или истёкший токен — ErrInvalidRefreshToken.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// 1. Новый refresh-токен того же семейства
+	newRefresh, err := token.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. Атомарный обмен старого токена на новый
+	userID, err := s.TokenRepo.RotateRefreshToken(ctx, refreshToken, newRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Новый access-токен
+	accessToken, err := token.GenerateAccessToken(userID, accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: newRefresh,
+	}, nil
+}
+
+// Logout отзывает refresh-токен и все токены того же входа.
+// Неизвестный или уже отозванный токен не ошибка: повторный выход ничего не меняет.
+// Выданный ранее access-токен действует до своего истечения.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	if refreshToken == "" {
+		return ErrInvalidRefreshToken
+	}
+	return s.TokenRepo.DeleteRefreshToken(ctx, refreshToken)
+}
+
+// LogoutAll отзывает все refresh-токены игрока — выход со всех устройств.
+// Access-токены, как и при Logout, доживают свои 15 минут.
+func (s *AuthService) LogoutAll(ctx context.Context, playerID string) error {
+	return s.TokenRepo.DeleteUserRefreshTokens(ctx, playerID)
+}
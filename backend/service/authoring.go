@@ -0,0 +1,278 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/repo"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// AuthoringService — редактирование историй администраторами: черновик
+// в базе правится сцена за сценой, проверяется и публикуется новой версией.
+// Игра черновиков не видит: каталог читает только опубликованные версии.
+type AuthoringService struct {
+	Drafts  repo.DraftRepo
+	Stories repo.StoryCatalog // опубликованные истории: для checkout и номера следующей версии
+	Reload  func() error      // перечитать каталог после публикации; nil — подхватит наблюдатель каталога
+}
+
+// NewAuthoringService — конструктор.
+func NewAuthoringService(drafts repo.DraftRepo, stories repo.StoryCatalog, reload func() error) *AuthoringService {
+	return &AuthoringService{Drafts: drafts, Stories: stories, Reload: reload}
+}
+
+// Manifest возвращает YAML манифеста черновика.
+func (a *AuthoringService) Manifest(ctx context.Context, storyID string) (string, error) {
+	if !domain.ValidStoryID(storyID) {
+		return "", ErrStoryNotFound
+	}
+	return a.Drafts.GetManifest(ctx, storyID)
+}
+
+// PutManifest создаёт черновик истории или заменяет его манифест.
+// Манифест должен разбираться; ID в нём, если указан, совпадает с storyID.
+func (a *AuthoringService) PutManifest(ctx context.Context, storyID, source string) error {
+	if !domain.ValidStoryID(storyID) {
+		return fmt.Errorf("%w: invalid story id", ErrInvalidInput)
+	}
+	story, err := repo.ParseStory([]byte(source))
+	if err != nil {
+		return fmt.Errorf("%w: manifest: %v", ErrInvalidInput, err)
+	}
+	if story.ID != "" && story.ID != storyID {
+		return fmt.Errorf("%w: manifest id %q does not match story %q", ErrInvalidInput, story.ID, storyID)
+	}
+	return a.Drafts.PutManifest(ctx, storyID, source)
+}
+
+// Scenes возвращает сцены черновика без исходного текста.
+func (a *AuthoringService) Scenes(ctx context.Context, storyID string) ([]domain.DraftScene, error) {
+	if !domain.ValidStoryID(storyID) {
+		return nil, ErrDraftNotFound
+	}
+	return a.Drafts.ListScenes(ctx, storyID)
+}
+
+// Scene возвращает сцену черновика вместе с исходным YAML.
+func (a *AuthoringService) Scene(ctx context.Context, storyID, sceneID string) (domain.DraftScene, error) {
+	if !domain.ValidStoryID(storyID) || !domain.ValidSceneID(sceneID) {
+		return domain.DraftScene{}, ErrSceneNotFound
+	}
+	return a.Drafts.GetScene(ctx, storyID, sceneID)
+}
+
+// CreateScene добавляет в черновик новую сцену.
+func (a *AuthoringService) CreateScene(ctx context.Context, storyID, sceneID, source string) (domain.DraftScene, error) {
+	scene, err := draftScene(storyID, sceneID, source)
+	if err != nil {
+		return scene, err
+	}
+	return a.Drafts.CreateScene(ctx, storyID, scene)
+}
+
+// UpdateScene заменяет сцену черновика.
+func (a *AuthoringService) UpdateScene(ctx context.Context, storyID, sceneID, source string) (domain.DraftScene, error) {
+	scene, err := draftScene(storyID, sceneID, source)
+	if err != nil {
+		return scene, err
+	}
+	return a.Drafts.UpdateScene(ctx, storyID, scene)
+}
+
+// DeleteScene удаляет сцену из черновика.
+func (a *AuthoringService) DeleteScene(ctx context.Context, storyID, sceneID string) error {
+	if !domain.ValidStoryID(storyID) || !domain.ValidSceneID(sceneID) {
+		return ErrSceneNotFound
+	}
+	return a.Drafts.DeleteScene(ctx, storyID, sceneID)
+}
+
+// draftScene проверяет сцену перед записью в черновик: идентификаторы допустимы,
+// YAML разбирается, а ID внутри сцены совпадает с ID из пути.
+// Связность графа здесь не проверяется: черновик может быть недописан.
+func draftScene(storyID, sceneID, source string) (domain.DraftScene, error) {
+	scene := domain.DraftScene{ID: sceneID, Source: source}
+	if !domain.ValidStoryID(storyID) {
+		return scene, ErrDraftNotFound
+	}
+	if !domain.ValidSceneID(sceneID) {
+		return scene, fmt.Errorf("%w: invalid scene id %q", ErrInvalidInput, sceneID)
+	}
+	parsed, err := repo.ParseScene(sceneID, []byte(source))
+	if err != nil {
+		return scene, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	if parsed.ID != sceneID {
+		return scene, fmt.Errorf("%w: scene id %q does not match %q", ErrInvalidInput, parsed.ID, sceneID)
+	}
+	return scene, nil
+}
+
+// Validate проверяет черновик так же, как проверяется история перед публикацией.
+func (a *AuthoringService) Validate(ctx context.Context, storyID string) (StoryReport, error) {
+	if !domain.ValidStoryID(storyID) {
+		return StoryReport{}, ErrDraftNotFound
+	}
+	manifest, scenes, err := a.Drafts.LoadDraft(ctx, storyID)
+	if err != nil {
+		return StoryReport{}, err
+	}
+	return validateDraft(storyID, a.nextVersion(ctx, storyID), manifest, scenes), nil
+}
+
+// nextVersion возвращает номер, под которым опубликуется черновик: больше и живой
+// истории каталога, и последней опубликованной версии.
+func (a *AuthoringService) nextVersion(ctx context.Context, storyID string) int {
+	version := 1
+	if scenes, err := a.Stories.Scenes(storyID); err == nil {
+		version = scenes.Story().Version + 1
+	}
+	if versions, err := a.Drafts.Versions(ctx, storyID); err == nil && len(versions) > 0 {
+		version = max(version, versions[0].Version+1)
+	}
+	return version
+}
+
+// validateDraft разбирает черновик и проверяет граф истории. Ошибки разбора
+// попадают в отчёт, а не прерывают проверку, чтобы автор увидел все проблемы сразу.
+// Версия из манифеста заменяется на version — ту, под которой черновик опубликуется,
+// поэтому миграции проверяются относительно настоящего номера версии.
+func validateDraft(storyID string, version int, manifest string, drafts []domain.DraftScene) StoryReport {
+	var report StoryReport
+	story, err := repo.ParseStory([]byte(manifest))
+	if err != nil {
+		report.add(SeverityError, "", "", "manifest: %v", err)
+		return report
+	}
+	if story.ID != "" && story.ID != storyID {
+		report.add(SeverityError, "", "", "manifest id %q does not match story %q", story.ID, storyID)
+	}
+	story.ID = storyID
+	story.Version = version
+
+	scenes := make(map[string]domain.Scene, len(drafts))
+	for _, d := range drafts {
+		scene, err := repo.ParseScene(d.ID, []byte(d.Source))
+		if err != nil {
+			report.add(SeverityError, d.ID, "", "%v", err)
+			continue
+		}
+		scenes[d.ID] = scene
+	}
+	checked := ValidateScenes(scenes, story)
+	report.Issues = append(report.Issues, checked.Issues...)
+	return report
+}
+
+// Publish проверяет черновик и публикует его новой версией истории.
+// Номер версии больше номера опубликованной сейчас истории, даже если она
+// пришла из файлов, чтобы версия в манифесте только росла.
+// Черновик с ошибками не публикуется: возвращается DraftInvalidError с отчётом.
+func (a *AuthoringService) Publish(ctx context.Context, storyID string, adminID uuid.UUID) (domain.StoryVersion, error) {
+	if !domain.ValidStoryID(storyID) {
+		return domain.StoryVersion{}, ErrDraftNotFound
+	}
+
+	// 1. Следующая версия после той, что сейчас в игре
+	minVersion := a.nextVersion(ctx, storyID)
+
+	// 2. Проверка и публикация в одной транзакции с чтением черновика
+	var count int
+	version, err := a.Drafts.Publish(ctx, storyID, adminID, minVersion,
+		func(version int, manifest string, scenes []domain.DraftScene) error {
+			report := validateDraft(storyID, version, manifest, scenes)
+			if report.HasErrors() {
+				return &DraftInvalidError{Report: report}
+			}
+			count = len(scenes)
+			return nil
+		})
+	if err != nil {
+		return domain.StoryVersion{}, err
+	}
+
+	// 3. Каталог перечитывается сразу, не дожидаясь наблюдателя
+	if a.Reload != nil {
+		if err := a.Reload(); err != nil {
+			return domain.StoryVersion{}, fmt.Errorf("version %d is published, but the catalog reload failed: %w", version, err)
+		}
+	}
+
+	v := domain.StoryVersion{StoryID: storyID, Version: version, Scenes: count}
+	if adminID != uuid.Nil {
+		v.PublishedBy = &adminID
+	}
+	if versions, err := a.Drafts.Versions(ctx, storyID); err == nil && len(versions) > 0 && versions[0].Version == version {
+		v = versions[0]
+	}
+	return v, nil
+}
+
+// Versions возвращает опубликованные версии истории, новые первыми.
+func (a *AuthoringService) Versions(ctx context.Context, storyID string) ([]domain.StoryVersion, error) {
+	if !domain.ValidStoryID(storyID) {
+		return nil, ErrStoryNotFound
+	}
+	return a.Drafts.Versions(ctx, storyID)
+}
+
+// Checkout заменяет черновик историей, которая сейчас в игре, — например,
+// чтобы начать правку истории из файлов каталога. Переводы в черновик не копируются:
+// они остаются в locales/ каталога и после публикации берутся оттуда (см. repo.LayeredStore).
+func (a *AuthoringService) Checkout(ctx context.Context, storyID string) error {
+	if !domain.ValidStoryID(storyID) {
+		return ErrStoryNotFound
+	}
+	live, err := a.Stories.Scenes(storyID)
+	if err != nil {
+		return err
+	}
+
+	// 1. Манифест без вычисляемых полей
+	story := live.Story()
+	story.ID = ""
+	manifest, err := marshalYAML(story)
+	if err != nil {
+		return err
+	}
+
+	// 2. Все сцены
+	ids, err := live.List()
+	if err != nil {
+		return err
+	}
+	sort.Strings(ids)
+	scenes := make([]domain.DraftScene, 0, len(ids))
+	for _, id := range ids {
+		scene, err := live.Load(id)
+		if err != nil {
+			return err
+		}
+		src, err := marshalYAML(scene)
+		if err != nil {
+			return err
+		}
+		scenes = append(scenes, domain.DraftScene{ID: id, Source: src})
+	}
+	return a.Drafts.ReplaceDraft(ctx, storyID, manifest, scenes)
+}
+
+// marshalYAML кодирует v с отступом в два пробела, как в файлах историй.
+func marshalYAML(v any) (string, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/repo"
+
+	"github.com/google/uuid"
+)
+
+// fakeDraftRepo — черновики и опубликованные версии в памяти.
+type fakeDraftRepo struct {
+	manifests map[string]string
+	scenes    map[string]map[string]string
+	versions  []domain.StoryVersion
+}
+
+func newFakeDraftRepo() *fakeDraftRepo {
+	return &fakeDraftRepo{manifests: map[string]string{}, scenes: map[string]map[string]string{}}
+}
+
+func (f *fakeDraftRepo) GetManifest(_ context.Context, storyID string) (string, error) {
+	m, ok := f.manifests[storyID]
+	if !ok {
+		return "", repo.ErrDraftNotFound
+	}
+	return m, nil
+}
+
+func (f *fakeDraftRepo) PutManifest(_ context.Context, storyID, manifest string) error {
+	f.manifests[storyID] = manifest
+	if f.scenes[storyID] == nil {
+		f.scenes[storyID] = map[string]string{}
+	}
+	return nil
+}
+
+func (f *fakeDraftRepo) ListScenes(ctx context.Context, storyID string) ([]domain.DraftScene, error) {
+	_, scenes, err := f.LoadDraft(ctx, storyID)
+	for i := range scenes {
+		scenes[i].Source = ""
+	}
+	return scenes, err
+}
+
+func (f *fakeDraftRepo) GetScene(_ context.Context, storyID, sceneID string) (domain.DraftScene, error) {
+	src, ok := f.scenes[storyID][sceneID]
+	if !ok {
+		return domain.DraftScene{}, repo.ErrSceneNotFound
+	}
+	return domain.DraftScene{ID: sceneID, Source: src}, nil
+}
+
+func (f *fakeDraftRepo) CreateScene(_ context.Context, storyID string, scene domain.DraftScene) (domain.DraftScene, error) {
+	scenes, ok := f.scenes[storyID]
+	if !ok {
+		return scene, repo.ErrDraftNotFound
+	}
+	if _, dup := scenes[scene.ID]; dup {
+		return scene, repo.ErrSceneExists
+	}
+	scenes[scene.ID] = scene.Source
+	return scene, nil
+}
+
+func (f *fakeDraftRepo) UpdateScene(_ context.Context, storyID string, scene domain.DraftScene) (domain.DraftScene, error) {
+	if _, ok := f.scenes[storyID][scene.ID]; !ok {
+		return scene, repo.ErrSceneNotFound
+	}
+	f.scenes[storyID][scene.ID] = scene.Source
+	return scene, nil
+}
+
+func (f *fakeDraftRepo) DeleteScene(_ context.Context, storyID, sceneID string) error {
+	if _, ok := f.scenes[storyID][sceneID]; !ok {
+		return repo.ErrSceneNotFound
+	}
+	delete(f.scenes[storyID], sceneID)
+	return nil
+}
+
+func (f *fakeDraftRepo) LoadDraft(_ context.Context, storyID string) (string, []domain.DraftScene, error) {
+	m, ok := f.manifests[storyID]
+	if !ok {
+		return "", nil, repo.ErrDraftNotFound
+	}
+	var scenes []domain.DraftScene
+	for id, src := range f.scenes[storyID] {
+		scenes = append(scenes, domain.DraftScene{ID: id, Source: src})
+	}
+	sort.Slice(scenes, func(i, j int) bool { return scenes[i].ID < scenes[j].ID })
+	return m, scenes, nil
+}
+
+func (f *fakeDraftRepo) ReplaceDraft(_ context.Context, storyID, manifest string, scenes []domain.DraftScene) error {
+	f.manifests[storyID] = manifest
+	f.scenes[storyID] = map[string]string{}
+	for _, sc := range scenes {
+		f.scenes[storyID][sc.ID] = sc.Source
+	}
+	return nil
+}
+
+func (f *fakeDraftRepo) Publish(ctx context.Context, storyID string, _ uuid.UUID, minVersion int, check repo.PublishCheck) (int, error) {
+	manifest, scenes, err := f.LoadDraft(ctx, storyID)
+	if err != nil {
+		return 0, err
+	}
+	version := 1
+	for _, v := range f.versions {
+		if v.StoryID == storyID {
+			version = max(version, v.Version+1)
+		}
+	}
+	version = max(version, minVersion)
+	if err := check(version, manifest, scenes); err != nil {
+		return 0, err
+	}
+	f.versions = append(f.versions, domain.StoryVersion{StoryID: storyID, Version: version, Scenes: len(scenes)})
+	return version, nil
+}
+
+func (f *fakeDraftRepo) Versions(_ context.Context, storyID string) ([]domain.StoryVersion, error) {
+	var versions []domain.StoryVersion
+	for i := len(f.versions) - 1; i >= 0; i-- {
+		if f.versions[i].StoryID == storyID {
+			versions = append(versions, f.versions[i])
+		}
+	}
+	return versions, nil
+}
+
+func TestAuthoringPublish(t *testing.T) {
+	ctx := context.Background()
+	drafts := newFakeDraftRepo()
+	live := &fakeSceneRepo{story: domain.Story{ID: testStory, Title: "Live", Version: 3, Start: "intro"}}
+	reloads := 0
+	svc := NewAuthoringService(drafts, fakeCatalog{testStory: live}, func() error {
+		reloads++
+		return nil
+	})
+
+	// 1. Сцены без черновика не создаются, кривой YAML и чужой ID отклоняются
+	if _, err := svc.CreateScene(ctx, testStory, "intro", "id: intro\ntext: x\n"); !errors.Is(err, ErrDraftNotFound) {
+		t.Fatalf("create without draft: err = %v; want ErrDraftNotFound", err)
+	}
+	if err := svc.PutManifest(ctx, testStory, "title: Draft\nstart: intro\n"); err != nil {
+		t.Fatalf("PutManifest: %v", err)
+	}
+	for _, src := range []string{"id: [intro\n", "id: other\ntext: x\n"} {
+		if _, err := svc.CreateScene(ctx, testStory, "intro", src); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("create %q: err = %v; want ErrInvalidInput", src, err)
+		}
+	}
+
+	// 2. Черновик со ссылкой на несуществующую сцену не публикуется
+	intro := "id: intro\ntext: Начало\nchoices:\n  - id: go\n    text: Дальше\n    next: end\n"
+	if _, err := svc.CreateScene(ctx, testStory, "intro", intro); err != nil {
+		t.Fatalf("CreateScene: %v", err)
+	}
+	if _, err := svc.CreateScene(ctx, testStory, "intro", intro); !errors.Is(err, ErrSceneExists) {
+		t.Errorf("duplicate create: err = %v; want ErrSceneExists", err)
+	}
+	_, err := svc.Publish(ctx, testStory, uuid.New())
+	var invalid *DraftInvalidError
+	if !errors.As(err, &invalid) || !strings.Contains(invalid.Report.Issues[0].Message, "end") {
+		t.Fatalf("publish broken draft: err = %v; want DraftInvalidError about end", err)
+	}
+	if len(drafts.versions) != 0 || reloads != 0 {
+		t.Fatalf("broken draft was published: versions = %v, reloads = %d", drafts.versions, reloads)
+	}
+
+	// 3. Исправленный черновик публикуется версией после живой и перечитывает каталог
+	if _, err := svc.CreateScene(ctx, testStory, "end", "id: end\ntext: Конец\nending:\n  id: peace\n  category: good\n"); err != nil {
+		t.Fatalf("CreateScene end: %v", err)
+	}
+	report, err := svc.Validate(ctx, testStory)
+	if err != nil || report.HasErrors() {
+		t.Fatalf("Validate = %v, %v; want no errors", report.Issues, err)
+	}
+	v, err := svc.Publish(ctx, testStory, uuid.New())
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if v.Version != 4 || v.Scenes != 2 || reloads != 1 {
+		t.Errorf("published %+v with %d reloads; want version 4 with 2 scenes and 1 reload", v, reloads)
+	}
+}
+
+func TestAuthoringPublishAssignedVersion(t *testing.T) {
+	ctx := context.Background()
+	drafts := newFakeDraftRepo()
+	live := &fakeSceneRepo{story: domain.Story{ID: testStory, Title: "Live", Version: 3, Start: "intro"}}
+	svc := NewAuthoringService(drafts, fakeCatalog{testStory: live}, nil)
+
+	// 1. Манифест черновика отстал от каталога: миграция к присвоенной версии 4
+	// проверяется относительно неё, а не версии 1 из манифеста
+	if err := svc.PutManifest(ctx, testStory, "title: Draft\nversion: 1\nstart: intro\nmigrations:\n  - version: 4\n    scenes: {gate: intro}\n"); err != nil {
+		t.Fatalf("PutManifest: %v", err)
+	}
+	if _, err := svc.CreateScene(ctx, testStory, "intro", "id: intro\ntext: x\nending:\n  id: peace\n  category: good\n"); err != nil {
+		t.Fatalf("CreateScene: %v", err)
+	}
+	report, err := svc.Validate(ctx, testStory)
+	if err != nil || report.HasErrors() {
+		t.Fatalf("Validate = %v, %v; want no errors", report.Issues, err)
+	}
+	v, err := svc.Publish(ctx, testStory, uuid.Nil)
+	if err != nil || v.Version != 4 {
+		t.Fatalf("Publish = %+v, %v; want version 4", v, err)
+	}
+
+	// 2. Манифест забежал вперёд: миграция к версии 9 опережает присвоенную 5
+	if err := svc.PutManifest(ctx, testStory, "title: Draft\nversion: 9\nstart: intro\nmigrations:\n  - version: 9\n"); err != nil {
+		t.Fatalf("PutManifest: %v", err)
+	}
+	_, err = svc.Publish(ctx, testStory, uuid.Nil)
+	var invalid *DraftInvalidError
+	if !errors.As(err, &invalid) || !strings.Contains(invalid.Report.Issues[0].Message, "ahead of story version 5") {
+		t.Fatalf("publish with migration ahead: err = %v; want DraftInvalidError about version 5", err)
+	}
+}
+
+func TestAuthoringCheckout(t *testing.T) {
+	ctx := context.Background()
+	drafts := newFakeDraftRepo()
+	live := &fakeSceneRepo{
+		scenes: map[string]domain.Scene{"intro": {ID: "intro", Text: "Дождь", Ending: &domain.Ending{ID: "rain", Category: domain.EndingGood}}},
+		story:  domain.Story{ID: testStory, Title: "Live", Version: 2, Start: "intro"},
+	}
+	svc := NewAuthoringService(drafts, fakeCatalog{testStory: live}, nil)
+
+	if err := svc.Checkout(ctx, testStory); err != nil {
+		t.Fatalf("Checkout: %v", err)
+	}
+	report, err := svc.Validate(ctx, testStory)
+	if err != nil || report.HasErrors() {
+		t.Fatalf("Validate after checkout = %v, %v; want no errors", report.Issues, err)
+	}
+	scene, err := svc.Scene(ctx, testStory, "intro")
+	if err != nil || !strings.Contains(scene.Source, "text: Дождь") {
+		t.Errorf("scene = %q, %v; want the live scene in YAML", scene.Source, err)
+	}
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/repo"
+)
+
+// enterScene приводит игрока в сцену sceneID: проходит по автоматическим переходам,
+// пока условия очередной сцены не перестанут уводить дальше, и применяет OnEnter
+// сцены, где игрок в итоге оказался. Сцены, из которых игрока увёл переход,
+// считаются непосещёнными, и их OnEnter не применяется.
+// Возвращает сохранение с итоговой сценой и саму сцену; если сцены нет,
+// сохранение указывает на неё, а сцена равна nil — ошибку отдаст её загрузка.
+// Повторный приход в сцену внутри одной цепочки — *RedirectLoopError.
+func enterScene(scenes repo.SceneRepo, story domain.Story, save domain.Save, sceneID string) (domain.Save, *domain.Scene, error) {
+	path := []string{sceneID}
+	visited := map[string]bool{}
+	for {
+		scene, err := scenes.Load(sceneID)
+		if errors.Is(err, repo.ErrSceneNotFound) {
+			save.SceneID = sceneID
+			return save, nil, nil
+		}
+		if err != nil {
+			return domain.Save{}, nil, err
+		}
+		visited[sceneID] = true
+
+		next, ok := scene.RedirectFor(save)
+		if !ok {
+			save.SceneID = sceneID
+			return story.Enter(scene, save), &scene, nil
+		}
+		path = append(path, next)
+		if visited[next] {
+			return domain.Save{}, nil, &RedirectLoopError{Path: path}
+		}
+		sceneID = next
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/repo"
+)
+
+// Ошибки сервисного слоя. Обработчики HTTP различают их через errors.Is/errors.As
+// и переводят в ответы с постоянными машиночитаемыми кодами.
+
+// ErrSceneNotFound — в истории нет сцены с таким идентификатором.
+var ErrSceneNotFound = repo.ErrSceneNotFound
+
+// ErrStoryNotFound — в каталоге нет истории с таким идентификатором.
+var ErrStoryNotFound = repo.ErrStoryNotFound
+
+// ErrInvalidChoice — в сцене нет выбора с таким идентификатором.
+var ErrInvalidChoice = errors.New("invalid choice")
+
+// ErrInvalidInput — входные данные не прошли доменную проверку (например, слишком короткий пароль).
+var ErrInvalidInput = domain.ErrInvalidInput
+
+// ErrUsernameTaken — имя пользователя уже занято.
+var ErrUsernameTaken = repo.ErrUsernameTaken
+
+// ErrInvalidCredentials — неверная пара логин/пароль.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrInvalidRefreshToken — refresh-токен не выдавался, истёк или отозван.
+var ErrInvalidRefreshToken = repo.ErrRefreshTokenNotFound
+
+// ErrRefreshTokenReused — refresh-токен предъявлен повторно; все токены этого входа отозваны.
+var ErrRefreshTokenReused = repo.ErrRefreshTokenReused
+
+// ErrPlayerNotFound — игрока с таким идентификатором нет.
+var ErrPlayerNotFound = repo.ErrPlayerNotFound
+
+// ErrGameNotStarted — у игрока нет сохранения, сначала нужно начать новую игру.
+var ErrGameNotStarted = errors.New("game not started")
+
+// ErrWrongScene — выбор сделан не из той сцены, где сейчас находится игрок.
+var ErrWrongScene = errors.New("choice is not from the current scene")
+
+// WrongSceneError уточняет ErrWrongScene: где игрок пытался сделать выбор и где он на самом деле.
+type WrongSceneError struct {
+	SceneID        string // сцена из запроса
+	CurrentSceneID string // сцена из последнего сохранения
+}
+
+func (e *WrongSceneError) Error() string {
+	return fmt.Sprintf("%v: requested %q, player is at %q", ErrWrongScene, e.SceneID, e.CurrentSceneID)
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrWrongScene).
+func (e *WrongSceneError) Is(target error) bool {
+	return target == ErrWrongScene
+}
+
+// ErrRunFinished — прохождение завершено концовкой, выбирать больше нечего.
+var ErrRunFinished = errors.New("run is finished")
+
+// ErrChoiceLocked — условия выбора не выполнены для текущего состояния игрока.
+var ErrChoiceLocked = errors.New("choice is locked")
+
+// ChoiceLockedError уточняет ErrChoiceLocked: какой выбор и почему недоступен.
+type ChoiceLockedError struct {
+	ChoiceID string
+	Reason   string
+}
+
+func (e *ChoiceLockedError) Error() string {
+	return fmt.Sprintf("%v: %s %s", ErrChoiceLocked, e.ChoiceID, e.Reason)
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrChoiceLocked).
+func (e *ChoiceLockedError) Is(target error) bool {
+	return target == ErrChoiceLocked
+}
+
+// ErrInvalidSlot — идентификатор слота не соответствует slotPattern.
+var ErrInvalidSlot = errors.New("invalid save slot")
+
+// ErrSlotNotFound — у игрока нет такого слота сохранения.
+var ErrSlotNotFound = errors.New("save slot not found")
+
+// ErrRewindForbidden — манифест истории запрещает откат к прошлым решениям.
+var ErrRewindForbidden = errors.New("rewind is not allowed in this story")
+
+// ErrSnapshotNotFound — снимка с таким идентификатором у игрока нет.
+var ErrSnapshotNotFound = errors.New("save snapshot not found")
+
+// ErrDraftNotFound — у истории нет черновика.
+var ErrDraftNotFound = repo.ErrDraftNotFound
+
+// ErrSceneExists — в черновике уже есть сцена с таким идентификатором.
+var ErrSceneExists = repo.ErrSceneExists
+
+// ErrDraftInvalid — черновик не прошёл проверку и не может быть опубликован.
+var ErrDraftInvalid = errors.New("draft has errors")
+
+// DraftInvalidError уточняет ErrDraftInvalid: отчёт проверки черновика.
+type DraftInvalidError struct {
+	Report StoryReport
+}
+
+func (e *DraftInvalidError) Error() string {
+	errs := 0
+	for _, i := range e.Report.Issues {
+		if i.Severity == SeverityError {
+			errs++
+		}
+	}
+	return fmt.Sprintf("%v: %d error(s)", ErrDraftInvalid, errs)
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrDraftInvalid).
+func (e *DraftInvalidError) Is(target error) bool {
+	return target == ErrDraftInvalid
+}
+
+// ErrSaveOutdated — сохранение сделано на прежней версии истории, и миграции
+// из манифеста не переносят его в существующую сцену.
+var ErrSaveOutdated = errors.New("save cannot be migrated to the current story version")
+
+// SaveOutdatedError уточняет ErrSaveOutdated: какое сохранение и куда его привели миграции.
+type SaveOutdatedError struct {
+	Slot        string
+	SceneID     string // сцена после миграций, которой нет в текущей версии
+	FromVersion int
+	ToVersion   int
+}
+
+func (e *SaveOutdatedError) Error() string {
+	return fmt.Sprintf("%v: slot %s, scene %q (version %d → %d)", ErrSaveOutdated, e.Slot, e.SceneID, e.FromVersion, e.ToVersion)
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrSaveOutdated).
+func (e *SaveOutdatedError) Is(target error) bool {
+	return target == ErrSaveOutdated
+}
+
+// ErrRedirectLoop — автоматические переходы сцен зациклились; выбор отклоняется.
+var ErrRedirectLoop = errors.New("scene redirect loop")
+
+// RedirectLoopError уточняет ErrRedirectLoop: цепочка сцен, замкнувшаяся в цикл.
+type RedirectLoopError struct {
+	Path []string // сцены по порядку; последняя уже встречалась раньше
+}
+
+func (e *RedirectLoopError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrRedirectLoop, strings.Join(e.Path, " -> "))
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrRedirectLoop).
+func (e *RedirectLoopError) Is(target error) bool {
+	return target == ErrRedirectLoop
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"blood-on-maple-leaves/backend/domain"
@@ -11,89 +12,394 @@ import (
 	"github.com/google/uuid"
 )
 
+// DefaultStartScene — сцена, с которой начинается новая игра, если не задано иное.
+const DefaultStartScene = "intro"
+
 // GameService управляет игровой логикой: загрузкой сцен, применением выбора и сохранением прогресса.
+// Прогресс игрока ведётся отдельно в каждой истории каталога.
 type GameService struct {
-	SceneRepo repo.SceneRepo // для загрузки YAML-сцен
-	SaveRepo  repo.SaveRepo  // для чтения/записи прогресса из Postgres
+	Stories        repo.StoryCatalog // истории и их сцены
+	SaveRepo       repo.SaveRepo     // для чтения/записи прогресса из Postgres
+	RunRepo        repo.RunRepo      // журнал завершённых прохождений; nil — не вести
+	Locales        repo.LocaleRepo   // языковые настройки игроков; nil — только Accept-Language
+	Players        repo.PlayerFinder // имена игроков для вставки {{player}}; nil — пустое имя
+	DefaultStoryID string            // история для клиентов, которые не указывают её явно
 }
 
 // NewGameService создаёт сервис с необходимыми репозиториями.
-func NewGameService(sceneRepo repo.SceneRepo, saveRepo repo.SaveRepo) *GameService {
+func NewGameService(stories repo.StoryCatalog, saveRepo repo.SaveRepo) *GameService {
 	return &GameService{
-		SceneRepo: sceneRepo,
-		SaveRepo:  saveRepo,
+		Stories:  stories,
+		SaveRepo: saveRepo,
 	}
 }
 
-// ApplyChoice находит выбор по его идентификатору в сцене.
-// Возвращает объект Choice или ошибку, если выбор не найден.
-func (g *GameService) ApplyChoice(scene domain.Scene, choiceID string) (domain.Choice, error) {
+// scenes возвращает сцены истории вместе с её актуальным манифестом.
+func (g *GameService) scenes(storyID string) (repo.StoryScenes, domain.Story, error) {
+	scenes, err := g.Stories.Scenes(storyID)
+	if err != nil {
+		return nil, domain.Story{}, err
+	}
+	return scenes, scenes.Story(), nil
+}
+
+// story возвращает манифест истории или пустой манифест, если истории больше нет в каталоге.
+func (g *GameService) story(storyID string) domain.Story {
+	_, story, _ := g.scenes(storyID)
+	return story
+}
+
+// startScene возвращает стартовую сцену истории: указанную в манифесте, иначе DefaultStartScene.
+func startScene(story domain.Story) string {
+	if story.Start != "" {
+		return story.Start
+	}
+	return DefaultStartScene
+}
+
+// ListStories возвращает истории каталога в том виде, в каком их видит игрок:
+// каждая на языке, подобранном по acceptLanguage среди её переводов.
+func (g *GameService) ListStories(acceptLanguage string) []domain.Story {
+	stories := g.Stories.Stories()
+	for i, story := range stories {
+		locale := NegotiateLocale(story, "", acceptLanguage)
+		if scenes, err := g.Stories.Scenes(story.ID); err == nil {
+			story = story.Localize(translation(scenes, story, locale))
+		}
+		stories[i] = story.Public()
+	}
+	return stories
+}
+
+// GetStory возвращает манифест истории на языке locale для игрока или ErrStoryNotFound.
+func (g *GameService) GetStory(storyID, locale string) (domain.Story, error) {
+	scenes, story, err := g.scenes(storyID)
+	if err != nil {
+		return domain.Story{}, err
+	}
+	return story.Localize(translation(scenes, story, locale)).Public(), nil
+}
+
+// ApplyChoice находит выбор по его идентификатору в сцене и проверяет его условия
+// для состояния save. Возвращает объект Choice, ошибку, если выбор не найден,
+// или *ChoiceLockedError, если условия выбора не выполнены.
+func (g *GameService) ApplyChoice(scene domain.Scene, choiceID string, save domain.Save) (domain.Choice, error) {
 	for _, choice := range scene.Choices {
 		if choice.ID == choiceID {
+			if cond, unmet := domain.Unmet(choice.Requires, save); unmet {
+				return domain.Choice{}, &ChoiceLockedError{ChoiceID: choiceID, Reason: lockReason(cond)}
+			}
 			return choice, nil
 		}
 	}
-	return domain.Choice{}, errors.New("invalid choice ID: " + choiceID)
+	return domain.Choice{}, fmt.Errorf("%w: %s", ErrInvalidChoice, choiceID)
 }
 
-// Choose загружает сцену и возвращает идентификатор следующей сцены после применения выбора.
-func (g *GameService) Choose(sceneID, choiceID string) (string, error) {
-	scene, err := g.SceneRepo.Load(sceneID)
+// Choose загружает сцену и возвращает идентификатор следующей сцены после применения выбора
+// и автоматических переходов. Условия выбора, переходов и броски проверяются для значений
+// характеристик по умолчанию и нулевого зерна.
+func (g *GameService) Choose(storyID, sceneID, choiceID string) (string, error) {
+	scenes, story, err := g.scenes(storyID)
+	if err != nil {
+		return "", err
+	}
+	scene, err := scenes.Load(sceneID)
+	if err != nil {
+		return "", err
+	}
+	save := domain.Save{Stats: story.DefaultStats()}
+	choice, err := g.ApplyChoice(scene, choiceID, save)
 	if err != nil {
 		return "", err
 	}
-	choice, err := g.ApplyChoice(scene, choiceID)
+	res := choice.Resolve(save)
+	save = story.ApplyOps(save, res.Effects, res.Ops)
+	save.Flags = domain.ApplyFlags(save.Flags, res.SetFlags, res.ClearFlags)
+	save, _, err = enterScene(scenes, story, save, res.Next)
 	if err != nil {
 		return "", err
 	}
-	return choice.Next, nil
+	return save.SceneID, nil
+}
+
+// GetScene возвращает структуру сцены истории по её идентификатору.
+func (g *GameService) GetScene(storyID, sceneID string) (domain.Scene, error) {
+	scenes, _, err := g.scenes(storyID)
+	if err != nil {
+		return domain.Scene{}, err
+	}
+	return scenes.Load(sceneID)
+}
+
+// ChoiceView — выбор в том виде, в каком его видит конкретный игрок.
+type ChoiceView struct {
+	domain.Choice
+	Locked       bool   `json:"locked,omitempty"`
+	LockedReason string `json:"locked_reason,omitempty"`
+}
+
+// SceneView — сцена с выборами, размеченными для конкретного игрока.
+type SceneView struct {
+	ID      string         `json:"id"`
+	Text    string         `json:"text"`
+	Choices []ChoiceView   `json:"choices"`
+	Ending  *domain.Ending `json:"ending,omitempty"`
+}
+
+// ViewScene размечает сцену для состояния save: добавляет к тексту абзацы,
+// чьи условия выполнены, подставляет в текст сцены и выборов имя игрока player,
+// характеристики и условные фрагменты, помечает недоступные выборы Locked
+// с причиной и убирает скрытые (Hidden).
+func (g *GameService) ViewScene(scene domain.Scene, save domain.Save, player string) SceneView {
+	vars := domain.TextVars{Player: player, Save: save}
+	view := SceneView{
+		ID:      scene.ID,
+		Text:    domain.RenderText(scene.TextFor(save), vars),
+		Choices: []ChoiceView{},
+		Ending:  scene.Ending,
+	}
+	for _, choice := range scene.Choices {
+		choice.Text = domain.RenderText(choice.Text, vars)
+		cv := ChoiceView{Choice: choice}
+		if cond, unmet := domain.Unmet(choice.Requires, save); unmet {
+			if choice.Hidden {
+				continue
+			}
+			cv.Locked = true
+			cv.LockedReason = lockReason(cond)
+		}
+		view.Choices = append(view.Choices, cv)
+	}
+	return view
 }
 
-// GetScene возвращает структуру сцены по её идентификатору.
-func (g *GameService) GetScene(sceneID string) (domain.Scene, error) {
-	return g.SceneRepo.Load(sceneID)
+// GetSceneForPlayer загружает сцену истории на языке locale, подставляет в текст
+// данные игрока и размечает выборы по его последнему сохранению в этой истории. Непереведённые строки остаются
+// на языке исходного текста. Если игра ещё не начата, условия проверяются
+// для значений по умолчанию, а возвращаемый флаг started равен false.
+func (g *GameService) GetSceneForPlayer(
+	ctx context.Context,
+	playerID uuid.UUID,
+	storyID, sceneID, locale string,
+) (view SceneView, save domain.Save, started bool, err error) {
+	scenes, story, err := g.scenes(storyID)
+	if err != nil {
+		return SceneView{}, domain.Save{}, false, err
+	}
+	scene, err := scenes.Load(sceneID)
+	if err != nil {
+		return SceneView{}, domain.Save{}, false, err
+	}
+
+	save, err = g.GetLatestSave(ctx, playerID, storyID)
+	switch {
+	case errors.Is(err, ErrGameNotStarted):
+		save = domain.Save{StoryID: storyID, Stats: story.DefaultStats()}
+	case err != nil:
+		return SceneView{}, domain.Save{}, false, err
+	default:
+		started = true
+	}
+
+	player, err := g.playerName(ctx, playerID)
+	if err != nil {
+		return SceneView{}, domain.Save{}, false, err
+	}
+
+	scene = scene.Localize(translation(scenes, story, locale))
+	return g.ViewScene(scene, save, player), save, started, nil
+}
+
+// playerName возвращает имя игрока для вставки {{player}}.
+func (g *GameService) playerName(ctx context.Context, playerID uuid.UUID) (string, error) {
+	if g.Players == nil {
+		return "", nil
+	}
+	p, err := g.Players.GetByID(ctx, playerID.String())
+	if err != nil {
+		return "", err
+	}
+	return p.Username, nil
+}
+
+// lockReason формирует текст причины блокировки выбора.
+func lockReason(cond domain.Condition) string {
+	return "requires " + cond.String()
 }
 
 // ChooseForPlayer обрабатывает выбор игрока с учётом сохранённого прогресса.
 // Он загружает последнюю запись Save, применяет выбранный вариант и сохраняет новое состояние.
+// Сохранение попадает в сцену, куда игрока приводят автоматические переходы
+// следующей сцены, с применёнными OnEnter этой сцены.
+// Если sceneID не совпадает с текущей сценой игрока, возвращает *WrongSceneError,
+// если прохождение уже завершено концовкой — ErrRunFinished.
+// Когда выбор ведёт в сцену-концовку, сохранение помечается завершённым,
+// и вместе с прохождением записывается в RunRepo. Если исход выбора определяется броском,
+// бросок записывается в новое сохранение (Save.Roll).
 func (g *GameService) ChooseForPlayer(
 	ctx context.Context,
 	playerID uuid.UUID,
-	sceneID, choiceID string,
+	storyID, sceneID, choiceID string,
 ) (string, domain.Save, error) {
-	current, err := g.SaveRepo.GetLatestByPlayer(ctx, playerID)
+	scenes, story, err := g.scenes(storyID)
 	if err != nil {
 		return "", domain.Save{}, err
 	}
 
-	scene, err := g.SceneRepo.Load(sceneID)
+	current, err := g.GetLatestSave(ctx, playerID, storyID)
 	if err != nil {
 		return "", domain.Save{}, err
 	}
 
-	choice, err := g.ApplyChoice(scene, choiceID)
+	// После концовки выбирать нельзя — только начать новую игру
+	if current.Finished() {
+		return "", domain.Save{}, ErrRunFinished
+	}
+
+	// Выбирать можно только в той сцене, где игрок находится по сохранению
+	if current.SceneID != sceneID {
+		return "", domain.Save{}, &WrongSceneError{SceneID: sceneID, CurrentSceneID: current.SceneID}
+	}
+
+	scene, err := scenes.Load(sceneID)
 	if err != nil {
 		return "", domain.Save{}, err
 	}
 
+	choice, err := g.ApplyChoice(scene, choiceID, current)
+	if err != nil {
+		return "", domain.Save{}, err
+	}
+
+	// Итог выбора; бросок зависит только от зерна и номера броска в сохранении,
+	// поэтому повтор запроса не даёт перебросить кубик
+	res := choice.Resolve(current)
 	newSave := domain.Save{
-		ID:        uuid.New(),
-		PlayerID:  playerID,
-		SceneID:   choice.Next,
-		Honor:     current.Honor + choice.Effects["honor"],
-		Rage:      current.Rage + choice.Effects["rage"],
-		Karma:     current.Karma + choice.Effects["karma"],
-		CreatedAt: time.Now(),
+		ID:           uuid.New(),
+		PlayerID:     playerID,
+		StoryID:      storyID,
+		StoryVersion: story.Version,
+		Slot:         current.Slot,
+		SceneID:      res.Next,
+		Stats:        current.Stats,
+		Flags:        domain.ApplyFlags(current.Flags, res.SetFlags, res.ClearFlags),
+		Seed:         current.Seed,
+		Rolls:        current.Rolls,
+		Roll:         res.Roll,
+		CreatedAt:    time.Now(),
+	}
+	if res.Roll != nil {
+		newSave.Rolls++
+	}
+	newSave = story.ApplyOps(newSave, res.Effects, res.Ops)
+
+	// Приход в следующую сцену: автоматические переходы и её OnEnter.
+	// Если итоговая сцена — концовка, отмечаем прохождение завершённым.
+	// Недоступную следующую сцену здесь не считаем ошибкой: её отдаст GetScene.
+	newSave, next, err := enterScene(scenes, story, newSave, res.Next)
+	if err != nil {
+		return "", domain.Save{}, err
+	}
+	if next != nil && next.IsEnding() {
+		newSave.EndingID = next.Ending.ID
 	}
 
-	if err := g.SaveRepo.Create(ctx, newSave); err != nil {
+	// Сохранение с концовкой и запись о прохождении пишутся одной транзакцией:
+	// иначе при сбое второй записи прохождение завершилось бы, не попав в журнал
+	if newSave.Finished() && g.RunRepo != nil {
+		run := domain.CompletedRun{
+			ID:         uuid.New(),
+			PlayerID:   playerID,
+			StoryID:    storyID,
+			SceneID:    newSave.SceneID,
+			Ending:     *next.Ending,
+			Stats:      newSave.Stats,
+			Flags:      newSave.Flags,
+			FinishedAt: newSave.CreatedAt,
+		}
+		if err := g.RunRepo.Finish(ctx, newSave, run); err != nil {
+			return "", domain.Save{}, err
+		}
+	} else if err := g.SaveRepo.Create(ctx, newSave); err != nil {
 		return "", domain.Save{}, err
 	}
 
-	return choice.Next, newSave, nil
+	return newSave.SceneID, newSave, nil
+}
+
+// EndingOf возвращает концовку завершённого прохождения на языке locale
+// или nil, если игра продолжается.
+func (g *GameService) EndingOf(save domain.Save, locale string) (*domain.Ending, error) {
+	if !save.Finished() {
+		return nil, nil
+	}
+	scenes, story, err := g.scenes(save.StoryID)
+	if err != nil {
+		return nil, err
+	}
+	scene, err := scenes.Load(save.SceneID)
+	if err != nil {
+		return nil, err
+	}
+	return scene.Localize(translation(scenes, story, locale)).Ending, nil
+}
+
+// CompletedRuns возвращает завершённые прохождения игрока во всех историях, новые первыми.
+// Характеристики отфильтрованы по видимости в манифесте своей истории, а название
+// концовки переведено на язык, который игрок получил бы в этой истории
+// (см. ResolveLocale). Если истории больше нет в каталоге, название остаётся записанным.
+func (g *GameService) CompletedRuns(ctx context.Context, playerID uuid.UUID, acceptLanguage string) ([]domain.CompletedRun, error) {
+	if g.RunRepo == nil {
+		return []domain.CompletedRun{}, nil
+	}
+	runs, err := g.RunRepo.ListByPlayer(ctx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	locales := map[string]string{} // язык по истории; выбирается один раз на историю
+	for i := range runs {
+		storyID := runs[i].StoryID
+		runs[i].Stats = g.story(storyID).VisibleStats(runs[i].Stats)
+
+		scenes, story, err := g.scenes(storyID)
+		if err != nil {
+			continue
+		}
+		locale, ok := locales[storyID]
+		if !ok {
+			locale = g.ResolveLocale(ctx, playerID, storyID, acceptLanguage)
+			locales[storyID] = locale
+		}
+		t := translation(scenes, story, locale)
+		runs[i].Ending.Title = t.Text(domain.EndingTitleKey(runs[i].SceneID), runs[i].Ending.Title)
+	}
+	return runs, nil
+}
+
+// GetLatestSave возвращает последнее сохранение игрока в истории, перенесённое
+// на текущую версию истории. Если игрок ещё не начинал эту историю, возвращает
+// ErrGameNotStarted, если сохранение не переносится — *SaveOutdatedError.
+func (g *GameService) GetLatestSave(ctx context.Context, playerID uuid.UUID, storyID string) (domain.Save, error) {
+	scenes, story, err := g.scenes(storyID)
+	if err != nil {
+		return domain.Save{}, err
+	}
+	save, err := g.SaveRepo.GetLatestByPlayer(ctx, playerID, storyID)
+	if errors.Is(err, repo.ErrSaveNotFound) {
+		return domain.Save{}, ErrGameNotStarted
+	}
+	if err != nil {
+		return domain.Save{}, err
+	}
+	return migrateSave(scenes, story, save)
+}
+
+// VisibleStats возвращает характеристики сохранения, которые можно показать игроку.
+func (g *GameService) VisibleStats(save domain.Save) map[string]int {
+	return g.story(save.StoryID).VisibleStats(save.Stats)
 }
 
-// GetLatestSave возвращает последнее сохранение игрока.
-func (g *GameService) GetLatestSave(ctx context.Context, playerID uuid.UUID) (domain.Save, error) {
-	return g.SaveRepo.GetLatestByPlayer(ctx, playerID)
+// VisibleEffects возвращает изменения характеристик по пути в сохранение без скрытых характеристик.
+func (g *GameService) VisibleEffects(save domain.Save) []domain.AppliedEffect {
+	return g.story(save.StoryID).VisibleEffects(save.Effects)
 }
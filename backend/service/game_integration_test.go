@@ -30,7 +30,7 @@ func setupService(t *testing.T) (*GameService, func()) {
 		Choices: []domain.Choice{{ID: "attack", Next: "hallway", Effects: map[string]int{"rage": 1}}},
 	}
 	sceneRepo := &repo.FakeSceneRepo{Scenes: map[string]domain.Scene{"intro": scene}}
-	svc := NewGameService(sceneRepo, saveRepo)
+	svc := NewGameService(repo.FakeCatalog{"ronin": sceneRepo}, saveRepo)
 	return svc, func() { pool.Close(); pgC.Terminate(ctx) }
 }
 
@@ -40,17 +40,17 @@ func TestChooseForPlayer(t *testing.T) {
 
 	playerID := uuid.New()
 	// First time: no save → GetLatestByPlayer returns error; handle by creating initial save manually
-	initial := domain.Save{ID: uuid.New(), PlayerID: playerID, SceneID: "intro", Honor: 0, Rage: 0, Karma: 0, CreatedAt: time.Now()}
+	initial := domain.Save{ID: uuid.New(), PlayerID: playerID, StoryID: "ronin", SceneID: "intro", Stats: map[string]int{"honor": 0, "rage": 0, "karma": 0}, CreatedAt: time.Now()}
 	svc.SaveRepo.Create(context.Background(), initial)
 
-	next, newSave, err := svc.ChooseForPlayer(context.Background(), playerID, "intro", "attack")
+	next, newSave, err := svc.ChooseForPlayer(context.Background(), playerID, "ronin", "intro", "attack")
 	if err != nil {
 		t.Fatalf("ChooseForPlayer failed: %v", err)
 	}
 	if next != "hallway" {
 		t.Errorf("expected next 'hallway', got '%s'", next)
 	}
-	if newSave.Rage != 1 {
-		t.Errorf("expected rage=1, got %d", newSave.Rage)
+	if newSave.Stats["rage"] != 1 {
+		t.Errorf("expected rage=1, got %d", newSave.Stats["rage"])
 	}
 }
@@ -1,25 +1,250 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"sort"
 	"testing"
 
 	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/repo"
+
+	"github.com/google/uuid"
 )
 
+// testStory — идентификатор истории в unit-тестах.
+const testStory = "ronin"
+
 // fakeSceneRepo — фейковая реализация SceneRepo для unit-тестов.
 type fakeSceneRepo struct {
-	scenes map[string]domain.Scene
+	scenes       map[string]domain.Scene
+	story        domain.Story
+	translations map[string]domain.Translation
 }
 
 func (f *fakeSceneRepo) Load(id string) (domain.Scene, error) {
 	scene, ok := f.scenes[id]
 	if !ok {
-		return domain.Scene{}, errors.New("scene not found")
+		return domain.Scene{}, repo.ErrSceneNotFound
 	}
 	return scene, nil
 }
 
+func (f *fakeSceneRepo) List() ([]string, error) {
+	ids := make([]string, 0, len(f.scenes))
+	for id := range f.scenes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (f *fakeSceneRepo) Story() domain.Story {
+	return f.story
+}
+
+func (f *fakeSceneRepo) Translation(locale string) domain.Translation {
+	return f.translations[locale]
+}
+
+// fakeCatalog — фейковый каталог историй.
+type fakeCatalog map[string]*fakeSceneRepo
+
+func (c fakeCatalog) Stories() []domain.Story {
+	var out []domain.Story
+	for id, r := range c {
+		story := r.story
+		story.ID = id
+		out = append(out, story)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (c fakeCatalog) Scenes(storyID string) (repo.StoryScenes, error) {
+	r, ok := c[storyID]
+	if !ok {
+		return nil, repo.ErrStoryNotFound
+	}
+	return r, nil
+}
+
+// newTestService собирает GameService с одной историей testStory.
+func newTestService(scenes map[string]domain.Scene, saves repo.SaveRepo) (*GameService, *fakeSceneRepo) {
+	r := &fakeSceneRepo{scenes: scenes}
+	return NewGameService(fakeCatalog{testStory: r}, saves), r
+}
+
+// fakeSaveRepo — фейковая реализация SaveRepo в памяти.
+type fakeSaveRepo struct {
+	saves  []domain.Save
+	active map[playerStory]string            // активный слот игрока в истории
+	names  map[playerStory]map[string]string // отображаемые имена слотов
+}
+
+// playerStory — прогресс игрока в одной истории.
+type playerStory struct {
+	playerID uuid.UUID
+	storyID  string
+}
+
+func (f *fakeSaveRepo) Create(_ context.Context, s domain.Save) error {
+	if s.Slot == "" {
+		s.Slot = domain.DefaultSlot
+	}
+	if f.active == nil {
+		f.active = map[playerStory]string{}
+		f.names = map[playerStory]map[string]string{}
+	}
+	key := playerStory{s.PlayerID, s.StoryID}
+	if f.names[key] == nil {
+		f.names[key] = map[string]string{}
+	}
+	if _, ok := f.names[key][s.Slot]; !ok {
+		f.names[key][s.Slot] = s.Slot
+	}
+	f.saves = append(f.saves, s)
+	f.active[key] = s.Slot
+	return nil
+}
+
+func (f *fakeSaveRepo) latest(playerID uuid.UUID, storyID, slot string) (domain.Save, error) {
+	for i := len(f.saves) - 1; i >= 0; i-- {
+		s := f.saves[i]
+		if s.PlayerID == playerID && s.StoryID == storyID && s.Slot == slot {
+			return s, nil
+		}
+	}
+	return domain.Save{}, repo.ErrSaveNotFound
+}
+
+func (f *fakeSaveRepo) GetLatestByPlayer(_ context.Context, playerID uuid.UUID, storyID string) (domain.Save, error) {
+	return f.latest(playerID, storyID, f.active[playerStory{playerID, storyID}])
+}
+
+func (f *fakeSaveRepo) ListSlots(_ context.Context, playerID uuid.UUID, storyID string) ([]domain.SaveSlot, error) {
+	key := playerStory{playerID, storyID}
+	var out []domain.SaveSlot
+	for slot, name := range f.names[key] {
+		s, _ := f.latest(playerID, storyID, slot)
+		out = append(out, domain.SaveSlot{
+			Slot: slot, Name: name, Active: f.active[key] == slot,
+			SceneID: s.SceneID, Stats: s.Stats, Finished: s.Finished(), LastPlayed: s.CreatedAt, StoryVersion: s.StoryVersion,
+		})
+	}
+	return out, nil
+}
+
+func (f *fakeSaveRepo) ActivateSlot(_ context.Context, playerID uuid.UUID, storyID, slot string) (domain.Save, error) {
+	s, err := f.latest(playerID, storyID, slot)
+	if err != nil {
+		return s, err
+	}
+	f.active[playerStory{playerID, storyID}] = slot
+	return s, nil
+}
+
+func (f *fakeSaveRepo) RenameSlot(_ context.Context, playerID uuid.UUID, storyID, slot, name string) error {
+	key := playerStory{playerID, storyID}
+	if _, ok := f.names[key][slot]; !ok {
+		return repo.ErrSaveNotFound
+	}
+	f.names[key][slot] = name
+	return nil
+}
+
+func (f *fakeSaveRepo) DeleteSlot(_ context.Context, playerID uuid.UUID, storyID, slot string) error {
+	key := playerStory{playerID, storyID}
+	if _, ok := f.names[key][slot]; !ok {
+		return repo.ErrSaveNotFound
+	}
+	delete(f.names[key], slot)
+	if f.active[key] == slot {
+		delete(f.active, key)
+	}
+	kept := f.saves[:0]
+	for _, s := range f.saves {
+		if s.PlayerID != playerID || s.StoryID != storyID || s.Slot != slot {
+			kept = append(kept, s)
+		}
+	}
+	f.saves = kept
+	return nil
+}
+
+func (f *fakeSaveRepo) ListHistory(_ context.Context, playerID uuid.UUID, storyID, slot string, limit, offset int) ([]domain.Save, error) {
+	var out []domain.Save
+	for i := len(f.saves) - 1; i >= 0; i-- {
+		s := f.saves[i]
+		if s.PlayerID == playerID && s.StoryID == storyID && s.Slot == slot {
+			out = append(out, s)
+		}
+	}
+	if offset >= len(out) {
+		return nil, nil
+	}
+	out = out[offset:]
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (f *fakeSaveRepo) GetByID(_ context.Context, playerID, saveID uuid.UUID) (domain.Save, error) {
+	for _, s := range f.saves {
+		if s.ID == saveID && s.PlayerID == playerID {
+			return s, nil
+		}
+	}
+	return domain.Save{}, repo.ErrSaveNotFound
+}
+
+func (f *fakeSaveRepo) ListOutdated(_ context.Context, storyID string, version int) ([]domain.Save, error) {
+	out := []domain.Save{}
+	for key, names := range f.names {
+		if key.storyID != storyID {
+			continue
+		}
+		for slot := range names {
+			if s, err := f.latest(key.playerID, storyID, slot); err == nil && s.StoryVersion != version {
+				out = append(out, s)
+			}
+		}
+	}
+	return out, nil
+}
+
+// fakeRunRepo — фейковая реализация RunRepo в памяти; сохранения пишет в saves.
+// Если задан err, Finish не записывает ничего и возвращает его.
+type fakeRunRepo struct {
+	saves repo.SaveRepo
+	runs  []domain.CompletedRun
+	err   error
+}
+
+func (f *fakeRunRepo) Finish(ctx context.Context, save domain.Save, run domain.CompletedRun) error {
+	if f.err != nil {
+		return f.err
+	}
+	if err := f.saves.Create(ctx, save); err != nil {
+		return err
+	}
+	f.runs = append(f.runs, run)
+	return nil
+}
+
+func (f *fakeRunRepo) ListByPlayer(_ context.Context, playerID uuid.UUID) ([]domain.CompletedRun, error) {
+	var out []domain.CompletedRun
+	for i := len(f.runs) - 1; i >= 0; i-- {
+		if f.runs[i].PlayerID == playerID {
+			out = append(out, f.runs[i])
+		}
+	}
+	return out, nil
+}
+
 func TestApplyChoice(t *testing.T) {
 	scene := domain.Scene{
 		ID:   "intro",
@@ -45,7 +270,7 @@ func TestApplyChoice(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			choice, err := svc.ApplyChoice(tc.scene, tc.choiceID)
+			choice, err := svc.ApplyChoice(tc.scene, tc.choiceID, domain.Save{})
 			if tc.wantErr {
 				if err == nil {
 					t.Errorf("ApplyChoice(%s) expected error", tc.choiceID)
@@ -71,8 +296,7 @@ func TestChoose(t *testing.T) {
 		},
 	}
 
-	fakeRepo := &fakeSceneRepo{scenes: map[string]domain.Scene{"intro": scene}}
-	svc := NewGameService(fakeRepo, nil)
+	svc, _ := newTestService(map[string]domain.Scene{"intro": scene}, nil)
 
 	cases := []struct {
 		name     string
@@ -88,7 +312,7 @@ func TestChoose(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			next, err := svc.Choose(tc.sceneID, tc.choiceID)
+			next, err := svc.Choose(testStory, tc.sceneID, tc.choiceID)
 			if tc.wantErr {
 				if err == nil {
 					t.Errorf("Choose(%s,%s) expected error", tc.sceneID, tc.choiceID)
@@ -104,3 +328,671 @@ func TestChoose(t *testing.T) {
 		})
 	}
 }
+
+func TestStartNewGame(t *testing.T) {
+	scene := domain.Scene{
+		ID:      "intro",
+		Choices: []domain.Choice{{ID: "attack", Next: "hallway", Effects: map[string]int{"rage": 1}}},
+	}
+	saves := &fakeSaveRepo{}
+	svc, stories := newTestService(map[string]domain.Scene{"intro": scene}, saves)
+	ctx := context.Background()
+	playerID := uuid.New()
+
+	// До начала игры выбор невозможен
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "attack"); !errors.Is(err, ErrGameNotStarted) {
+		t.Fatalf("ChooseForPlayer before start: got %v; want ErrGameNotStarted", err)
+	}
+
+	_, save, err := svc.StartNewGame(ctx, playerID, testStory, "")
+	if err != nil {
+		t.Fatalf("StartNewGame failed: %v", err)
+	}
+	if save.SceneID != "intro" || save.Stats["rage"] != 0 {
+		t.Errorf("unexpected initial save: %+v", save)
+	}
+
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "attack"); err != nil {
+		t.Fatalf("ChooseForPlayer after start: %v", err)
+	}
+
+	// Рестарт возвращает игрока на старт со сброшенными характеристиками
+	if _, _, err := svc.StartNewGame(ctx, playerID, testStory, ""); err != nil {
+		t.Fatalf("restart failed: %v", err)
+	}
+	latest, err := svc.GetLatestSave(ctx, playerID, testStory)
+	if err != nil {
+		t.Fatalf("GetLatestSave failed: %v", err)
+	}
+	if latest.SceneID != "intro" || latest.Stats["rage"] != 0 {
+		t.Errorf("after restart got %+v; want intro with zero stats", latest)
+	}
+
+	// Несуществующая стартовая сцена — ошибка
+	stories.story.Start = "missing"
+	if _, _, err := svc.StartNewGame(ctx, playerID, testStory, ""); err == nil {
+		t.Error("StartNewGame with missing start scene expected error")
+	}
+}
+
+func TestChooseForPlayerWrongScene(t *testing.T) {
+	scenes := map[string]domain.Scene{
+		"intro":   {ID: "intro", Choices: []domain.Choice{{ID: "attack", Next: "hallway", Effects: map[string]int{"rage": 1}}}},
+		"hallway": {ID: "hallway", Choices: []domain.Choice{{ID: "back", Next: "intro", Effects: map[string]int{"honor": 5}}}},
+	}
+	svc, _ := newTestService(scenes, &fakeSaveRepo{})
+	ctx := context.Background()
+	playerID := uuid.New()
+	if _, _, err := svc.StartNewGame(ctx, playerID, testStory, ""); err != nil {
+		t.Fatalf("StartNewGame failed: %v", err)
+	}
+
+	// Выбор из чужой сцены отклоняется, игрок остаётся на месте
+	_, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "hallway", "back")
+	var wrong *WrongSceneError
+	if !errors.As(err, &wrong) || !errors.Is(err, ErrWrongScene) {
+		t.Fatalf("got %v; want WrongSceneError", err)
+	}
+	if wrong.CurrentSceneID != "intro" {
+		t.Errorf("CurrentSceneID=%s; want intro", wrong.CurrentSceneID)
+	}
+	latest, _ := svc.GetLatestSave(ctx, playerID, testStory)
+	if latest.SceneID != "intro" || latest.Stats["honor"] != 0 {
+		t.Errorf("save changed after rejected choice: %+v", latest)
+	}
+
+	// Выбор из текущей сцены проходит
+	next, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "attack")
+	if err != nil || next != "hallway" {
+		t.Fatalf("ChooseForPlayer(intro, attack) = %s, %v", next, err)
+	}
+}
+
+func TestConditionalChoices(t *testing.T) {
+	scene := domain.Scene{
+		ID: "gate",
+		Choices: []domain.Choice{
+			{ID: "walk", Next: "road"},
+			{ID: "duel", Next: "duel", Requires: []domain.Condition{
+				{Any: []domain.Condition{{Stat: "honor", Op: ">=", Value: 3}, {Stat: "rage", Op: "<", Value: 2}}},
+			}},
+			{ID: "bribe", Next: "inn", Hidden: true, Requires: []domain.Condition{{Stat: "karma", Op: "<", Value: 0}}},
+		},
+	}
+	svc := NewGameService(nil, nil)
+
+	cases := []struct {
+		name       string
+		save       domain.Save
+		choiceID   string
+		wantLocked bool
+	}{
+		{"duel by honor", domain.Save{Stats: map[string]int{"honor": 3, "rage": 5}}, "duel", false},
+		{"duel by calm", domain.Save{Stats: map[string]int{"rage": 1}}, "duel", false},
+		{"duel locked", domain.Save{Stats: map[string]int{"honor": 2, "rage": 2}}, "duel", true},
+		{"bribe locked", domain.Save{}, "bribe", true},
+		{"bribe open", domain.Save{Stats: map[string]int{"karma": -1}}, "bribe", false},
+		{"no conditions", domain.Save{Stats: map[string]int{"rage": 9}}, "walk", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.ApplyChoice(scene, tc.choiceID, tc.save)
+			if got := errors.Is(err, ErrChoiceLocked); got != tc.wantLocked {
+				t.Errorf("ApplyChoice(%s) err=%v; want locked=%v", tc.choiceID, err, tc.wantLocked)
+			}
+		})
+	}
+
+	// Недоступный выбор помечается с причиной, скрытый — не показывается
+	view := svc.ViewScene(scene, domain.Save{Stats: map[string]int{"honor": 2, "rage": 2}}, "")
+	if len(view.Choices) != 2 {
+		t.Fatalf("got %d choices; want 2 (bribe hidden)", len(view.Choices))
+	}
+	duel := view.Choices[1]
+	if !duel.Locked || duel.LockedReason != "requires honor >= 3 or rage < 2" {
+		t.Errorf("duel view = %+v", duel)
+	}
+}
+
+func TestStoryStats(t *testing.T) {
+	maxRage, hidden := 3, false
+	story := domain.Story{Stats: []domain.StatDef{
+		{Name: "rage", Max: &maxRage},
+		{Name: "loyalty", Default: 1},
+		{Name: "curse", Visible: &hidden},
+	}}
+	scene := domain.Scene{ID: "intro", Choices: []domain.Choice{
+		{ID: "pledge", Next: "intro", Effects: map[string]int{"rage": 5, "loyalty": 2, "curse": 1, "luck": 1}},
+	}}
+	saves := &fakeSaveRepo{}
+	svc, stories := newTestService(map[string]domain.Scene{"intro": scene}, saves)
+	stories.story = story
+	ctx := context.Background()
+	playerID := uuid.New()
+
+	_, start, err := svc.StartNewGame(ctx, playerID, testStory, "")
+	if err != nil {
+		t.Fatalf("StartNewGame failed: %v", err)
+	}
+	if start.Stats["loyalty"] != 1 {
+		t.Errorf("default loyalty=%d; want 1", start.Stats["loyalty"])
+	}
+
+	_, save, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "pledge")
+	if err != nil {
+		t.Fatalf("ChooseForPlayer failed: %v", err)
+	}
+	want := map[string]int{"rage": 3, "loyalty": 3, "curse": 1, "luck": 1}
+	for k, v := range want {
+		if save.Stats[k] != v {
+			t.Errorf("stat %s=%d; want %d", k, save.Stats[k], v)
+		}
+	}
+	if _, ok := svc.VisibleStats(save)["curse"]; ok {
+		t.Error("hidden stat curse must not be visible")
+	}
+
+	// Характеристика, объявленная после создания сохранения, получает значение по умолчанию
+	stories.story.Stats = append(stories.story.Stats, domain.StatDef{Name: "fame", Default: 7})
+	latest, err := svc.GetLatestSave(ctx, playerID, testStory)
+	if err != nil {
+		t.Fatalf("GetLatestSave failed: %v", err)
+	}
+	if latest.Stats["fame"] != 7 {
+		t.Errorf("fame=%d; want default 7", latest.Stats["fame"])
+	}
+}
+
+func TestStoryFlags(t *testing.T) {
+	scenes := map[string]domain.Scene{
+		"temple": {
+			ID:   "temple",
+			Text: "Монах стоит у алтаря.",
+			ConditionalText: []domain.ConditionalText{
+				{Requires: []domain.Condition{{Flag: "spared_monk"}}, Text: "Он узнаёт тебя."},
+			},
+			Choices: []domain.Choice{
+				{ID: "spare", Next: "temple", SetFlags: []string{"spared_monk"}},
+				{ID: "take", Next: "temple", SetFlags: []string{"took_sword"}, ClearFlags: []string{"spared_monk"}},
+				{ID: "bless", Next: "temple", Requires: []domain.Condition{{Flag: "spared_monk"}, {NotFlag: "took_sword"}}},
+			},
+		},
+	}
+	svc, stories := newTestService(scenes, &fakeSaveRepo{})
+	stories.story.Start = "temple"
+	ctx := context.Background()
+	playerID := uuid.New()
+	if _, _, err := svc.StartNewGame(ctx, playerID, testStory, ""); err != nil {
+		t.Fatalf("StartNewGame failed: %v", err)
+	}
+
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "temple", "bless"); !errors.Is(err, ErrChoiceLocked) {
+		t.Fatalf("bless before spare: got %v; want ErrChoiceLocked", err)
+	}
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "temple", "spare"); err != nil {
+		t.Fatalf("spare failed: %v", err)
+	}
+
+	// Флаг переживает следующий снимок сохранения
+	view, save, _, err := svc.GetSceneForPlayer(ctx, playerID, testStory, "temple", "")
+	if err != nil {
+		t.Fatalf("GetSceneForPlayer failed: %v", err)
+	}
+	if !save.HasFlag("spared_monk") {
+		t.Errorf("flags=%v; want spared_monk", save.Flags)
+	}
+	if view.Text != "Монах стоит у алтаря.\n\nОн узнаёт тебя." {
+		t.Errorf("text=%q", view.Text)
+	}
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "temple", "bless"); err != nil {
+		t.Fatalf("bless after spare: %v", err)
+	}
+
+	_, save, err = svc.ChooseForPlayer(ctx, playerID, testStory, "temple", "take")
+	if err != nil {
+		t.Fatalf("take failed: %v", err)
+	}
+	if save.HasFlag("spared_monk") || !save.HasFlag("took_sword") {
+		t.Errorf("flags after take=%v; want [took_sword]", save.Flags)
+	}
+}
+
+func TestEndingFinishesRun(t *testing.T) {
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Choices: []domain.Choice{{ID: "die", Next: "grave", Effects: map[string]int{"rage": 2}}}},
+		"grave": {ID: "grave", Ending: &domain.Ending{ID: "fallen", Title: "Павший", Category: domain.EndingBad}},
+	}
+	saves := &fakeSaveRepo{}
+	svc, _ := newTestService(scenes, saves)
+	svc.RunRepo = &fakeRunRepo{saves: saves}
+	ctx := context.Background()
+	playerID := uuid.New()
+	if _, _, err := svc.StartNewGame(ctx, playerID, testStory, ""); err != nil {
+		t.Fatalf("StartNewGame failed: %v", err)
+	}
+
+	_, save, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "die")
+	if err != nil {
+		t.Fatalf("ChooseForPlayer failed: %v", err)
+	}
+	if !save.Finished() || save.EndingID != "fallen" {
+		t.Errorf("save after ending = %+v; want finished with fallen", save)
+	}
+	ending, err := svc.EndingOf(save, "")
+	if err != nil || ending == nil || ending.Category != domain.EndingBad {
+		t.Errorf("EndingOf = %+v, %v", ending, err)
+	}
+
+	completed, _ := svc.CompletedRuns(ctx, playerID, "")
+	if len(completed) != 1 || completed[0].Ending.ID != "fallen" || completed[0].Stats["rage"] != 2 {
+		t.Errorf("completed runs = %+v", completed)
+	}
+
+	// После концовки выбирать нельзя
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "grave", "anything"); !errors.Is(err, ErrRunFinished) {
+		t.Errorf("choose after ending: got %v; want ErrRunFinished", err)
+	}
+
+	// Новая игра снова разрешает выбор
+	if _, _, err := svc.StartNewGame(ctx, playerID, testStory, ""); err != nil {
+		t.Fatalf("StartNewGame failed: %v", err)
+	}
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "die"); err != nil {
+		t.Errorf("choose after restart: %v", err)
+	}
+	if completed, _ := svc.CompletedRuns(ctx, playerID, ""); len(completed) != 2 {
+		t.Errorf("got %d completed runs; want 2", len(completed))
+	}
+}
+
+func TestEndingRunWriteFailure(t *testing.T) {
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Choices: []domain.Choice{{ID: "die", Next: "grave"}}},
+		"grave": {ID: "grave", Ending: &domain.Ending{ID: "fallen", Category: domain.EndingBad}},
+	}
+	saves := &fakeSaveRepo{}
+	runs := &fakeRunRepo{saves: saves, err: errors.New("db down")}
+	svc, _ := newTestService(scenes, saves)
+	svc.RunRepo = runs
+	ctx := context.Background()
+	playerID := uuid.New()
+	if _, _, err := svc.StartNewGame(ctx, playerID, testStory, ""); err != nil {
+		t.Fatalf("StartNewGame failed: %v", err)
+	}
+
+	// 1. Сбой записи прохождения не оставляет сохранения с концовкой
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "die"); err == nil {
+		t.Fatal("choose with failing run write: expected error")
+	}
+	if save, _ := svc.GetLatestSave(ctx, playerID, testStory); save.Finished() || save.SceneID != "intro" {
+		t.Fatalf("save after failed run write = %+v; want unfinished at intro", save)
+	}
+
+	// 2. Повтор выбора проходит и записывает прохождение
+	runs.err = nil
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "die"); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if completed, _ := svc.CompletedRuns(ctx, playerID, ""); len(completed) != 1 {
+		t.Errorf("got %d completed runs after retry; want 1", len(completed))
+	}
+}
+
+func TestSceneEntryAndRedirects(t *testing.T) {
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", OnEnter: &domain.SceneEntry{Effects: map[string]int{"honor": 1}}, Choices: []domain.Choice{
+			{ID: "pray", Next: "gate", SetFlags: []string{"blessed"}},
+			{ID: "wander", Next: "maze"},
+		}},
+		"gate": {ID: "gate", OnEnter: &domain.SceneEntry{Effects: map[string]int{"honor": 100}}, Redirects: []domain.Redirect{
+			{Requires: []domain.Condition{{Flag: "blessed"}}, Next: "temple"},
+			{Next: "intro"},
+		}},
+		"temple": {ID: "temple", OnEnter: &domain.SceneEntry{Effects: map[string]int{"honor": 2}}, Ending: &domain.Ending{ID: "saint"}},
+		"maze":   {ID: "maze", Redirects: []domain.Redirect{{Next: "hedge"}}},
+		"hedge":  {ID: "hedge", Redirects: []domain.Redirect{{Next: "maze"}}},
+	}
+	saves := &fakeSaveRepo{}
+	svc, _ := newTestService(scenes, saves)
+	ctx := context.Background()
+	playerID := uuid.New()
+
+	// 1. OnEnter стартовой сцены применяется в начале игры
+	_, save, err := svc.StartNewGame(ctx, playerID, testStory, "")
+	if err != nil || save.Stats["honor"] != 1 {
+		t.Fatalf("StartNewGame = %+v, %v; want honor 1", save, err)
+	}
+
+	// 2. Зациклившиеся переходы отклоняют выбор, не записывая сохранение
+	_, _, err = svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "wander")
+	var loop *RedirectLoopError
+	if !errors.As(err, &loop) || !slices.Equal(loop.Path, []string{"maze", "hedge", "maze"}) {
+		t.Fatalf("choose into a loop: err = %v; want RedirectLoopError maze,hedge,maze", err)
+	}
+	if latest, _ := svc.GetLatestSave(ctx, playerID, testStory); latest.SceneID != "intro" {
+		t.Errorf("save after rejected choice is at %q; want intro", latest.SceneID)
+	}
+
+	// 3. Переход учитывает флаг выбора; пропущенная сцена не применяет OnEnter
+	next, save, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "pray")
+	if err != nil {
+		t.Fatalf("ChooseForPlayer: %v", err)
+	}
+	if next != "temple" || save.SceneID != "temple" || save.Stats["honor"] != 3 || save.EndingID != "saint" {
+		t.Errorf("after redirect: next = %q, save = %+v; want temple with honor 3 and ending saint", next, save)
+	}
+}
+
+func TestSaveSlots(t *testing.T) {
+	scenes := map[string]domain.Scene{
+		"intro":   {ID: "intro", Choices: []domain.Choice{{ID: "attack", Next: "hallway", Effects: map[string]int{"rage": 1}}}},
+		"hallway": {ID: "hallway"},
+	}
+	svc, _ := newTestService(scenes, &fakeSaveRepo{})
+	ctx := context.Background()
+	playerID := uuid.New()
+
+	// Основное прохождение уходит в коридор
+	_, main, err := svc.StartNewGame(ctx, playerID, testStory, "")
+	if err != nil || main.Slot != domain.DefaultSlot {
+		t.Fatalf("StartNewGame = %+v, %v", main, err)
+	}
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "attack"); err != nil {
+		t.Fatalf("ChooseForPlayer failed: %v", err)
+	}
+
+	// Второй слот начинается с начала и становится активным
+	if _, _, err := svc.StartNewGame(ctx, playerID, testStory, "alt"); err != nil {
+		t.Fatalf("StartNewGame(alt) failed: %v", err)
+	}
+	if cur, _ := svc.GetLatestSave(ctx, playerID, testStory); cur.Slot != "alt" || cur.SceneID != "intro" {
+		t.Errorf("current after new slot = %+v", cur)
+	}
+
+	// Загрузка основного слота возвращает игрока в коридор
+	loaded, err := svc.LoadSlot(ctx, playerID, testStory, domain.DefaultSlot)
+	if err != nil || loaded.SceneID != "hallway" {
+		t.Fatalf("LoadSlot(main) = %+v, %v", loaded, err)
+	}
+	if cur, _ := svc.GetLatestSave(ctx, playerID, testStory); cur.Slot != domain.DefaultSlot {
+		t.Errorf("active slot = %s; want main", cur.Slot)
+	}
+
+	if err := svc.RenameSlot(ctx, playerID, testStory, "alt", "Путь тени"); err != nil {
+		t.Fatalf("RenameSlot failed: %v", err)
+	}
+	slots, _ := svc.ListSlots(ctx, playerID, testStory)
+	if len(slots) != 2 {
+		t.Fatalf("got %d slots; want 2", len(slots))
+	}
+
+	if err := svc.DeleteSlot(ctx, playerID, testStory, "alt"); err != nil {
+		t.Fatalf("DeleteSlot failed: %v", err)
+	}
+	if _, err := svc.LoadSlot(ctx, playerID, testStory, "alt"); !errors.Is(err, ErrSlotNotFound) {
+		t.Errorf("LoadSlot(deleted) = %v; want ErrSlotNotFound", err)
+	}
+	if _, _, err := svc.StartNewGame(ctx, playerID, testStory, "../etc"); !errors.Is(err, ErrInvalidSlot) {
+		t.Errorf("StartNewGame(bad slot) = %v; want ErrInvalidSlot", err)
+	}
+}
+
+func TestHistoryAndRestore(t *testing.T) {
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Choices: []domain.Choice{{ID: "attack", Outcomes: []domain.Outcome{
+			{ID: "hit", Next: "hallway", Effects: map[string]int{"rage": 2}},
+		}}}},
+		"hallway": {ID: "hallway", Choices: []domain.Choice{{ID: "calm", Next: "garden", Effects: map[string]int{"rage": -1, "honor": 1}}}},
+		"garden":  {ID: "garden"},
+	}
+	svc, stories := newTestService(scenes, &fakeSaveRepo{})
+	ctx := context.Background()
+	playerID := uuid.New()
+
+	svc.StartNewGame(ctx, playerID, testStory, "")
+	svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "attack")
+	svc.ChooseForPlayer(ctx, playerID, testStory, "hallway", "calm")
+
+	history, err := svc.History(ctx, playerID, testStory, "", 2, 0)
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	if len(history) != 2 || history[0].SceneID != "garden" || history[1].SceneID != "hallway" {
+		t.Fatalf("history = %+v", history)
+	}
+	if history[0].Deltas["rage"] != -1 || history[0].Deltas["honor"] != 1 || history[1].Deltas["rage"] != 2 {
+		t.Errorf("deltas = %v, %v", history[0].Deltas, history[1].Deltas)
+	}
+	if page, _ := svc.History(ctx, playerID, testStory, "", 2, 2); len(page) != 1 || page[0].SceneID != "intro" {
+		t.Errorf("second page = %+v", page)
+	}
+
+	// По умолчанию откат запрещён
+	if _, err := svc.Restore(ctx, playerID, testStory, history[1].ID); !errors.Is(err, ErrRewindForbidden) {
+		t.Fatalf("Restore without policy = %v; want ErrRewindForbidden", err)
+	}
+
+	stories.story.AllowRewind = true
+	restored, err := svc.Restore(ctx, playerID, testStory, history[1].ID)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if restored.SceneID != "hallway" || restored.Stats["rage"] != 2 {
+		t.Errorf("restored = %+v", restored)
+	}
+	if history[1].Roll == nil || restored.Roll != nil {
+		t.Errorf("roll of snapshot = %+v, of restored = %+v; want the restored copy without a roll", history[1].Roll, restored.Roll)
+	}
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "hallway", "calm"); err != nil {
+		t.Errorf("choose after restore: %v", err)
+	}
+	if _, err := svc.Restore(ctx, uuid.New(), testStory, history[1].ID); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("Restore foreign snapshot = %v; want ErrSnapshotNotFound", err)
+	}
+}
+
+func TestStoriesKeepSeparateProgress(t *testing.T) {
+	ronin := &fakeSceneRepo{scenes: map[string]domain.Scene{
+		"intro":   {ID: "intro", Choices: []domain.Choice{{ID: "attack", Next: "hallway", Effects: map[string]int{"rage": 1}}}},
+		"hallway": {ID: "hallway"},
+	}}
+	monk := &fakeSceneRepo{
+		scenes: map[string]domain.Scene{"gate": {ID: "gate", Choices: []domain.Choice{{ID: "pray", Next: "gate"}}}},
+		story:  domain.Story{Title: "Монах", Start: "gate"},
+	}
+	svc := NewGameService(fakeCatalog{testStory: ronin, "monk": monk}, &fakeSaveRepo{})
+	ctx := context.Background()
+	playerID := uuid.New()
+
+	svc.StartNewGame(ctx, playerID, testStory, "")
+	svc.StartNewGame(ctx, playerID, "monk", "")
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "attack"); err != nil {
+		t.Fatalf("ChooseForPlayer(ronin) failed: %v", err)
+	}
+
+	// Прогресс в одной истории не сдвигает другую
+	if cur, _ := svc.GetLatestSave(ctx, playerID, "monk"); cur.SceneID != "gate" || cur.StoryID != "monk" {
+		t.Errorf("monk save = %+v", cur)
+	}
+	if cur, _ := svc.GetLatestSave(ctx, playerID, testStory); cur.SceneID != "hallway" {
+		t.Errorf("ronin save = %+v", cur)
+	}
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, "monk", "intro", "attack"); !errors.Is(err, ErrWrongScene) {
+		t.Errorf("choice from another story's scene = %v; want ErrWrongScene", err)
+	}
+
+	if _, err := svc.GetStory("missing", ""); !errors.Is(err, ErrStoryNotFound) {
+		t.Errorf("GetStory(missing) = %v; want ErrStoryNotFound", err)
+	}
+	if stories := svc.ListStories(""); len(stories) != 2 || stories[0].ID != "monk" {
+		t.Errorf("ListStories = %+v", stories)
+	}
+}
+
+func TestViewSceneRendersTemplates(t *testing.T) {
+	scene := domain.Scene{
+		ID:   "gate",
+		Text: "{{player}}, твоя честь — {{honor}}.{{if spared_monk and honor >= 3}} Монах кланяется.{{else}} Монах молчит.{{end}}",
+		Choices: []domain.Choice{
+			{ID: "go", Text: "Войти{{if not spared_monk}} с мечом{{end}}", Next: "gate"},
+		},
+	}
+	svc, _ := newTestService(nil, &fakeSaveRepo{})
+
+	view := svc.ViewScene(scene, domain.Save{Stats: map[string]int{"honor": 3}, Flags: []string{"spared_monk"}}, "kenji")
+	if view.Text != "kenji, твоя честь — 3. Монах кланяется." {
+		t.Errorf("text = %q", view.Text)
+	}
+	if view.Choices[0].Text != "Войти" {
+		t.Errorf("choice text = %q", view.Choices[0].Text)
+	}
+
+	view = svc.ViewScene(scene, domain.Save{Stats: map[string]int{"honor": 5}}, "kenji")
+	if view.Text != "kenji, твоя честь — 5. Монах молчит." || view.Choices[0].Text != "Войти с мечом" {
+		t.Errorf("view without flag = %q / %q", view.Text, view.Choices[0].Text)
+	}
+	if scene.Choices[0].Text != "Войти{{if not spared_monk}} с мечом{{end}}" {
+		t.Errorf("source choice was modified: %q", scene.Choices[0].Text)
+	}
+}
+
+func TestSkillCheckIsReproducible(t *testing.T) {
+	scenes := map[string]domain.Scene{
+		"cliff": {ID: "cliff", Choices: []domain.Choice{{
+			ID: "leap",
+			Check: &domain.SkillCheck{
+				Stat: "rage", Dice: 20, Difficulty: 11,
+				Success: domain.Outcome{Next: "far", Effects: map[string]int{"honor": 1}},
+				Failure: domain.Outcome{ID: "fell", Next: "far", Effects: map[string]int{"rage": 1}},
+			},
+		}}},
+		"far": {ID: "far", Choices: []domain.Choice{{
+			ID: "gamble",
+			Outcomes: []domain.Outcome{
+				{ID: "win", Weight: 3, Next: "far"},
+				{ID: "lose", Weight: 1, Next: "far"},
+			},
+		}}},
+	}
+	saves := &fakeSaveRepo{}
+	svc, stories := newTestService(scenes, saves)
+	stories.story = domain.Story{Start: "cliff", AllowRewind: true, Stats: []domain.StatDef{{Name: "rage", Default: 3}, {Name: "honor"}}}
+	ctx := context.Background()
+	playerID := uuid.New()
+	_, start, err := svc.StartNewGame(ctx, playerID, testStory, "")
+	if err != nil {
+		t.Fatalf("StartNewGame failed: %v", err)
+	}
+
+	// 1. Проверка навыка: кубик + rage против сложности, бросок записан в сохранение
+	_, save, err := svc.ChooseForPlayer(ctx, playerID, testStory, "cliff", "leap")
+	if err != nil {
+		t.Fatalf("leap failed: %v", err)
+	}
+	roll := save.Roll
+	if roll == nil || roll.Kind != domain.RollCheck || roll.Modifier != 3 || roll.Total != roll.Value+3 {
+		t.Fatalf("roll = %+v", roll)
+	}
+	if roll.Value < 1 || roll.Value > 20 || roll.Success != (roll.Total >= 11) {
+		t.Errorf("roll out of range or misjudged: %+v", roll)
+	}
+	if roll.Success && (roll.Outcome != "success" || save.Stats["honor"] != 1) ||
+		!roll.Success && (roll.Outcome != "fell" || save.Stats["rage"] != 4) {
+		t.Errorf("outcome %q with stats %v", roll.Outcome, save.Stats)
+	}
+	if save.Seed != start.Seed || save.Rolls != 1 {
+		t.Errorf("seed/rolls = %d/%d; want %d/1", save.Seed, save.Rolls, start.Seed)
+	}
+
+	// 2. Откат к началу и повтор дают тот же бросок
+	if _, err := svc.Restore(ctx, playerID, testStory, start.ID); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	_, again, err := svc.ChooseForPlayer(ctx, playerID, testStory, "cliff", "leap")
+	if err != nil {
+		t.Fatalf("leap after restore failed: %v", err)
+	}
+	if *again.Roll != *roll {
+		t.Errorf("replayed roll = %+v; want %+v", again.Roll, roll)
+	}
+
+	// 3. Случайный исход по весам: последовательные броски берут новые номера
+	_, next, err := svc.ChooseForPlayer(ctx, playerID, testStory, "far", "gamble")
+	if err != nil {
+		t.Fatalf("gamble failed: %v", err)
+	}
+	if next.Roll == nil || next.Roll.Kind != domain.RollRandom || next.Rolls != 2 ||
+		(next.Roll.Outcome != "win" && next.Roll.Outcome != "lose") {
+		t.Errorf("gamble roll = %+v, rolls = %d", next.Roll, next.Rolls)
+	}
+
+	// 4. Распределение по весам примерно 3:1
+	wins := 0
+	choice := scenes["far"].Choices[0]
+	for i := 0; i < 4000; i++ {
+		if choice.Resolve(domain.Save{Seed: 42, Rolls: i}).Roll.Outcome == "win" {
+			wins++
+		}
+	}
+	if wins < 2800 || wins > 3200 {
+		t.Errorf("wins = %d of 4000; want about 3000", wins)
+	}
+}
+
+func TestEffectOps(t *testing.T) {
+	ptr := func(v int) *int { return &v }
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Choices: []domain.Choice{{
+			ID: "calm", Next: "temple", Effects: map[string]int{"rage": 3},
+			Ops: []domain.Effect{
+				{Stat: "honor", Op: domain.OpCopy, From: "rage"},
+				{Stat: "honor", Op: domain.OpMul, Value: 4},
+				{Stat: "rage", Op: domain.OpSet, Value: 0},
+				{Stat: "karma", Op: domain.OpRandom, Min: ptr(1), Max: ptr(6)},
+				{Stat: "karma", Op: domain.OpClamp, Max: ptr(2)},
+			},
+		}}},
+		"temple": {ID: "temple", Choices: []domain.Choice{{ID: "stay", Next: "temple"}}},
+	}
+	saves := &fakeSaveRepo{}
+	svc, stories := newTestService(scenes, saves)
+	stories.story = domain.Story{Start: "intro", Stats: []domain.StatDef{
+		{Name: "rage", Default: 4},
+		{Name: "honor", Max: ptr(10)},
+		{Name: "karma", Visible: new(bool)},
+	}}
+	ctx := context.Background()
+	playerID := uuid.New()
+	if _, _, err := svc.StartNewGame(ctx, playerID, testStory, ""); err != nil {
+		t.Fatalf("StartNewGame: %v", err)
+	}
+	start, _ := svc.GetLatestSave(ctx, playerID, testStory)
+
+	// 1. Прибавки, затем операции по порядку; границы действуют после каждой
+	_, save, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "calm")
+	if err != nil {
+		t.Fatalf("ChooseForPlayer: %v", err)
+	}
+	if save.Stats["rage"] != 0 || save.Stats["honor"] != 10 || save.Stats["karma"] < 1 || save.Stats["karma"] > 2 || save.Rolls != start.Rolls+1 {
+		t.Errorf("stats = %v, rolls = %d; want rage 0, honor 10, karma 1..2 and one roll", save.Stats, save.Rolls)
+	}
+	var got []string
+	for _, e := range save.Effects {
+		got = append(got, fmt.Sprintf("%s %s %d->%d", e.Stat, e.Op, e.Before, e.After))
+	}
+	want := []string{"rage add 4->7", "honor copy 0->7", "honor mul 7->10", "rage set 7->0"}
+	if len(got) != 6 || !slices.Equal(got[:4], want) {
+		t.Errorf("effects = %v; want %v and two karma ops", got, want)
+	}
+
+	// 2. Скрытые характеристики не попадают в ответ
+	if visible := svc.VisibleEffects(save); len(visible) != 4 {
+		t.Errorf("visible effects = %+v; want 4 without karma", visible)
+	}
+
+	// 3. Случайная операция повторяется при том же зерне и номере броска
+	again := stories.story.ApplyOps(start, scenes["intro"].Choices[0].Effects, scenes["intro"].Choices[0].Ops)
+	if again.Effects[4] != save.Effects[4] {
+		t.Errorf("random op is not reproducible: %+v vs %+v", again.Effects[4], save.Effects[4])
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/repo"
+
+	"github.com/google/uuid"
+)
+
+// MaxHistoryLimit — верхняя граница размера страницы истории.
+const MaxHistoryLimit = 100
+
+// HistoryEntry — один снимок прохождения с изменениями относительно предыдущего.
+type HistoryEntry struct {
+	ID        uuid.UUID      `json:"id"`
+	SceneID   string         `json:"scene_id"`
+	Stats     map[string]int `json:"stats"`
+	Deltas    map[string]int `json:"deltas"`
+	Flags     []string       `json:"flags"`
+	Finished  bool           `json:"finished"`
+	Roll      *domain.Roll   `json:"roll,omitempty"` // бросок, которым определён переход в снимок
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// History возвращает страницу истории слота в истории storyID, новые снимки первыми.
+// Пустой slot означает активный слот. Для каждого снимка считаются изменения
+// видимых характеристик относительно предыдущего снимка того же слота.
+func (g *GameService) History(ctx context.Context, playerID uuid.UUID, storyID, slot string, limit, offset int) ([]HistoryEntry, error) {
+	_, story, err := g.scenes(storyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Определяем слот
+	if slot == "" {
+		current, err := g.GetLatestSave(ctx, playerID, storyID)
+		var outdated *SaveOutdatedError
+		switch {
+		case err == nil:
+			slot = current.Slot
+		case errors.As(err, &outdated):
+			// прошлые снимки видны, даже если прохождение не переносится на новую версию
+			slot = outdated.Slot
+		default:
+			return nil, err
+		}
+	}
+	if !slotPattern.MatchString(slot) {
+		return nil, ErrInvalidSlot
+	}
+	if limit <= 0 || limit > MaxHistoryLimit {
+		limit = MaxHistoryLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// 2. Берём на один снимок больше, чтобы посчитать изменения для последнего на странице
+	saves, err := g.SaveRepo.ListHistory(ctx, playerID, storyID, slot, limit+1, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. Собираем записи с изменениями
+	entries := []HistoryEntry{}
+	for i := 0; i < len(saves) && i < limit; i++ {
+		stats := story.VisibleStats(story.Normalize(saves[i].Stats))
+		prev := map[string]int{}
+		if i+1 < len(saves) {
+			prev = story.VisibleStats(story.Normalize(saves[i+1].Stats))
+		}
+		entries = append(entries, HistoryEntry{
+			ID:        saves[i].ID,
+			SceneID:   saves[i].SceneID,
+			Stats:     stats,
+			Deltas:    domain.StatDeltas(prev, stats),
+			Flags:     saves[i].Flags,
+			Finished:  saves[i].Finished(),
+			Roll:      saves[i].Roll,
+			CreatedAt: saves[i].CreatedAt,
+		})
+	}
+	return entries, nil
+}
+
+// Restore откатывает прохождение к снимку saveID: в слот снимка дописывается
+// новая запись с его состоянием, и слот становится активным.
+// Сама история не переписывается, поэтому откат можно отменить, восстановив более поздний снимок.
+// Снимок другой истории считается ненайденным. Снимок прежней версии истории
+// переносится на текущую; если он не переносится, возвращается *SaveOutdatedError.
+func (g *GameService) Restore(ctx context.Context, playerID uuid.UUID, storyID string, saveID uuid.UUID) (domain.Save, error) {
+	scenes, story, err := g.scenes(storyID)
+	if err != nil {
+		return domain.Save{}, err
+	}
+	if !story.AllowRewind {
+		return domain.Save{}, ErrRewindForbidden
+	}
+
+	snapshot, err := g.SaveRepo.GetByID(ctx, playerID, saveID)
+	if errors.Is(err, repo.ErrSaveNotFound) || (err == nil && snapshot.StoryID != storyID) {
+		return domain.Save{}, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return domain.Save{}, err
+	}
+
+	restored, err := migrateSave(scenes, story, snapshot)
+	if err != nil {
+		return domain.Save{}, err
+	}
+	restored.ID = uuid.New()
+	restored.Roll = nil // бросок снимка относится к переходу в него, а не к откату
+	restored.CreatedAt = time.Now()
+	if err := g.SaveRepo.Create(ctx, restored); err != nil {
+		return domain.Save{}, err
+	}
+	return restored, nil
+}
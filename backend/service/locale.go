@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/repo"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+)
+
+// ResolveLocale выбирает язык, на котором игрок читает историю storyID:
+// по настройкам игрока, если он выбирал язык, иначе по Accept-Language (см. NegotiateLocale).
+// Ошибка чтения настроек не мешает игре: язык тогда берётся из Accept-Language.
+// Для анонимного запроса playerID равен uuid.Nil.
+func (g *GameService) ResolveLocale(ctx context.Context, playerID uuid.UUID, storyID, acceptLanguage string) string {
+	var pref string
+	if g.Locales != nil && playerID != uuid.Nil {
+		pref, _ = g.Locales.GetLocale(ctx, playerID)
+	}
+	return NegotiateLocale(g.story(storyID), pref, acceptLanguage)
+}
+
+// NegotiateLocale выбирает язык истории. Порядок: язык pref, затем заголовок
+// Accept-Language, затем язык исходного текста. Выбирается только язык,
+// на который история переведена; ближайший родственный тоже подходит (en-GB → en).
+func NegotiateLocale(story domain.Story, pref, acceptLanguage string) string {
+	source := story.SourceLocale()
+	if len(story.Locales) < 2 {
+		return source
+	}
+
+	// 1. Явный выбор игрока
+	if tag, err := language.Parse(pref); pref != "" && err == nil {
+		if locale, ok := matchLocale(story.Locales, tag); ok {
+			return locale
+		}
+	}
+
+	// 2. Предпочтения браузера
+	if tags, _, err := language.ParseAcceptLanguage(acceptLanguage); err == nil && len(tags) > 0 {
+		if locale, ok := matchLocale(story.Locales, tags...); ok {
+			return locale
+		}
+	}
+
+	// 3. Язык исходного текста
+	return source
+}
+
+// matchLocale подбирает из supported язык, ближайший к prefs.
+func matchLocale(supported []string, prefs ...language.Tag) (string, bool) {
+	tags := make([]language.Tag, len(supported))
+	for i, s := range supported {
+		tags[i] = language.Make(s)
+	}
+	_, i, conf := language.NewMatcher(tags).Match(prefs...)
+	if conf == language.No {
+		return "", false
+	}
+	return supported[i], true
+}
+
+// SetPlayerLocale сохраняет язык игрока и возвращает его канонический тег.
+// Пустая строка сбрасывает выбор: язык снова берётся из Accept-Language.
+func (g *GameService) SetPlayerLocale(ctx context.Context, playerID uuid.UUID, locale string) (string, error) {
+	if g.Locales == nil {
+		return "", fmt.Errorf("%w: locale preferences are not supported", ErrInvalidInput)
+	}
+	if locale != "" {
+		tag, err := language.Parse(locale)
+		if err != nil {
+			return "", fmt.Errorf("%w: unknown locale %q", ErrInvalidInput, locale)
+		}
+		locale = tag.String()
+	}
+	if err := g.Locales.SetLocale(ctx, playerID, locale); err != nil {
+		return "", err
+	}
+	return locale, nil
+}
+
+// translation возвращает перевод истории на язык locale или nil,
+// если locale — язык исходного текста или перевода нет.
+func translation(scenes repo.StoryScenes, story domain.Story, locale string) domain.Translation {
+	if locale == "" || locale == story.SourceLocale() {
+		return nil
+	}
+	if t, ok := scenes.(repo.Translator); ok {
+		return t.Translation(locale)
+	}
+	return nil
+}
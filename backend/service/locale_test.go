@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"blood-on-maple-leaves/backend/domain"
+
+	"github.com/google/uuid"
+)
+
+// fakeLocaleRepo — языковые настройки игроков в памяти.
+type fakeLocaleRepo map[uuid.UUID]string
+
+func (f fakeLocaleRepo) GetLocale(_ context.Context, playerID uuid.UUID) (string, error) {
+	return f[playerID], nil
+}
+
+func (f fakeLocaleRepo) SetLocale(_ context.Context, playerID uuid.UUID, locale string) error {
+	f[playerID] = locale
+	return nil
+}
+
+func TestNegotiateLocale(t *testing.T) {
+	story := domain.Story{Locales: []string{"ru", "en"}}
+	cases := []struct {
+		pref, accept, want string
+	}{
+		{"", "", "ru"},
+		{"", "en-US,en;q=0.9", "en"},
+		{"", "de-DE, fr;q=0.8", "ru"},
+		{"", "de, en;q=0.5", "en"},
+		{"ru", "en", "ru"},
+		{"en-GB", "", "en"},
+		{"ja", "en", "en"},
+		{"", "not a header;;", "ru"},
+	}
+	for _, c := range cases {
+		if got := NegotiateLocale(story, c.pref, c.accept); got != c.want {
+			t.Errorf("NegotiateLocale(pref=%q, accept=%q) = %q; want %q", c.pref, c.accept, got, c.want)
+		}
+	}
+
+	// История без переводов всегда на исходном языке
+	if got := NegotiateLocale(domain.Story{Locale: "en"}, "ru", "ru"); got != "en" {
+		t.Errorf("untranslated story locale = %q; want en", got)
+	}
+}
+
+func TestGetSceneForPlayerLocalized(t *testing.T) {
+	scenes := map[string]domain.Scene{
+		"intro": {
+			ID:              "intro",
+			Text:            "Дождь не стихает.",
+			ConditionalText: []domain.ConditionalText{{Text: "Монах ждёт."}},
+			Choices: []domain.Choice{
+				{ID: "fight", Text: "Обнажить меч", Next: "grave"},
+				{ID: "wait", Text: "Ждать", Next: "intro"},
+			},
+		},
+		"grave": {ID: "grave", Text: "Конец.", Ending: &domain.Ending{ID: "fallen", Title: "Павший"}},
+	}
+	svc, stories := newTestService(scenes, &fakeSaveRepo{})
+	stories.story.Locales = []string{"ru", "en"}
+	stories.translations = map[string]domain.Translation{"en": {
+		"intro.text":         "The rain does not stop.",
+		"intro.text.0":       "The monk waits.",
+		"intro.choice.fight": "Draw the sword",
+		"grave.ending":       "Fallen",
+	}}
+	locales := fakeLocaleRepo{}
+	svc.Locales = locales
+	ctx := context.Background()
+	playerID := uuid.New()
+
+	// Язык из Accept-Language; непереведённый выбор остаётся на русском
+	locale := svc.ResolveLocale(ctx, playerID, testStory, "en-US")
+	view, _, _, err := svc.GetSceneForPlayer(ctx, playerID, testStory, "intro", locale)
+	if err != nil {
+		t.Fatalf("GetSceneForPlayer failed: %v", err)
+	}
+	if view.Text != "The rain does not stop.\n\nThe monk waits." {
+		t.Errorf("text = %q", view.Text)
+	}
+	if view.Choices[0].Text != "Draw the sword" || view.Choices[1].Text != "Ждать" {
+		t.Errorf("choices = %+v", view.Choices)
+	}
+
+	// Снимок истории не изменился
+	if scenes["intro"].Choices[0].Text != "Обнажить меч" {
+		t.Errorf("source scene was modified: %+v", scenes["intro"])
+	}
+
+	// Настройки игрока важнее Accept-Language
+	if _, err := svc.SetPlayerLocale(ctx, playerID, "ru-RU"); err != nil {
+		t.Fatalf("SetPlayerLocale failed: %v", err)
+	}
+	if got := svc.ResolveLocale(ctx, playerID, testStory, "en"); got != "ru" {
+		t.Errorf("ResolveLocale with preference = %q; want ru", got)
+	}
+	if _, err := svc.SetPlayerLocale(ctx, playerID, "!!"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("SetPlayerLocale(!!) = %v; want ErrInvalidInput", err)
+	}
+
+	ending, err := svc.EndingOf(domain.Save{StoryID: testStory, SceneID: "grave", EndingID: "fallen"}, "en")
+	if err != nil || ending == nil || ending.Title != "Fallen" {
+		t.Errorf("EndingOf(en) = %+v, %v", ending, err)
+	}
+}
+
+func TestCompletedRunsLocalized(t *testing.T) {
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Choices: []domain.Choice{{ID: "fight", Next: "grave"}}},
+		"grave": {ID: "grave", Ending: &domain.Ending{ID: "fallen", Title: "Павший"}},
+	}
+	saves := &fakeSaveRepo{}
+	svc, stories := newTestService(scenes, saves)
+	svc.RunRepo = &fakeRunRepo{saves: saves}
+	stories.story.Locales = []string{"ru", "en"}
+	stories.translations = map[string]domain.Translation{"en": {"grave.ending": "Fallen"}}
+	ctx := context.Background()
+	playerID := uuid.New()
+	svc.StartNewGame(ctx, playerID, testStory, "")
+	if _, _, err := svc.ChooseForPlayer(ctx, playerID, testStory, "intro", "fight"); err != nil {
+		t.Fatalf("ChooseForPlayer failed: %v", err)
+	}
+
+	for accept, want := range map[string]string{"en-US": "Fallen", "": "Павший"} {
+		runs, err := svc.CompletedRuns(ctx, playerID, accept)
+		if err != nil || len(runs) != 1 || runs[0].Ending.Title != want {
+			t.Errorf("CompletedRuns(%q) = %+v, %v; want ending %q", accept, runs, err, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/repo"
+
+	"github.com/google/uuid"
+)
+
+// migrateSave переносит прочитанное сохранение на текущую версию истории
+// и дополняет характеристики значениями по умолчанию. В базе сохранение
+// не переписывается: следующий выбор запишет новый снимок уже на текущей версии.
+// Если после миграций игрок оказывается в несуществующей сцене, возвращает *SaveOutdatedError.
+func migrateSave(scenes repo.SceneRepo, story domain.Story, save domain.Save) (domain.Save, error) {
+	if save.StoryVersion != story.Version {
+		from := save.StoryVersion
+		save = story.MigrateSave(save)
+		if _, err := scenes.Load(save.SceneID); err != nil {
+			if errors.Is(err, repo.ErrSceneNotFound) {
+				return domain.Save{}, &SaveOutdatedError{
+					Slot:        save.Slot,
+					SceneID:     save.SceneID,
+					FromVersion: from,
+					ToVersion:   story.Version,
+				}
+			}
+			return domain.Save{}, err
+		}
+	}
+	save.Stats = story.Normalize(save.Stats)
+	return save, nil
+}
+
+// SaveMigrationIssue — сохранение, которое не переносится на текущую версию истории.
+type SaveMigrationIssue struct {
+	SaveID       uuid.UUID `json:"save_id"`
+	PlayerID     uuid.UUID `json:"player_id"`
+	Slot         string    `json:"slot"`
+	StoryVersion int       `json:"story_version"` // версия, на которой сделано сохранение
+	SceneID      string    `json:"scene_id"`      // сцена после миграций, которой нет в истории
+}
+
+// MigrationReport — итог проверки сохранений истории на совместимость с её текущей версией.
+type MigrationReport struct {
+	StoryID  string               `json:"story_id"`
+	Version  int                  `json:"version"`
+	Outdated int                  `json:"outdated"` // слотов, сохранённых на прежних версиях
+	Failed   []SaveMigrationIssue `json:"failed"`   // из них не переносятся
+}
+
+// MigrationReport проверяет последние сохранения всех слотов истории, сделанные
+// на прежних версиях, и перечисляет те, которые миграции не переносят.
+// Такие игроки получат ErrSaveOutdated и смогут только начать игру заново,
+// поэтому отчёт стоит смотреть перед публикацией и после неё.
+func (g *GameService) MigrationReport(ctx context.Context, storyID string) (MigrationReport, error) {
+	scenes, story, err := g.scenes(storyID)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+	saves, err := g.SaveRepo.ListOutdated(ctx, storyID, story.Version)
+	if err != nil {
+		return MigrationReport{}, err
+	}
+
+	report := MigrationReport{StoryID: storyID, Version: story.Version, Outdated: len(saves), Failed: []SaveMigrationIssue{}}
+	for _, save := range saves {
+		_, err := migrateSave(scenes, story, save)
+		var outdated *SaveOutdatedError
+		if errors.As(err, &outdated) {
+			report.Failed = append(report.Failed, SaveMigrationIssue{
+				SaveID:       save.ID,
+				PlayerID:     save.PlayerID,
+				Slot:         save.Slot,
+				StoryVersion: save.StoryVersion,
+				SceneID:      outdated.SceneID,
+			})
+			continue
+		}
+		if err != nil {
+			return MigrationReport{}, err
+		}
+	}
+	return report, nil
+}
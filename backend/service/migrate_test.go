@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"blood-on-maple-leaves/backend/domain"
+
+	"github.com/google/uuid"
+)
+
+func TestSaveMigration(t *testing.T) {
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Choices: []domain.Choice{{ID: "go", Next: "ford"}}},
+		"ford":  {ID: "ford", Choices: []domain.Choice{{ID: "cross", Next: "intro"}}},
+	}
+	saves := &fakeSaveRepo{}
+	svc, stories := newTestService(scenes, saves)
+	stories.story = domain.Story{Version: 3, Start: "intro", Stats: []domain.StatDef{{Name: "honor"}}, Migrations: []domain.Migration{
+		{Version: 2, Scenes: map[string]string{"gate": "bridge"}, Stats: map[string]int{"honor": 1}},
+		{Version: 3, Scenes: map[string]string{"bridge": "ford"}},
+	}}
+	ctx := context.Background()
+
+	// Сохранения прежних версий: одно переносится по цепочке миграций, другое — нет
+	movedID, lostID := uuid.New(), uuid.New()
+	saves.Create(ctx, domain.Save{ID: uuid.New(), PlayerID: movedID, StoryID: testStory, StoryVersion: 1, SceneID: "gate", Stats: map[string]int{}})
+	saves.Create(ctx, domain.Save{ID: uuid.New(), PlayerID: lostID, StoryID: testStory, StoryVersion: 2, SceneID: "gate", Stats: map[string]int{}})
+
+	// 1. Перенесённое сохранение играется как обычно и пишется уже на текущей версии
+	save, err := svc.GetLatestSave(ctx, movedID, testStory)
+	if err != nil {
+		t.Fatalf("GetLatestSave: %v", err)
+	}
+	if save.SceneID != "ford" || save.Stats["honor"] != 1 || save.StoryVersion != 3 {
+		t.Errorf("migrated save = %+v; want ford with honor 1 at version 3", save)
+	}
+	if _, next, err := svc.ChooseForPlayer(ctx, movedID, testStory, "ford", "cross"); err != nil || next.StoryVersion != 3 {
+		t.Errorf("ChooseForPlayer after migration = %+v, %v", next, err)
+	}
+
+	// 2. Непереносимое сохранение отдаёт понятную ошибку и попадает в отчёт
+	_, err = svc.GetLatestSave(ctx, lostID, testStory)
+	var outdated *SaveOutdatedError
+	if !errors.As(err, &outdated) || outdated.SceneID != "gate" || outdated.FromVersion != 2 {
+		t.Fatalf("GetLatestSave of a lost save: err = %v; want SaveOutdatedError at gate", err)
+	}
+	report, err := svc.MigrationReport(ctx, testStory)
+	if err != nil {
+		t.Fatalf("MigrationReport: %v", err)
+	}
+	if report.Outdated != 1 || len(report.Failed) != 1 || report.Failed[0].PlayerID != lostID {
+		t.Errorf("report = %+v; want only the lost save", report)
+	}
+
+	// 3. Игра заново начинается в том же слоте, и отчёт пустеет
+	if _, save, err := svc.StartNewGame(ctx, lostID, testStory, ""); err != nil || save.Slot != domain.DefaultSlot {
+		t.Fatalf("StartNewGame over a lost save = %+v, %v", save, err)
+	}
+	if report, _ := svc.MigrationReport(ctx, testStory); report.Outdated != 0 {
+		t.Errorf("report after restart = %+v; want nothing outdated", report)
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"time"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/repo"
+
+	"github.com/google/uuid"
+)
+
+// slotPattern — допустимые идентификаторы слотов: латиница в нижнем регистре, цифры, _ и -.
+var slotPattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// StartNewGame создаёт стартовое сохранение игрока в истории на её стартовой сцене
+// со значениями характеристик по умолчанию из манифеста.
+// Пустой slot означает текущий активный слот истории (или domain.DefaultSlot для первой игры).
+// Сохранения пишутся только добавлением, поэтому повторный вызов начинает игру заново:
+// новая запись становится последней в слоте, а старая история остаётся в базе.
+func (g *GameService) StartNewGame(ctx context.Context, playerID uuid.UUID, storyID, slot string) (domain.Scene, domain.Save, error) {
+	scenes, story, err := g.scenes(storyID)
+	if err != nil {
+		return domain.Scene{}, domain.Save{}, err
+	}
+
+	// 1. Определяем слот
+	if slot == "" {
+		current, err := g.GetLatestSave(ctx, playerID, storyID)
+		var outdated *SaveOutdatedError
+		switch {
+		case err == nil:
+			slot = current.Slot
+		case errors.Is(err, ErrGameNotStarted):
+			slot = domain.DefaultSlot
+		case errors.As(err, &outdated):
+			// прохождение не переносится на новую версию — начинаем его заново в том же слоте
+			slot = outdated.Slot
+		default:
+			return domain.Scene{}, domain.Save{}, err
+		}
+	}
+	if !slotPattern.MatchString(slot) {
+		return domain.Scene{}, domain.Save{}, ErrInvalidSlot
+	}
+
+	// 2. Проверяем, что стартовая сцена существует
+	start := startScene(story)
+	if _, err := scenes.Load(start); err != nil {
+		return domain.Scene{}, domain.Save{}, err
+	}
+
+	// 3. Создаём начальное сохранение; приход в стартовую сцену, как и после выбора,
+	// проходит по её автоматическим переходам и применяет OnEnter
+	save := domain.Save{
+		ID:           uuid.New(),
+		PlayerID:     playerID,
+		StoryID:      storyID,
+		StoryVersion: story.Version,
+		Slot:         slot,
+		SceneID:      start,
+		Stats:        story.DefaultStats(),
+		Flags:        []string{},
+		Seed:         rand.Int64(),
+		CreatedAt:    time.Now(),
+	}
+	save, scene, err := enterScene(scenes, story, save, start)
+	if err != nil {
+		return domain.Scene{}, domain.Save{}, err
+	}
+	if scene == nil {
+		return domain.Scene{}, domain.Save{}, fmt.Errorf("%w: %s", ErrSceneNotFound, save.SceneID)
+	}
+	if scene.IsEnding() {
+		save.EndingID = scene.Ending.ID
+	}
+	if err := g.SaveRepo.Create(ctx, save); err != nil {
+		return domain.Scene{}, domain.Save{}, err
+	}
+
+	return *scene, save, nil
+}
+
+// ListSlots возвращает слоты сохранений игрока в истории, недавно игранные первыми.
+// Сводка показана после переноса на текущую версию истории; слоты, которые
+// не переносятся, помечены Outdated. Характеристики отфильтрованы по видимости.
+func (g *GameService) ListSlots(ctx context.Context, playerID uuid.UUID, storyID string) ([]domain.SaveSlot, error) {
+	scenes, story, err := g.scenes(storyID)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := g.SaveRepo.ListSlots(ctx, playerID, storyID)
+	if err != nil {
+		return nil, err
+	}
+	for i, sl := range slots {
+		save, err := migrateSave(scenes, story, domain.Save{
+			Slot: sl.Slot, SceneID: sl.SceneID, Stats: sl.Stats, StoryVersion: sl.StoryVersion,
+		})
+		if errors.Is(err, ErrSaveOutdated) {
+			slots[i].Outdated = true
+			slots[i].Stats = story.VisibleStats(story.Normalize(sl.Stats))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		slots[i].SceneID = save.SceneID
+		slots[i].Stats = story.VisibleStats(save.Stats)
+	}
+	return slots, nil
+}
+
+// LoadSlot делает слот активным: следующие выборы продолжат прохождение из него.
+// Если сохранение слота не переносится на текущую версию истории, слот всё равно
+// становится активным, а возвращается *SaveOutdatedError.
+func (g *GameService) LoadSlot(ctx context.Context, playerID uuid.UUID, storyID, slot string) (domain.Save, error) {
+	if !slotPattern.MatchString(slot) {
+		return domain.Save{}, ErrInvalidSlot
+	}
+	scenes, story, err := g.scenes(storyID)
+	if err != nil {
+		return domain.Save{}, err
+	}
+	save, err := g.SaveRepo.ActivateSlot(ctx, playerID, storyID, slot)
+	if errors.Is(err, repo.ErrSaveNotFound) {
+		return domain.Save{}, ErrSlotNotFound
+	}
+	if err != nil {
+		return domain.Save{}, err
+	}
+	return migrateSave(scenes, story, save)
+}
+
+// RenameSlot меняет отображаемое имя слота.
+func (g *GameService) RenameSlot(ctx context.Context, playerID uuid.UUID, storyID, slot, name string) error {
+	if !slotPattern.MatchString(slot) {
+		return ErrInvalidSlot
+	}
+	if name == "" {
+		name = slot
+	}
+	err := g.SaveRepo.RenameSlot(ctx, playerID, storyID, slot, name)
+	if errors.Is(err, repo.ErrSaveNotFound) {
+		return ErrSlotNotFound
+	}
+	return err
+}
+
+// DeleteSlot удаляет слот со всей его историей.
+// Если удалён активный слот, игра считается не начатой, пока игрок не загрузит другой слот.
+func (g *GameService) DeleteSlot(ctx context.Context, playerID uuid.UUID, storyID, slot string) error {
+	if !slotPattern.MatchString(slot) {
+		return ErrInvalidSlot
+	}
+	err := g.SaveRepo.DeleteSlot(ctx, playerID, storyID, slot)
+	if errors.Is(err, repo.ErrSaveNotFound) {
+		return ErrSlotNotFound
+	}
+	return err
+}
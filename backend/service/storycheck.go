@@ -0,0 +1,526 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"blood-on-maple-leaves/backend/domain"
+	"blood-on-maple-leaves/backend/repo"
+)
+
+// Уровни серьёзности найденных проблем.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue — одна проблема в графе истории.
+type Issue struct {
+	Severity string `json:"severity"`
+	SceneID  string `json:"scene_id,omitempty"`
+	ChoiceID string `json:"choice_id,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (i Issue) String() string {
+	where := i.SceneID
+	if i.ChoiceID != "" {
+		where += "/" + i.ChoiceID
+	}
+	if where == "" {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, where, i.Message)
+}
+
+// StoryReport — результат проверки истории.
+type StoryReport struct {
+	Issues []Issue `json:"issues"`
+}
+
+// HasErrors сообщает, есть ли в отчёте ошибки (а не только предупреждения).
+func (r StoryReport) HasErrors() bool {
+	for _, i := range r.Issues {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *StoryReport) add(severity, sceneID, choiceID, format string, args ...any) {
+	r.Issues = append(r.Issues, Issue{
+		Severity: severity,
+		SceneID:  sceneID,
+		ChoiceID: choiceID,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// CheckStory загружает все сцены через SceneRepo и проверяет граф истории.
+// Ошибка возвращается, только если не удалось получить список сцен;
+// проблемы отдельных сцен (в том числе ошибки разбора) попадают в отчёт.
+func CheckStory(scenes repo.SceneRepo, story domain.Story) (StoryReport, error) {
+	ids, err := scenes.List()
+	if err != nil {
+		return StoryReport{}, err
+	}
+
+	var report StoryReport
+	loaded := make(map[string]domain.Scene, len(ids))
+	for _, id := range ids {
+		if !domain.ValidSceneID(id) {
+			report.add(SeverityError, id, "", "invalid scene id: use lowercase letters, digits, \"_\" and \"-\"")
+			continue
+		}
+		scene, err := scenes.Load(id)
+		if err != nil {
+			report.add(SeverityError, id, "", "cannot load scene: %v", err)
+			continue
+		}
+		loaded[id] = scene
+	}
+
+	report.Issues = append(report.Issues, ValidateScenes(loaded, story).Issues...)
+	return report, nil
+}
+
+// ValidateScenes проверяет уже загруженные сцены (ключ карты — идентификатор,
+// под которым сцена хранится, например имя файла без .yaml):
+//   - стартовая сцена существует;
+//   - id внутри сцены совпадает с ключом;
+//   - у сцены есть текст, а у не-концовки — хотя бы один выбор; сцене, которая
+//     всегда уводит переходом, ни то ни другое не нужно;
+//   - идентификаторы выборов не повторяются, next (в том числе у исходов бросков)
+//     указывает на существующую сцену;
+//   - проверки навыка ссылаются на известные характеристики, у случайных исходов
+//     есть уникальные id и неотрицательные веса;
+//   - эффекты, операции ops и условия используют только характеристики из манифеста,
+//     операции и операторы корректны, у clamp и random заданы нужные границы;
+//   - вставки {{...}} в текстах сцен и выборов разбираются и ссылаются на известные характеристики;
+//   - OnEnter меняет только известные характеристики, автоматические переходы ведут
+//     в существующие сцены и не образуют цикла;
+//   - миграции сохранений объявлены для версий не выше текущей, без повторов,
+//     переводят сцены в существующие и меняют только известные характеристики;
+//   - все сцены достижимы из стартовой (иначе предупреждение).
+func ValidateScenes(scenes map[string]domain.Scene, story domain.Story) StoryReport {
+	var report StoryReport
+
+	ids := make([]string, 0, len(scenes))
+	for id := range scenes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	start := story.Start
+	if start == "" {
+		start = DefaultStartScene
+	}
+	if _, ok := scenes[start]; !ok {
+		report.add(SeverityError, "", "", "start scene %q does not exist", start)
+	}
+
+	if _, ok := story.Stat(domain.PlayerVar); ok {
+		report.add(SeverityWarning, "", "", "stat %q cannot be inserted into text: {{%s}} is the player name", domain.PlayerVar, domain.PlayerVar)
+	}
+
+	setFlags := collectSetFlags(scenes)
+	checkMigrations(&report, story, scenes)
+	checkRedirectLoops(&report, scenes, ids)
+
+	for _, id := range ids {
+		scene := scenes[id]
+		if scene.ID != id {
+			report.add(SeverityError, id, "", "scene id %q does not match file name", scene.ID)
+		}
+		always := scene.AlwaysRedirects()
+		if scene.Text == "" && !always {
+			report.add(SeverityError, id, "", "scene has no text")
+		}
+		if scene.IsEnding() {
+			if scene.Ending.ID == "" {
+				report.add(SeverityError, id, "", "ending has no id")
+			}
+			if len(scene.Choices) > 0 {
+				report.add(SeverityWarning, id, "", "ending scene has choices that can never be taken")
+			}
+		} else if len(scene.Choices) == 0 && !always {
+			report.add(SeverityError, id, "", "scene has no choices and is not marked as an ending")
+		}
+		checkEntry(&report, story, scenes, setFlags, id, scene)
+		checkTemplate(&report, story, setFlags, id, "", scene.Text)
+		for _, ct := range scene.ConditionalText {
+			checkConditions(&report, story, setFlags, id, "", ct.Requires)
+			checkTemplate(&report, story, setFlags, id, "", ct.Text)
+		}
+
+		seen := map[string]bool{}
+		for _, choice := range scene.Choices {
+			if choice.ID == "" {
+				report.add(SeverityError, id, "", "choice has no id")
+			} else if seen[choice.ID] {
+				report.add(SeverityError, id, choice.ID, "duplicate choice id")
+			}
+			seen[choice.ID] = true
+
+			checkRandom(&report, story, id, choice)
+			for _, b := range choice.Branches() {
+				if b.Next == "" {
+					report.add(SeverityError, id, choice.ID, "choice has no next scene")
+				} else if !domain.ValidSceneID(b.Next) {
+					report.add(SeverityError, id, choice.ID, "next scene %q is not a valid scene id", b.Next)
+				} else if _, ok := scenes[b.Next]; !ok {
+					report.add(SeverityError, id, choice.ID, "next scene %q does not exist", b.Next)
+				}
+				for _, key := range sortedKeys(b.Effects) {
+					if _, ok := story.Stat(key); !ok {
+						report.add(SeverityError, id, choice.ID, "effect on unknown stat %q", key)
+					}
+				}
+				checkOps(&report, story, id, choice.ID, b.Ops)
+			}
+			checkConditions(&report, story, setFlags, id, choice.ID, choice.Requires)
+			checkTemplate(&report, story, setFlags, id, choice.ID, choice.Text)
+		}
+	}
+
+	// Достижимость из стартовой сцены
+	reachable := map[string]bool{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		scene, ok := scenes[id]
+		if !ok || reachable[id] {
+			continue
+		}
+		reachable[id] = true
+		for _, r := range scene.Redirects {
+			queue = append(queue, r.Next)
+		}
+		for _, choice := range scene.Choices {
+			for _, b := range choice.Branches() {
+				queue = append(queue, b.Next)
+			}
+		}
+	}
+	for _, id := range ids {
+		if !reachable[id] {
+			report.add(SeverityWarning, id, "", "scene is unreachable from start scene %q", start)
+		}
+	}
+
+	return report
+}
+
+// checkEntry проверяет OnEnter и автоматические переходы сцены.
+func checkEntry(report *StoryReport, story domain.Story, scenes map[string]domain.Scene, setFlags map[string]bool, sceneID string, scene domain.Scene) {
+	if scene.OnEnter != nil {
+		for _, key := range sortedKeys(scene.OnEnter.Effects) {
+			if _, ok := story.Stat(key); !ok {
+				report.add(SeverityError, sceneID, "", "on_enter effect on unknown stat %q", key)
+			}
+		}
+		checkOps(report, story, sceneID, "", scene.OnEnter.Ops)
+	}
+	for i, r := range scene.Redirects {
+		switch {
+		case r.Next == "":
+			report.add(SeverityError, sceneID, "", "redirect %d has no next scene", i+1)
+		case !domain.ValidSceneID(r.Next):
+			report.add(SeverityError, sceneID, "", "redirect %d: next scene %q is not a valid scene id", i+1, r.Next)
+		case r.Next == sceneID:
+			report.add(SeverityError, sceneID, "", "redirect %d leads back to the same scene", i+1)
+		default:
+			if _, ok := scenes[r.Next]; !ok {
+				report.add(SeverityError, sceneID, "", "redirect %d: next scene %q does not exist", i+1, r.Next)
+			}
+		}
+		checkConditions(report, story, setFlags, sceneID, "", r.Requires)
+		if i > 0 && len(scene.Redirects[i-1].Requires) == 0 {
+			report.add(SeverityWarning, sceneID, "", "redirect %d follows a redirect without conditions and is never taken", i+1)
+		}
+	}
+}
+
+// checkRedirectLoops ищет циклы в графе автоматических переходов. Условия переходов
+// не учитываются: цикл, который срабатывает лишь при некоторых состояниях, всё равно
+// ошибка — игра отклонит выбор, который в него приведёт.
+// Переходы в несуществующие сцены обходятся молча: о них сообщает проверка каждой сцены.
+func checkRedirectLoops(report *StoryReport, scenes map[string]domain.Scene, ids []string) {
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make(map[string]int, len(scenes))
+	reported := map[string]bool{}
+
+	var visit func(id string, path []string)
+	visit = func(id string, path []string) {
+		state[id] = onPath
+		path = append(path, id)
+		for _, r := range scenes[id].Redirects {
+			if _, ok := scenes[r.Next]; !ok || r.Next == id {
+				continue
+			}
+			switch state[r.Next] {
+			case unvisited:
+				visit(r.Next, path)
+			case onPath:
+				cycle := append(slices.Clone(path[slices.Index(path, r.Next):]), r.Next)
+				if !reported[r.Next] {
+					reported[r.Next] = true
+					report.add(SeverityError, r.Next, "", "redirect loop: %s", strings.Join(cycle, " -> "))
+				}
+			}
+		}
+		state[id] = done
+	}
+	for _, id := range ids {
+		if state[id] == unvisited {
+			visit(id, nil)
+		}
+	}
+}
+
+// checkMigrations проверяет миграции сохранений из манифеста. Сцена, в которую
+// миграция переводит игрока, может быть переименована более поздней миграцией,
+// поэтому проверяется конец всей цепочки переименований.
+func checkMigrations(report *StoryReport, story domain.Story, scenes map[string]domain.Scene) {
+	migrations := slices.Clone(story.Migrations)
+	sort.SliceStable(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, m := range migrations {
+		switch {
+		case m.Version <= 0:
+			report.add(SeverityError, "", "", "migration version %d must be positive", m.Version)
+		case m.Version > story.Version:
+			report.add(SeverityError, "", "", "migration to version %d is ahead of story version %d", m.Version, story.Version)
+		case i > 0 && migrations[i-1].Version == m.Version:
+			report.add(SeverityError, "", "", "duplicate migration to version %d", m.Version)
+		}
+
+		olds := make([]string, 0, len(m.Scenes))
+		for old := range m.Scenes {
+			olds = append(olds, old)
+		}
+		sort.Strings(olds)
+		for _, old := range olds {
+			target := m.Scenes[old]
+			for _, later := range migrations[i+1:] {
+				if next, ok := later.Scenes[target]; ok {
+					target = next
+				}
+			}
+			if _, ok := scenes[target]; !ok {
+				report.add(SeverityError, "", "", "migration to version %d moves scene %q to %q, which does not exist", m.Version, old, target)
+			}
+		}
+		for _, key := range sortedKeys(m.Stats) {
+			if _, ok := story.Stat(key); !ok {
+				report.add(SeverityError, "", "", "migration to version %d changes unknown stat %q", m.Version, key)
+			}
+		}
+	}
+}
+
+// checkOps проверяет операции эффектов: известные характеристики и операции,
+// обязательные для операции границы и источник копирования.
+func checkOps(report *StoryReport, story domain.Story, sceneID, choiceID string, ops []domain.Effect) {
+	for i, e := range ops {
+		n := i + 1
+		if _, ok := story.Stat(e.Stat); !ok {
+			report.add(SeverityError, sceneID, choiceID, "op %d changes unknown stat %q", n, e.Stat)
+		}
+		switch e.Op {
+		case domain.OpClamp:
+			if e.Min == nil && e.Max == nil {
+				report.add(SeverityError, sceneID, choiceID, "op %d: clamp needs min or max", n)
+			}
+		case domain.OpRandom:
+			if e.Min == nil || e.Max == nil {
+				report.add(SeverityError, sceneID, choiceID, "op %d: random needs both min and max", n)
+			}
+		case domain.OpCopy:
+			if _, ok := story.Stat(e.From); !ok {
+				report.add(SeverityError, sceneID, choiceID, "op %d copies unknown stat %q", n, e.From)
+			}
+		case domain.OpAdd, domain.OpSet, domain.OpMul:
+		default:
+			report.add(SeverityError, sceneID, choiceID, "op %d has invalid operation %q", n, e.Op)
+		}
+		if e.Min != nil && e.Max != nil && *e.Min > *e.Max {
+			report.add(SeverityError, sceneID, choiceID, "op %d: min %d is greater than max %d", n, *e.Min, *e.Max)
+		}
+	}
+}
+
+// checkRandom проверяет проверку навыка и случайные исходы выбора.
+func checkRandom(report *StoryReport, story domain.Story, sceneID string, choice domain.Choice) {
+	if !choice.IsRandom() {
+		return
+	}
+	if choice.Check != nil && len(choice.Outcomes) > 0 {
+		report.add(SeverityError, sceneID, choice.ID, "choice has both check and outcomes")
+	}
+	if choice.Next != "" || len(choice.Effects) > 0 || len(choice.Ops) > 0 || len(choice.SetFlags) > 0 || len(choice.ClearFlags) > 0 {
+		report.add(SeverityWarning, sceneID, choice.ID, "next, effects, ops and flags are ignored when the choice has check or outcomes")
+	}
+
+	if c := choice.Check; c != nil {
+		if _, ok := story.Stat(c.Stat); !ok {
+			report.add(SeverityError, sceneID, choice.ID, "check on unknown stat %q", c.Stat)
+		}
+		if c.Dice < 0 {
+			report.add(SeverityError, sceneID, choice.ID, "check dice must be positive")
+		}
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, o := range choice.Outcomes {
+		switch {
+		case o.ID == "":
+			report.add(SeverityError, sceneID, choice.ID, "outcome has no id")
+		case seen[o.ID]:
+			report.add(SeverityError, sceneID, choice.ID, "duplicate outcome id %q", o.ID)
+		}
+		seen[o.ID] = true
+		if o.Weight < 0 {
+			report.add(SeverityError, sceneID, choice.ID, "outcome %q has negative weight", o.ID)
+		}
+	}
+}
+
+// checkConditions проверяет условия рекурсивно: характеристики, операторы и флаги.
+func checkConditions(report *StoryReport, story domain.Story, setFlags map[string]bool, sceneID, choiceID string, conds []domain.Condition) {
+	for _, c := range conds {
+		if c.Stat != "" {
+			if _, ok := story.Stat(c.Stat); !ok {
+				report.add(SeverityError, sceneID, choiceID, "condition on unknown stat %q", c.Stat)
+			}
+			if !slices.Contains(domain.ConditionOps, c.Op) {
+				report.add(SeverityError, sceneID, choiceID, "condition has invalid operator %q", c.Op)
+			}
+		}
+		for _, f := range []string{c.Flag, c.NotFlag} {
+			if f != "" && !setFlags[f] {
+				report.add(SeverityWarning, sceneID, choiceID, "condition on flag %q that no choice sets", f)
+			}
+		}
+		checkConditions(report, story, setFlags, sceneID, choiceID, c.All)
+		checkConditions(report, story, setFlags, sceneID, choiceID, c.Any)
+	}
+}
+
+// collectSetFlags возвращает флаги, которые поднимает хотя бы один выбор
+// или OnEnter сцены истории.
+func collectSetFlags(scenes map[string]domain.Scene) map[string]bool {
+	setFlags := map[string]bool{}
+	for _, scene := range scenes {
+		if scene.OnEnter != nil {
+			for _, f := range scene.OnEnter.SetFlags {
+				setFlags[f] = true
+			}
+		}
+		for _, choice := range scene.Choices {
+			for _, b := range choice.Branches() {
+				for _, f := range b.SetFlags {
+					setFlags[f] = true
+				}
+			}
+		}
+	}
+	return setFlags
+}
+
+// checkTemplate проверяет вставки в тексте: синтаксис, характеристики и условия {{if}}.
+func checkTemplate(report *StoryReport, story domain.Story, setFlags map[string]bool, sceneID, choiceID, text string) {
+	t, err := domain.ParseTemplate(text)
+	if err != nil {
+		report.add(SeverityError, sceneID, choiceID, "text: %v", err)
+		return
+	}
+	for _, stat := range t.Stats() {
+		if _, ok := story.Stat(stat); !ok {
+			report.add(SeverityError, sceneID, choiceID, "text inserts unknown stat %q", stat)
+		}
+	}
+	checkConditions(report, story, setFlags, sceneID, choiceID, t.Conditions())
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// StoryStrings собирает все переводимые строки истории: манифест, затем сцены по порядку List.
+// Сцены, которые не загружаются, пропускаются — о них сообщает CheckStory.
+func StoryStrings(scenes repo.SceneRepo, story domain.Story) ([]domain.LocalString, error) {
+	ids, err := scenes.List()
+	if err != nil {
+		return nil, err
+	}
+	strs := story.Strings()
+	for _, id := range ids {
+		scene, err := scenes.Load(id)
+		if err != nil {
+			continue
+		}
+		strs = append(strs, scene.Strings()...)
+	}
+	return strs, nil
+}
+
+// TranslationReport — состояние перевода истории на один язык.
+type TranslationReport struct {
+	Locale       string               `json:"locale"`
+	Total        int                  `json:"total"`        // строк в истории
+	Untranslated []domain.LocalString `json:"untranslated"` // строки без перевода
+	Unknown      []string             `json:"unknown"`      // ключи перевода без исходной строки
+}
+
+// CheckTranslations сравнивает переводы со строками истории strs, по одному отчёту на язык
+// в алфавитном порядке языков.
+func CheckTranslations(strs []domain.LocalString, translations map[string]domain.Translation) []TranslationReport {
+	locales := make([]string, 0, len(translations))
+	for locale := range translations {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+
+	reports := make([]TranslationReport, 0, len(locales))
+	for _, locale := range locales {
+		t := translations[locale]
+		reports = append(reports, TranslationReport{
+			Locale:       locale,
+			Total:        len(strs),
+			Untranslated: t.Missing(strs),
+			Unknown:      t.Unknown(strs),
+		})
+	}
+	return reports
+}
+
+// CheckTranslationTemplates проверяет вставки {{...}} в переводе t так же, как
+// ValidateScenes проверяет исходный текст. В отчёте вместо сцены указывается ключ строки.
+func CheckTranslationTemplates(scenes map[string]domain.Scene, story domain.Story, t domain.Translation) StoryReport {
+	var report StoryReport
+	setFlags := collectSetFlags(scenes)
+	keys := make([]string, 0, len(t))
+	for key := range t {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		checkTemplate(&report, story, setFlags, key, "", t[key])
+	}
+	return report
+}
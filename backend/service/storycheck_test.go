@@ -0,0 +1,256 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"blood-on-maple-leaves/backend/domain"
+)
+
+func TestValidateScenes(t *testing.T) {
+	story := domain.Story{Start: "intro", Stats: []domain.StatDef{{Name: "rage"}}}
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Text: "…", Choices: []domain.Choice{
+			{ID: "go", Next: "hall", Effects: map[string]int{"rage": 1, "luck": 1}},
+			{ID: "go", Next: "nowhere"},
+			{ID: "wait", Next: "hall", Requires: []domain.Condition{{Stat: "rage", Op: "=>", Value: 1}}},
+		}},
+		"hall":    {ID: "hall", Text: "…"},
+		"renamed": {ID: "other", Text: "…", Ending: &domain.Ending{ID: "end"}},
+		"empty":   {ID: "empty", Ending: &domain.Ending{ID: "end2"}},
+	}
+
+	report := ValidateScenes(scenes, story)
+	if !report.HasErrors() {
+		t.Fatal("expected errors")
+	}
+
+	var got []string
+	for _, issue := range report.Issues {
+		got = append(got, issue.String())
+	}
+	all := strings.Join(got, "\n")
+	for _, want := range []string{
+		`error: intro/go: effect on unknown stat "luck"`,
+		`error: intro/go: duplicate choice id`,
+		`error: intro/go: next scene "nowhere" does not exist`,
+		`error: intro/wait: condition has invalid operator "=>"`,
+		`error: hall: scene has no choices and is not marked as an ending`,
+		`error: renamed: scene id "other" does not match file name`,
+		`error: empty: scene has no text`,
+		`warning: renamed: scene is unreachable from start scene "intro"`,
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("missing issue %q in:\n%s", want, all)
+		}
+	}
+}
+
+func TestCheckStoryValid(t *testing.T) {
+	story := domain.Story{Start: "intro", Stats: []domain.StatDef{{Name: "honor"}}}
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Text: "…", Choices: []domain.Choice{{ID: "bow", Next: "end", Effects: map[string]int{"honor": 1}}}},
+		"end":   {ID: "end", Text: "…", Ending: &domain.Ending{ID: "peace", Category: domain.EndingGood}},
+	}
+
+	report, err := CheckStory(&fakeSceneRepo{scenes: scenes}, story)
+	if err != nil {
+		t.Fatalf("CheckStory failed: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("unexpected issues: %v", report.Issues)
+	}
+}
+
+func TestCheckTranslations(t *testing.T) {
+	story := domain.Story{Title: "Ронин"}
+	scene := domain.Scene{ID: "intro", Text: "Дождь", Choices: []domain.Choice{{ID: "go", Text: "Идти"}}}
+	strs := append(story.Strings(), scene.Strings()...)
+
+	reports := CheckTranslations(strs, map[string]domain.Translation{
+		"en": {"story.title": "Ronin", "intro.text": "Rain", "intro.choice.run": "Run"},
+		"de": {},
+	})
+	if len(reports) != 2 || reports[0].Locale != "de" || reports[1].Locale != "en" {
+		t.Fatalf("reports = %+v", reports)
+	}
+	if len(reports[0].Untranslated) != 3 {
+		t.Errorf("de untranslated = %+v; want all 3 strings", reports[0].Untranslated)
+	}
+	en := reports[1]
+	if len(en.Untranslated) != 1 || en.Untranslated[0].Key != "intro.choice.go" {
+		t.Errorf("en untranslated = %+v; want intro.choice.go", en.Untranslated)
+	}
+	if len(en.Unknown) != 1 || en.Unknown[0] != "intro.choice.run" {
+		t.Errorf("en unknown = %v; want [intro.choice.run]", en.Unknown)
+	}
+}
+
+func TestValidateScenesTemplates(t *testing.T) {
+	story := domain.Story{Start: "intro", Stats: []domain.StatDef{{Name: "honor"}}}
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Text: "{{player}}: {{honr}} {{if honor >= 1}}да", Choices: []domain.Choice{
+			{ID: "go", Text: "{{if rage > 1}}Ярость{{end}}{{honor}}", Next: "end"},
+			{ID: "look", Text: "{{ honor | printf }}", Next: "end"},
+		}},
+		"end": {ID: "end", Text: "{{if honor >= 1}}Честь{{else}}Позор{{end}}", Ending: &domain.Ending{ID: "end"}},
+	}
+
+	var got []string
+	for _, issue := range ValidateScenes(scenes, story).Issues {
+		got = append(got, issue.String())
+	}
+	all := strings.Join(got, "\n")
+	for _, want := range []string{
+		`error: intro: text: invalid template: {{if honor >= 1}} without {{end}}`,
+		`error: intro/go: condition on unknown stat "rage"`,
+		`error: intro/look: text: invalid template: unknown action {{honor | printf}}`,
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("missing issue %q in:\n%s", want, all)
+		}
+	}
+	if strings.Contains(all, "end:") {
+		t.Errorf("valid template reported:\n%s", all)
+	}
+
+	// Опечатка в имени характеристики видна, когда шаблон в остальном корректен
+	scenes["intro"] = domain.Scene{ID: "intro", Text: "{{honr}}", Choices: []domain.Choice{{ID: "go", Next: "end"}}}
+	report := ValidateScenes(scenes, story)
+	if len(report.Issues) == 0 || report.Issues[0].String() != `error: intro: text inserts unknown stat "honr"` {
+		t.Errorf("issues = %v", report.Issues)
+	}
+}
+
+func TestValidateScenesRandomChoices(t *testing.T) {
+	story := domain.Story{Start: "intro", Stats: []domain.StatDef{{Name: "rage"}}}
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Text: "…", Choices: []domain.Choice{
+			{ID: "leap", Next: "intro", Check: &domain.SkillCheck{
+				Stat: "luck", Difficulty: 10,
+				Success: domain.Outcome{Next: "far"},
+				Failure: domain.Outcome{Next: "abyss"},
+			}},
+			{ID: "dice", Outcomes: []domain.Outcome{
+				{ID: "a", Next: "far", Effects: map[string]int{"gold": 1}},
+				{ID: "a", Weight: -1, Next: "far"},
+			}},
+		}},
+		"far": {ID: "far", Text: "…", Ending: &domain.Ending{ID: "end"}},
+	}
+
+	var got []string
+	for _, issue := range ValidateScenes(scenes, story).Issues {
+		got = append(got, issue.String())
+	}
+	all := strings.Join(got, "\n")
+	for _, want := range []string{
+		`warning: intro/leap: next, effects, ops and flags are ignored when the choice has check or outcomes`,
+		`error: intro/leap: check on unknown stat "luck"`,
+		`error: intro/leap: next scene "abyss" does not exist`,
+		`error: intro/dice: duplicate outcome id "a"`,
+		`error: intro/dice: outcome "a" has negative weight`,
+		`error: intro/dice: effect on unknown stat "gold"`,
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("missing issue %q in:\n%s", want, all)
+		}
+	}
+	if strings.Contains(all, "far: scene is unreachable") {
+		t.Errorf("outcome targets should count as reachable:\n%s", all)
+	}
+}
+
+func TestValidateScenesMigrations(t *testing.T) {
+	story := domain.Story{Version: 3, Start: "intro", Stats: []domain.StatDef{{Name: "honor"}}, Migrations: []domain.Migration{
+		{Version: 3, Scenes: map[string]string{"bridge": "ford"}},
+		{Version: 2, Scenes: map[string]string{"gate": "bridge", "well": "cellar"}, Stats: map[string]int{"luck": 1}},
+		{Version: 2},
+		{Version: 4},
+	}}
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Text: "…", Choices: []domain.Choice{{ID: "go", Next: "ford"}}},
+		"ford":  {ID: "ford", Text: "…", Ending: &domain.Ending{ID: "end"}},
+	}
+
+	var got []string
+	for _, issue := range ValidateScenes(scenes, story).Issues {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		`error: migration to version 2 moves scene "well" to "cellar", which does not exist`,
+		`error: migration to version 2 changes unknown stat "luck"`,
+		`error: duplicate migration to version 2`,
+		`error: migration to version 4 is ahead of story version 3`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidateScenesRedirects(t *testing.T) {
+	story := domain.Story{Start: "intro", Stats: []domain.StatDef{{Name: "honor"}}}
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Text: "…", Choices: []domain.Choice{{ID: "go", Next: "gate"}}},
+		"gate": {ID: "gate", Redirects: []domain.Redirect{
+			{Requires: []domain.Condition{{Flag: "oath"}}, Next: "hall"},
+			{Next: "yard"},
+			{Next: "intro"},
+		}},
+		"yard": {ID: "yard", Text: "…", OnEnter: &domain.SceneEntry{Effects: map[string]int{"luck": 1}, SetFlags: []string{"oath"}},
+			Choices: []domain.Choice{{ID: "back", Next: "intro"}}},
+		"hall":  {ID: "hall", Redirects: []domain.Redirect{{Next: "tower"}}},
+		"tower": {ID: "tower", Redirects: []domain.Redirect{{Next: "hall"}, {Requires: []domain.Condition{{Stat: "honor", Op: ">=", Value: 1}}, Next: "moat"}}},
+	}
+
+	var got []string
+	for _, issue := range ValidateScenes(scenes, story).Issues {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		`error: hall: redirect loop: hall -> tower -> hall`,
+		`warning: gate: redirect 3 follows a redirect without conditions and is never taken`,
+		`warning: tower: redirect 2 follows a redirect without conditions and is never taken`,
+		`error: tower: redirect 2: next scene "moat" does not exist`,
+		`error: yard: on_enter effect on unknown stat "luck"`,
+	}
+	slices.Sort(got)
+	slices.Sort(want)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestValidateScenesEffectOps(t *testing.T) {
+	one, two := 1, 2
+	story := domain.Story{Start: "intro", Stats: []domain.StatDef{{Name: "rage"}, {Name: "honor"}}}
+	scenes := map[string]domain.Scene{
+		"intro": {ID: "intro", Text: "…", Choices: []domain.Choice{{ID: "go", Next: "end", Ops: []domain.Effect{
+			{Stat: "rage", Op: domain.OpSet},
+			{Stat: "rage", Op: "pow", Value: 2},
+			{Stat: "luck", Op: domain.OpAdd, Value: 1},
+			{Stat: "honor", Op: domain.OpCopy, From: "luck"},
+			{Stat: "rage", Op: domain.OpClamp},
+			{Stat: "rage", Op: domain.OpRandom, Min: &two, Max: &one},
+		}}}},
+		"end": {ID: "end", Text: "…", Ending: &domain.Ending{ID: "end"},
+			OnEnter: &domain.SceneEntry{Ops: []domain.Effect{{Stat: "honor", Op: domain.OpRandom, Min: &one}}}},
+	}
+
+	var got []string
+	for _, issue := range ValidateScenes(scenes, story).Issues {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		`error: end: op 1: random needs both min and max`,
+		`error: intro/go: op 2 has invalid operation "pow"`,
+		`error: intro/go: op 3 changes unknown stat "luck"`,
+		`error: intro/go: op 4 copies unknown stat "luck"`,
+		`error: intro/go: op 5: clamp needs min or max`,
+		`error: intro/go: op 6: min 2 is greater than max 1`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("issues:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"sort"
+
+	"blood-on-maple-leaves/backend/domain"
+)
+
+// TranslatedString — перевод одной строки из внешнего файла (PO и т.п.)
+// вместе с исходным текстом, с которого переводили.
+type TranslatedString struct {
+	Key    string
+	Source string
+	Text   string
+}
+
+// ImportReport — итог импорта перевода.
+type ImportReport struct {
+	Updated   []string `json:"updated"`   // ключи, перевод которых добавлен или изменён
+	Unchanged int      `json:"unchanged"` // переводы, совпавшие с текущими
+	Empty     int      `json:"empty"`     // строки без перевода в файле
+	Stale     []string `json:"stale"`     // исходный текст изменился после выгрузки; перевод не применён
+	Unknown   []string `json:"unknown"`   // ключей больше нет в истории; перевод не применён
+}
+
+// ImportTranslation накладывает переводы entries на текущий перевод current
+// и возвращает новый перевод; current не меняется. Строки истории strs нужны,
+// чтобы отбросить переводы, сделанные со старого исходного текста (stale),
+// и переводы удалённых строк (unknown). Пустые переводы не стирают существующие.
+func ImportTranslation(strs []domain.LocalString, current domain.Translation, entries []TranslatedString) (domain.Translation, ImportReport) {
+	source := make(map[string]string, len(strs))
+	for _, s := range strs {
+		source[s.Key] = s.Text
+	}
+
+	next := make(domain.Translation, len(current)+len(entries))
+	for k, v := range current {
+		next[k] = v
+	}
+
+	var report ImportReport
+	for _, e := range entries {
+		text, ok := source[e.Key]
+		switch {
+		case !ok:
+			report.Unknown = append(report.Unknown, e.Key)
+		case e.Text == "":
+			report.Empty++
+		case e.Source != text:
+			report.Stale = append(report.Stale, e.Key)
+		case next[e.Key] == e.Text:
+			report.Unchanged++
+		default:
+			next[e.Key] = e.Text
+			report.Updated = append(report.Updated, e.Key)
+		}
+	}
+	sort.Strings(report.Updated)
+	sort.Strings(report.Stale)
+	sort.Strings(report.Unknown)
+	return next, report
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"blood-on-maple-leaves/backend/domain"
+)
+
+func TestImportTranslation(t *testing.T) {
+	strs := []domain.LocalString{
+		{Key: "intro.text", Text: "Дождь"},
+		{Key: "intro.choice.go", Text: "Идти"},
+		{Key: "intro.choice.wait", Text: "Ждать"},
+		{Key: "hall.text", Text: "Зал"},
+	}
+	current := domain.Translation{"intro.text": "Rain", "hall.text": "Hall"}
+
+	next, report := ImportTranslation(strs, current, []TranslatedString{
+		{Key: "intro.text", Source: "Дождь", Text: "Rain"},            // без изменений
+		{Key: "intro.choice.go", Source: "Идти", Text: "Go"},          // новый перевод
+		{Key: "intro.choice.wait", Source: "Подождать", Text: "Wait"}, // исходник изменился
+		{Key: "hall.text", Source: "Зал", Text: ""},                   // пустой не стирает
+		{Key: "gone.text", Source: "Ушло", Text: "Gone"},              // строки больше нет
+	})
+
+	want := domain.Translation{"intro.text": "Rain", "intro.choice.go": "Go", "hall.text": "Hall"}
+	if !reflect.DeepEqual(next, want) {
+		t.Errorf("translation = %v; want %v", next, want)
+	}
+	if len(current) != 2 {
+		t.Errorf("current translation was modified: %v", current)
+	}
+	if !reflect.DeepEqual(report.Updated, []string{"intro.choice.go"}) || report.Unchanged != 1 || report.Empty != 1 {
+		t.Errorf("report = %+v", report)
+	}
+	if !reflect.DeepEqual(report.Stale, []string{"intro.choice.wait"}) || !reflect.DeepEqual(report.Unknown, []string{"gone.text"}) {
+		t.Errorf("stale/unknown = %v / %v", report.Stale, report.Unknown)
+	}
+}
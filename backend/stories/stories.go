@@ -0,0 +1,13 @@
+// Package stories встраивает каталог историй в бинарник сервера.
+// Каждая подпапка с story.yaml — отдельная история (см. repo.Catalog).
+package stories
+
+import "embed"
+
+// FS — встроенный каталог историй; корень соответствует этой папке.
+// Встраиваются только YAML сцен и манифестов в папках историй и их переводы,
+// поэтому ни этот файл, ни посторонние файлы рядом в каталог не попадают.
+// Файлы с именами на "." и "_" не встраиваются.
+//
+//go:embed */*.yaml */locales/*.yaml
+var FS embed.FS